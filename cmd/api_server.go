@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -18,6 +20,7 @@ import (
 	repo "github.com/map-services/company-data-api/internal/repositories"
 	"github.com/map-services/company-data-api/internal/routes"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/redis/go-redis/v9"
 	"github.com/rm-hull/godx"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -31,26 +34,114 @@ import (
 // @version 1.0
 // @description A fast REST API for querying UK company data by geographic bounding box, built with Go, SQLite, and Gin. It imports official datasets from Companies House and Ordnance Survey CodePoint Open, providing spatial search capabilities for company records.
 // @BasePath /v1/company-data
-func ApiServer(dbPath string, port int, debug bool) {
+func ApiServer(dbPath string, port int, debug bool, reloadInterval time.Duration, queryTimeout time.Duration, maxResults int, apiKeysPath string, cacheOptions CacheOptions, backend string, servingSnapshotPath string) {
 	logger := internal.SetupLogger()
 	godx.Diagnostics(logger)
 
-	db, err := internal.Connect(dbPath)
+	if backend != BackendSQLite && backend != BackendMemory {
+		slog.Error("unknown search backend", "backend", backend, "backends", []string{BackendSQLite, BackendMemory})
+		os.Exit(1)
+	}
+	if servingSnapshotPath != "" && backend != BackendSQLite {
+		slog.Error("a serving snapshot is already searched in memory, so cannot be used with another backend", "backend", backend)
+		os.Exit(1)
+	}
+
+	apiKeys := map[string]middleware.APIKey{}
+	if apiKeysPath != "" {
+		var err error
+		if apiKeys, err = middleware.LoadAPIKeys(apiKeysPath); err != nil {
+			slog.Error("failed to load API keys", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Loaded API keys", "count", len(apiKeys))
+	}
+
+	cache, closeCache, err := newCache(cacheOptions)
 	if err != nil {
-		slog.Error("failed to initialize database", "error", err)
+		slog.Error("failed to initialize cache", "error", err)
 		os.Exit(1)
 	}
-	defer func() {
-		if err := db.Close(); err != nil {
-			slog.Error("error closing database", "error", err)
+	defer closeCache()
+
+	newRepository := func(db *sql.DB, backend string) (repo.SearchRepository, error) {
+		if backend == BackendMemory {
+			// Searches served from memory are as quick as cache hits, so
+			// aren't cached.
+			return repo.NewMemoryRepository(db, repo.WithQueryTimeout(queryTimeout))
 		}
-	}()
+		sqliteRepo, err := repo.NewSqliteDbRepository(db, repo.WithQueryTimeout(queryTimeout))
+		if err != nil || cache == nil {
+			return sqliteRepo, err
+		}
+		version, err := repo.DatasetVersion(db)
+		if err != nil {
+			return nil, err
+		}
+		return repo.NewCachingRepository(sqliteRepo, cache, version), nil
+	}
 
-	repo, err := repo.NewSqliteDbRepository(db)
+	// Snapshots are never written to, so are opened read-only. They are
+	// searched rarely enough that they're never loaded into memory.
+	snapshots := repo.NewSnapshots(internal.SnapshotDir(dbPath), func(path string) (*sql.DB, repo.SearchRepository, error) {
+		db, err := internal.ConnectReadOnly(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		searchRepo, err := newRepository(db, BackendSQLite)
+		if err != nil {
+			_ = db.Close()
+			return nil, nil, fmt.Errorf("failed to initialize repository: %w", err)
+		}
+		return db, searchRepo, nil
+	})
+
+	// The live database is only ever written to, and migrated, by imports.
+	open := func() (repo.Database, repo.SearchRepository, error) {
+		db, err := internal.ConnectReadOnly(dbPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize database: %w", err)
+		}
+		searchRepo, err := newRepository(db, backend)
+		if err != nil {
+			_ = db.Close()
+			return nil, nil, fmt.Errorf("failed to initialize repository: %w", err)
+		}
+		return db, searchRepo, nil
+	}
+	watchPath := dbPath
+	if servingSnapshotPath != "" {
+		// The snapshot is replaced by each export, and reloaded like the
+		// database would be.
+		watchPath = servingSnapshotPath
+		open = func() (repo.Database, repo.SearchRepository, error) {
+			snapshot, err := repo.OpenServingSnapshot(servingSnapshotPath)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to open serving snapshot: %w", err)
+			}
+			slog.Info("Opened serving snapshot", "path", servingSnapshotPath, "rows", snapshot.Rows(), "datasetVersion", snapshot.DatasetVersion())
+			if cache == nil {
+				return snapshot, snapshot, nil
+			}
+			return snapshot, repo.NewCachingRepository(snapshot, cache, snapshot.DatasetVersion()), nil
+		}
+	}
+
+	reloadable, err := repo.NewReloadableRepository(open)
 	if err != nil {
 		slog.Error("failed to initialize repository", "error", err)
 		os.Exit(1)
 	}
+	reloadable.SetSnapshots(snapshots)
+	defer func() {
+		if err := reloadable.Close(); err != nil {
+			slog.Error("error closing database", "error", err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go internal.WatchForReload(ctx, watchPath, reloadInterval, reloadable.Reload)
 
 	r := gin.New()
 
@@ -71,7 +162,7 @@ func ApiServer(dbPath string, port int, debug bool) {
 			Immutable: true,
 			Public:    true,
 		}),
-		cors.Default(),
+		cors.New(corsConfig()),
 	)
 
 	if debug {
@@ -80,16 +171,26 @@ func ApiServer(dbPath string, port int, debug bool) {
 	}
 
 	err = healthcheck.New(r, hc_config.DefaultConfig(), []checks.Check{
-		checks.SqlCheck{Sql: db},
+		reloadable,
 	})
 	if err != nil {
 		slog.Error("failed to initialize healthcheck", "error", err)
 		os.Exit(1)
 	}
 
-	v1 := r.Group("/v1/company-data")
-	v1.GET("/search", routes.Search(repo))
-	v1.GET("/search/by-postcode", routes.GroupByPostcode(repo))
+	v1 := r.Group("/v1/company-data", middleware.ResultLimit(maxResults, apiKeys))
+	v1.GET("/search", routes.Search(reloadable))
+	v1.GET("/search/by-postcode", routes.GroupByPostcode(reloadable))
+	v1.GET("/search/by-area", routes.SearchByArea(reloadable))
+	v1.GET("/search/radius", routes.SearchRadius(reloadable))
+	v1.GET("/search/nearest", routes.SearchNearest(reloadable))
+	// The latest page of the change feed, and a company's timeline, grow with
+	// each import, so they must not be cached.
+	v1.GET("/changes", cachecontrol.New(cachecontrol.NoCachePreset), routes.Changes(reloadable))
+	v1.GET("/companies/:number/timeline", cachecontrol.New(cachecontrol.NoCachePreset), routes.CompanyTimeline(reloadable))
+	// The report changes with every import and relocation, so must not be
+	// cached. Only admin API keys may see it.
+	v1.GET("/admin/unmatched", middleware.RequireAdmin(apiKeys), cachecontrol.New(cachecontrol.NoCachePreset), routes.UnmatchedReport(reloadable))
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	addr := fmt.Sprintf(":%d", port)
@@ -99,3 +200,60 @@ func ApiServer(dbPath string, port int, debug bool) {
 		os.Exit(1)
 	}
 }
+
+// Backends that searches of the live database may be served from: SQLite
+// queries, or every company loaded into memory when the database is opened.
+const (
+	BackendSQLite = "sqlite"
+	BackendMemory = "memory"
+)
+
+// CacheOptions chooses where search results are cached: in a Redis-protocol
+// server if a URL is given, so that every API server shares them, or in
+// memory otherwise.
+type CacheOptions struct {
+	SizeMB   int // of the in-memory cache; 0 disables it
+	RedisURL string
+	TTL      time.Duration // of results cached in Redis
+}
+
+// newCache returns the cache for the options, or nil if caching is disabled,
+// and a function to release it.
+func newCache(opts CacheOptions) (repo.Cache, func(), error) {
+	if opts.RedisURL == "" {
+		if opts.SizeMB <= 0 {
+			return nil, func() {}, nil
+		}
+		return repo.NewMemoryCache(opts.SizeMB << 20), func() {}, nil
+	}
+
+	redisOpts, err := redis.ParseURL(opts.RedisURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	client := redis.NewClient(redisOpts)
+	// Searches carry on without the cache while it is unavailable, so an
+	// unreachable server at startup isn't fatal.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		slog.Warn("unable to reach the Redis cache", "addr", redisOpts.Addr, "error", err)
+	} else {
+		slog.Info("Caching search results in Redis", "addr", redisOpts.Addr, "ttl", opts.TTL)
+	}
+	return repo.NewRedisCache(client, opts.TTL), func() {
+		if err := client.Close(); err != nil {
+			slog.Error("error closing Redis client", "error", err)
+		}
+	}, nil
+}
+
+// corsConfig allows any origin, as cors.Default does, and also lets browsers
+// send an API key and read whether search results were truncated.
+func corsConfig() cors.Config {
+	config := cors.DefaultConfig()
+	config.AllowAllOrigins = true
+	config.AddAllowHeaders(middleware.APIKeyHeader)
+	config.AddExposeHeaders(routes.TruncatedHeader)
+	return config
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/map-services/company-data-api/internal"
+	repo "github.com/map-services/company-data-api/internal/repositories"
+	"github.com/rm-hull/godx"
+)
+
+// ExportSnapshot writes the located companies in the database at dbPath to a
+// serving snapshot at outputPath, which the API server can then search
+// without the database.
+func ExportSnapshot(dbPath string, outputPath string) {
+	logger := internal.SetupLogger()
+	godx.Diagnostics(logger)
+
+	// Rather than exporting the empty database ConnectReadOnly would fall
+	// back to.
+	if _, err := os.Stat(dbPath); err != nil {
+		slog.Error("failed to open database", "error", err)
+		os.Exit(1)
+	}
+	db, err := internal.ConnectReadOnly(dbPath)
+	if err != nil {
+		slog.Error("failed to open database", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			slog.Error("error closing database", "error", err)
+		}
+	}()
+
+	rows, err := repo.ExportServingSnapshot(db, outputPath)
+	if err != nil {
+		slog.Error("failed to export serving snapshot", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Exported serving snapshot", "outputPath", outputPath, "companies", rows)
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/importer"
+	"github.com/rm-hull/godx"
+)
+
+// ImportDataset imports the named dataset from source, a local file or URL,
+// falling back to the dataset's default source when empty.
+func ImportDataset(name string, source string, dbPath string, blueGreen bool, opts ...importer.Option) {
+	logger := internal.SetupLogger()
+	godx.Diagnostics(logger)
+
+	dataset, err := importer.Lookup(name)
+	if err != nil {
+		slog.Error("failed to import dataset", "error", err)
+		os.Exit(1)
+	}
+	if source == "" {
+		source = dataset.DefaultSource()
+	}
+
+	err = importInto(dbPath, blueGreen, func(db *sql.DB) error {
+		return internal.TransientDownload(source, dataset.NewImporter(db, opts...).Import)
+	})
+	if err != nil {
+		slog.Error("failed to import dataset", "dataset", name, "error", err)
+		os.Exit(1)
+	}
+}
+
+// DryRunDataset parses the named dataset without writing anything, and
+// writes a quality report comparing it with the database at dbPath to
+// reportPath, or to stdout if reportPath is empty.
+func DryRunDataset(name string, source string, dbPath string, reportPath string, opts ...importer.Option) {
+	logger := internal.SetupLogger()
+	godx.Diagnostics(logger)
+
+	if err := dryRun(name, source, dbPath, reportPath, opts...); err != nil {
+		slog.Error("failed to dry run dataset import", "dataset", name, "error", err)
+		os.Exit(1)
+	}
+}
+
+func dryRun(name string, source string, dbPath string, reportPath string, opts ...importer.Option) error {
+	dataset, err := importer.Lookup(name)
+	if err != nil {
+		return err
+	}
+	if source == "" {
+		source = dataset.DefaultSource()
+	}
+
+	report := os.Stdout
+	if reportPath != "" {
+		if report, err = os.Create(reportPath); err != nil {
+			return fmt.Errorf("failed to create report file: %w", err)
+		}
+		defer func() {
+			if err := report.Close(); err != nil {
+				slog.Error("error closing report file", "error", err)
+			}
+		}()
+	}
+
+	db, err := internal.ConnectReadOnly(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			slog.Error("error closing database", "error", err)
+		}
+	}()
+
+	opts = append(opts, importer.WithDryRun(report))
+	return internal.TransientDownload(source, dataset.NewImporter(db, opts...).Import)
+}
+
+// importInto connects to the database at dbPath and runs the import against
+// it. With blueGreen set, the import is instead built in a staging copy of the
+// database, which is optimized and then atomically promoted over dbPath once
+// the import has completed successfully.
+func importInto(dbPath string, blueGreen bool, importFn func(db *sql.DB) error) error {
+	targetPath := dbPath
+	if blueGreen {
+		stagingPath, err := internal.PrepareStaging(dbPath)
+		if err != nil {
+			return err
+		}
+		targetPath = stagingPath
+	}
+
+	db, err := internal.Connect(targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	closeDb := func() {
+		if db == nil {
+			return
+		}
+		if err := db.Close(); err != nil {
+			slog.Error("error closing database", "error", err)
+		}
+		db = nil
+	}
+	defer closeDb()
+
+	if err := importFn(db); err != nil {
+		return err
+	}
+
+	if !blueGreen {
+		return nil
+	}
+
+	if err := internal.OptimizeForServing(db); err != nil {
+		return err
+	}
+	closeDb()
+	return internal.PromoteStaging(targetPath, dbPath)
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/rm-hull/godx"
+)
+
+// Snapshot saves a copy of the database at dbPath as the snapshot for the
+// given date, which the API server then searches when asked for as_of that
+// date or later.
+func Snapshot(dbPath string, date time.Time) {
+	logger := internal.SetupLogger()
+	godx.Diagnostics(logger)
+
+	snapshotPath, err := internal.CreateSnapshot(dbPath, date)
+	if err != nil {
+		slog.Error("failed to create snapshot", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Created snapshot", "date", date.Format(time.DateOnly), "snapshotPath", snapshotPath)
+}
@@ -15,9 +15,230 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/unmatched": {
+            "get": {
+                "description": "Counts the companies located at their registered postcode, or at the centroid of its sector or district when the postcode is not in CodePoint Open, and those that could not be located at all, by reason: missing, invalid, outside_coverage (Northern Ireland, the Channel Islands and the Isle of Man, which CodePoint Open does not cover) or unknown (terminated postcodes and well-formed typos). The unmatched postcodes shared by the most companies are listed. Unmatched companies are not returned by bounding box searches.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Report companies whose registered postcode could not be located",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API key with admin access",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.LocationReport"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/changes": {
+            "get": {
+                "description": "Returns the changes detected when each Companies House snapshot was imported: new companies (incorporated), dissolved, status_changed, renamed, address_changed and removed. Changes are ordered by ID and paginated: pass the returned next_cursor as the cursor parameter to fetch the following page. To sync incrementally, keep the cursor of the last page and pass it on the next sync.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "changes"
+                ],
+                "summary": "List changes between Companies House imports",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only changes detected at or after this time, as RFC 3339 or YYYY-MM-DD",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bounding box as comma-separated values: minEasting,minNorthing,maxEasting,maxNorthing. Changes match on the company's location, and address changes on either its old or new location.",
+                        "name": "bbox",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor returned by the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 1000, maximum 5000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.ChangesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{number}/timeline": {
+            "get": {
+                "description": "Returns every change detected for a company across Companies House imports, oldest first: incorporated, renamed, address_changed (with the location of the old and new postcodes), status_changed, dissolved, sic_changed, accounts_filed (when accounts_last_made_up_date moves on) and removed. Changes are only recorded from the second import onwards, so companies that have not changed since the database was first loaded have no timeline.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "changes"
+                ],
+                "summary": "List the changes recorded for a company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company number, e.g. 01234567 or SC123456. Numbers of fewer than 8 digits are padded with leading zeros.",
+                        "name": "number",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.TimelineResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/search": {
             "get": {
-                "description": "Returns companies within the specified bounding box",
+                "description": "Returns companies within the specified bounding box. If there are more than the maximum a search may return, only that many are returned and the response is marked truncated (and the X-Results-Truncated header set): narrow the bounding box, or page through an area with /search/by-area, to see the rest.",
                 "produces": [
                     "application/json"
                 ],
@@ -32,13 +253,31 @@ const docTemplate = `{
                         "name": "bbox",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search the latest snapshot taken on or before this date (YYYY-MM-DD)",
+                        "name": "as_of",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API key, for a different maximum number of results",
+                        "name": "X-API-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/internal.SearchResponse"
+                            "$ref": "#/definitions/routes.SearchResponse"
+                        },
+                        "headers": {
+                            "X-Results-Truncated": {
+                                "type": "string",
+                                "description": "true if the results were truncated"
+                            }
                         }
                     },
                     "400": {
@@ -50,6 +289,24 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -58,13 +315,118 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/search/by-area": {
+            "get": {
+                "description": "Returns companies whose registered postcode lies within the given region, county, district or ward. Results are ordered by company number and paginated: pass the returned next_cursor as the cursor parameter to fetch the following page.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "search"
+                ],
+                "summary": "Search companies within an administrative area",
+                "parameters": [
+                    {
+                        "enum": [
+                            "region",
+                            "county",
+                            "district",
+                            "ward"
+                        ],
+                        "type": "string",
+                        "description": "Area type",
+                        "name": "type",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Area code, e.g. E07000041",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor returned by the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 1000, maximum 5000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search the latest snapshot taken on or before this date (YYYY-MM-DD)",
+                        "name": "as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.AreaSearchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
             }
         },
         "/search/by-postcode": {
             "get": {
-                "description": "Returns companies grouped by postcode within the specified bounding box",
+                "description": "Returns companies grouped by postcode within the specified bounding box. Results beyond the maximum a search may return are truncated, as for /search.",
                 "produces": [
                     "application/json"
                 ],
@@ -74,18 +436,239 @@ const docTemplate = `{
                 "summary": "Group companies by postcode within bounding box",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Bounding box as comma-separated values: minLon,minLat,maxLon,maxLat",
-                        "name": "bbox",
+                        "type": "string",
+                        "description": "Bounding box as comma-separated values: minLon,minLat,maxLon,maxLat",
+                        "name": "bbox",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search the latest snapshot taken on or before this date (YYYY-MM-DD)",
+                        "name": "as_of",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API key, for a different maximum number of results",
+                        "name": "X-API-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.GroupedSearchResponse"
+                        },
+                        "headers": {
+                            "X-Results-Truncated": {
+                                "type": "string",
+                                "description": "true if the results were truncated"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/search/nearest": {
+            "get": {
+                "description": "Returns the companies registered nearest to a point, nearest first, with ties ordered by company number. Companies further away than max_distance are not returned, so fewer than k may be.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "search"
+                ],
+                "summary": "Search the companies nearest to a point",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Point as comma-separated values: easting,northing",
+                        "name": "point",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of companies (default 10, maximum 100)",
+                        "name": "k",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Maximum distance in metres (default and maximum 2500)",
+                        "name": "max_distance",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search the latest snapshot taken on or before this date (YYYY-MM-DD)",
+                        "name": "as_of",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API key, for a different maximum number of results",
+                        "name": "X-API-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.SearchResponse"
+                        },
+                        "headers": {
+                            "X-Results-Truncated": {
+                                "type": "string",
+                                "description": "true if the results were truncated"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/search/radius": {
+            "get": {
+                "description": "Returns companies within the given distance of a point, nearest first, with ties ordered by company number. Results beyond the maximum a search may return are truncated, as for /search.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "search"
+                ],
+                "summary": "Search companies within a radius of a point",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Point as comma-separated values: easting,northing",
+                        "name": "point",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Radius in metres (maximum 2500)",
+                        "name": "radius",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search the latest snapshot taken on or before this date (YYYY-MM-DD)",
+                        "name": "as_of",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API key, for a different maximum number of results",
+                        "name": "X-API-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/internal.GroupedSearchResponse"
+                            "$ref": "#/definitions/routes.SearchResponse"
+                        },
+                        "headers": {
+                            "X-Results-Truncated": {
+                                "type": "string",
+                                "description": "true if the results were truncated"
+                            }
                         }
                     },
                     "400": {
@@ -97,6 +680,24 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -105,46 +706,48 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
             }
         }
     },
     "definitions": {
-        "internal.GroupedSearchResponse": {
+        "models.CompanyChange": {
             "type": "object",
             "properties": {
-                "attribution": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "change_type": {
+                    "type": "string"
                 },
-                "results": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "array",
-                        "items": {
-                            "$ref": "#/definitions/models.CompanyDataWithLocation"
-                        }
-                    }
-                }
-            }
-        },
-        "internal.SearchResponse": {
-            "type": "object",
-            "properties": {
-                "attribution": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "company_number": {
+                    "type": "string"
                 },
-                "results": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.CompanyDataWithLocation"
-                    }
+                "detected_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "new_value": {
+                    "type": "string"
+                },
+                "old_value": {
+                    "type": "string"
+                },
+                "post_code": {
+                    "description": "PostCode is the company's registered postcode after the change (or\nbefore it, for a removed company), and PreviousPostCode the one it\nmoved from, for an address change.",
+                    "type": "string"
+                },
+                "previous_post_code": {
+                    "type": "string"
                 }
             }
         },
@@ -184,12 +787,27 @@ const docTemplate = `{
                 "conf_stmt_next_due_date": {
                     "type": "string"
                 },
+                "country_code": {
+                    "type": "string"
+                },
                 "country_of_origin": {
                     "type": "string"
                 },
+                "county_code": {
+                    "type": "string"
+                },
+                "county_name": {
+                    "type": "string"
+                },
                 "dissolution_date": {
                     "type": "string"
                 },
+                "district_code": {
+                    "type": "string"
+                },
+                "district_name": {
+                    "type": "string"
+                },
                 "easting": {
                     "type": "integer"
                 },
@@ -202,6 +820,10 @@ const docTemplate = `{
                 "limited_partnerships_num_lim_partners": {
                     "type": "integer"
                 },
+                "location_precision": {
+                    "description": "LocationPrecision is \"postcode\" when the company is placed at its\nregistered postcode, or \"sector\" or \"district\" when that postcode is\nnot in CodePoint Open and the company is placed at the centroid of its\npostcode sector (\"AB10 1\") or district (\"AB10\") instead.",
+                    "type": "string"
+                },
                 "mortgages_num_charges": {
                     "type": "integer"
                 },
@@ -214,9 +836,19 @@ const docTemplate = `{
                 "mortgages_num_satisfied": {
                     "type": "integer"
                 },
+                "nhs_ha_code": {
+                    "type": "string"
+                },
+                "nhs_region_code": {
+                    "type": "string"
+                },
                 "northing": {
                     "type": "integer"
                 },
+                "positional_quality": {
+                    "description": "PositionalQuality is the CodePoint Open positional quality indicator:\n10 (within the building of the matched address closest to the postcode\nmean) through 60 (estimated to postcode sector level), or 90 (no\ncoordinates available). 0 means it was not recorded at import time.",
+                    "type": "integer"
+                },
                 "reg_address_address_line_1": {
                     "type": "string"
                 },
@@ -261,6 +893,222 @@ const docTemplate = `{
                 },
                 "uri": {
                     "type": "string"
+                },
+                "ward_code": {
+                    "type": "string"
+                },
+                "ward_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Location": {
+            "type": "object",
+            "properties": {
+                "easting": {
+                    "type": "number"
+                },
+                "northing": {
+                    "type": "number"
+                }
+            }
+        },
+        "models.LocationReport": {
+            "type": "object",
+            "properties": {
+                "located": {
+                    "description": "Located counts the companies with a location, by its precision:\npostcode, sector or district.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "top_postcodes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UnmatchedPostcode"
+                    }
+                },
+                "total_unmatched": {
+                    "type": "integer"
+                },
+                "unmatched": {
+                    "description": "Unmatched counts the companies without a location, by reason: missing,\ninvalid, outside_coverage (Northern Ireland, the Channel Islands and\nthe Isle of Man) or unknown.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "models.TimelineEvent": {
+            "type": "object",
+            "properties": {
+                "change_type": {
+                    "type": "string"
+                },
+                "company_number": {
+                    "type": "string"
+                },
+                "detected_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "location": {
+                    "$ref": "#/definitions/models.Location"
+                },
+                "new_value": {
+                    "type": "string"
+                },
+                "old_value": {
+                    "type": "string"
+                },
+                "post_code": {
+                    "description": "PostCode is the company's registered postcode after the change (or\nbefore it, for a removed company), and PreviousPostCode the one it\nmoved from, for an address change.",
+                    "type": "string"
+                },
+                "previous_location": {
+                    "$ref": "#/definitions/models.Location"
+                },
+                "previous_post_code": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UnmatchedPostcode": {
+            "type": "object",
+            "properties": {
+                "companies": {
+                    "type": "integer"
+                },
+                "post_code": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "routes.AreaSearchResponse": {
+            "type": "object",
+            "properties": {
+                "attribution": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "last_updated": {
+                    "type": "string"
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CompanyDataWithLocation"
+                    }
+                },
+                "snapshot_date": {
+                    "type": "string"
+                }
+            }
+        },
+        "routes.ChangesResponse": {
+            "type": "object",
+            "properties": {
+                "attribution": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CompanyChange"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "routes.GroupedSearchResponse": {
+            "type": "object",
+            "properties": {
+                "attribution": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "last_updated": {
+                    "type": "string"
+                },
+                "results": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/models.CompanyDataWithLocation"
+                        }
+                    }
+                },
+                "snapshot_date": {
+                    "type": "string"
+                },
+                "truncated": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "routes.SearchResponse": {
+            "type": "object",
+            "properties": {
+                "attribution": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "last_updated": {
+                    "type": "string"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CompanyDataWithLocation"
+                    }
+                },
+                "snapshot_date": {
+                    "type": "string"
+                },
+                "truncated": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "routes.TimelineResponse": {
+            "type": "object",
+            "properties": {
+                "attribution": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "company_number": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TimelineEvent"
+                    }
                 }
             }
         }
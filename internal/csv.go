@@ -64,3 +64,150 @@ func ParseCSV[T any](reader io.Reader, includesHeader bool, fromFunc func(data [
 		}
 	}
 }
+
+// csvChunk is a run of consecutive CSV records that is parsed by a single
+// worker. Chunks are consumed in the order they were read, so results are
+// yielded in the same order as the input regardless of which worker
+// finishes first.
+type csvChunk[T any] struct {
+	firstLineNum int
+	records      [][]string
+	results      []Result[T]
+	ready        chan struct{}
+}
+
+const csvChunkSize = 256
+
+// ParseCSVConcurrently behaves like ParseCSV, but converts records with
+// fromFunc on a pool of workers. Records are read on a single goroutine and
+// handed out in chunks; at most a bounded number of chunks are in flight at
+// any one time, so memory use stays flat regardless of the input size.
+// Results are yielded in input order. With workers <= 1 it is equivalent to
+// ParseCSV.
+func ParseCSVConcurrently[T any](reader io.Reader, includesHeader bool, workers int, fromFunc func(data []string, headers []string) (T, error)) iter.Seq[Result[T]] {
+	if workers <= 1 {
+		return ParseCSV(reader, includesHeader, fromFunc)
+	}
+
+	return func(yield func(Result[T]) bool) {
+		done := make(chan struct{})
+		defer close(done)
+
+		jobs := make(chan *csvChunk[T])
+		ordered := make(chan *csvChunk[T], workers*2)
+
+		var headers []string
+
+		// send queues a chunk for the consumer, and (unless it already holds
+		// its results) for a worker. It reports false if the consumer has gone.
+		send := func(chunk *csvChunk[T], parse bool) bool {
+			select {
+			case ordered <- chunk:
+			case <-done:
+				return false
+			}
+			if !parse {
+				return true
+			}
+			select {
+			case jobs <- chunk:
+				return true
+			case <-done:
+				return false
+			}
+		}
+
+		failed := func(lineNum int, err error) *csvChunk[T] {
+			chunk := &csvChunk[T]{
+				results: []Result[T]{{LineNum: lineNum, Error: err}},
+				ready:   make(chan struct{}),
+			}
+			close(chunk.ready)
+			return chunk
+		}
+
+		go func() {
+			defer close(ordered)
+			defer close(jobs)
+
+			csvReader := csv.NewReader(reader)
+			lineNum := 0
+
+			if includesHeader {
+				var err error
+				headers, err = csvReader.Read()
+				if err != nil {
+					send(failed(lineNum, fmt.Errorf("failed to read CSV headers: %w", err)), false)
+					return
+				}
+			}
+
+			newChunk := func() *csvChunk[T] {
+				return &csvChunk[T]{
+					firstLineNum: lineNum + 1,
+					records:      make([][]string, 0, csvChunkSize),
+					ready:        make(chan struct{}),
+				}
+			}
+
+			chunk := newChunk()
+			for {
+				record, err := csvReader.Read()
+				if err == io.EOF {
+					break
+				}
+				lineNum++
+				if err != nil {
+					if len(chunk.records) > 0 && !send(chunk, true) {
+						return
+					}
+					send(failed(lineNum, fmt.Errorf("failed to read CSV line %d: %w", lineNum, err)), false)
+					return
+				}
+
+				chunk.records = append(chunk.records, record)
+				if len(chunk.records) == csvChunkSize {
+					if !send(chunk, true) {
+						return
+					}
+					chunk = newChunk()
+				}
+			}
+
+			if len(chunk.records) > 0 {
+				send(chunk, true)
+			}
+		}()
+
+		for range workers {
+			go func() {
+				for chunk := range jobs {
+					chunk.results = make([]Result[T], 0, len(chunk.records))
+					for i, record := range chunk.records {
+						lineNum := chunk.firstLineNum + i
+						data, err := fromFunc(record, headers)
+						if err != nil {
+							chunk.results = append(chunk.results, Result[T]{
+								LineNum: lineNum,
+								Error:   fmt.Errorf("failed to parse CSV line %d: %w", lineNum, err),
+							})
+							break
+						}
+						chunk.results = append(chunk.results, Result[T]{Value: data, LineNum: lineNum})
+					}
+					chunk.records = nil
+					close(chunk.ready)
+				}
+			}()
+		}
+
+		for chunk := range ordered {
+			<-chunk.ready
+			for _, result := range chunk.results {
+				if !yield(result) || result.Error != nil {
+					return
+				}
+			}
+		}
+	}
+}
@@ -143,3 +143,82 @@ func TestParseCSVFromFuncError(t *testing.T) {
 	require.Len(t, resultsSlice, 1, "expected one result")
 	assert.Error(t, resultsSlice[0].Error, "expected an error from fromFunc")
 }
+
+func TestParseCSVConcurrentlyPreservesOrder(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("name,age\n")
+	numRecords := csvChunkSize*5 + 17
+	for i := range numRecords {
+		fmt.Fprintf(&sb, "\"Person %d\",%d\n", i, i)
+	}
+
+	results := ParseCSVConcurrently(strings.NewReader(sb.String()), true, 4, fromFunc)
+
+	var actual []Result[testData]
+	for result := range results {
+		actual = append(actual, result)
+	}
+
+	require.Len(t, actual, numRecords)
+	for i, result := range actual {
+		require.NoError(t, result.Error)
+		assert.Equal(t, testData{Name: fmt.Sprintf("Person %d", i), Age: i}, result.Value)
+		assert.Equal(t, i+1, result.LineNum)
+	}
+}
+
+func TestParseCSVConcurrentlyStopsAtFirstError(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("name,age\n")
+	for i := range csvChunkSize * 3 {
+		if i == csvChunkSize+10 {
+			sb.WriteString("\"Bad Age\",abc\n")
+			continue
+		}
+		fmt.Fprintf(&sb, "\"Person %d\",%d\n", i, i)
+	}
+
+	results := ParseCSVConcurrently(strings.NewReader(sb.String()), true, 4, fromFunc)
+
+	var actual []Result[testData]
+	for result := range results {
+		actual = append(actual, result)
+	}
+
+	require.Len(t, actual, csvChunkSize+11)
+	last := actual[len(actual)-1]
+	assert.Error(t, last.Error)
+	assert.Equal(t, csvChunkSize+11, last.LineNum)
+}
+
+func TestParseCSVConcurrentlyMalformed(t *testing.T) {
+	csvData := `name,age
+"John Doe",30
+"Jane Doe",25,extra
+`
+	results := ParseCSVConcurrently(strings.NewReader(csvData), true, 2, fromFunc)
+
+	var resultsSlice []Result[testData]
+	for result := range results {
+		resultsSlice = append(resultsSlice, result)
+	}
+	require.Len(t, resultsSlice, 2, "expected two results")
+	assert.NoError(t, resultsSlice[0].Error, "first result should not have an error")
+	assert.Error(t, resultsSlice[1].Error, "second result should have an error")
+}
+
+func TestParseCSVConcurrentlyEarlyBreak(t *testing.T) {
+	var sb strings.Builder
+	for i := range csvChunkSize * 10 {
+		fmt.Fprintf(&sb, "\"Person %d\",%d\n", i, i)
+	}
+
+	count := 0
+	for range ParseCSVConcurrently(strings.NewReader(sb.String()), false, 4, fromFunc) {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	assert.Equal(t, 3, count)
+}
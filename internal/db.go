@@ -3,8 +3,10 @@ package internal
 import (
 	"database/sql"
 	_ "embed"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"strings"
 )
 
@@ -14,15 +16,221 @@ var migrationSQL string
 //go:embed sql/insert_code_point.sql
 var InsertCodePointSQL string
 
+//go:embed sql/insert_onspd_postcode.sql
+var InsertONSPDPostcodeSQL string
+
+//go:embed sql/insert_code_point_area.sql
+var InsertCodePointAreaSQL string
+
 //go:embed sql/insert_company_data.sql
 var InsertCompanyDataSQL string
 
+//go:embed sql/insert_company_change.sql
+var InsertCompanyChangeSQL string
+
+//go:embed sql/insert_removed_company_changes.sql
+var InsertRemovedCompanyChangesSQL string
+
+//go:embed sql/rebuild_code_point_rtree.sql
+var RebuildCodePointRTreeSQL string
+
+//go:embed sql/locate_companies.sql
+var LocateCompaniesSQL string
+
+//go:embed sql/relocate_companies.sql
+var RelocateCompaniesSQL string
+
+//go:embed sql/locate_companies_by_centroid.sql
+var LocateCompaniesByCentroidSQL string
+
+//go:embed sql/locate_company_changes.sql
+var LocateCompanyChangesSQL string
+
+//go:embed sql/backfill_company_change_locations.sql
+var backfillCompanyChangeLocationsSQL string
+
+//go:embed sql/rebuild_postcode_centroids.sql
+var RebuildPostcodeCentroidsSQL string
+
+//go:embed sql/record_dataset_import.sql
+var RecordDatasetImportSQL string
+
 //go:embed sql/search.sql
 var SearchSQL string
 
+//go:embed sql/search_by_code_point.sql
+var SearchByCodePointSQL string
+
+//go:embed sql/search_without_index.sql
+var SearchWithoutIndexSQL string
+
+//go:embed sql/search_by_area.sql
+var SearchByAreaSQL string
+
+//go:embed sql/load_companies.sql
+var LoadCompaniesSQL string
+
+//go:embed sql/changes.sql
+var ChangesSQL string
+
+//go:embed sql/timeline.sql
+var TimelineSQL string
+
+type column struct {
+	table      string
+	name       string
+	definition string
+}
+
+// addedColumns lists columns introduced after a table was first created.
+// CREATE TABLE IF NOT EXISTS leaves existing tables untouched, so databases
+// built by earlier versions are brought up to date with ALTER TABLE.
+var addedColumns = []column{
+	{"code_point", "positional_quality", "NUMERIC NOT NULL DEFAULT 0"},
+	{"code_point", "country_code", "TEXT NOT NULL DEFAULT ''"},
+	{"code_point", "nhs_region_code", "TEXT NOT NULL DEFAULT ''"},
+	{"code_point", "nhs_ha_code", "TEXT NOT NULL DEFAULT ''"},
+	{"code_point", "county_code", "TEXT NOT NULL DEFAULT ''"},
+	{"code_point", "district_code", "TEXT NOT NULL DEFAULT ''"},
+	{"code_point", "ward_code", "TEXT NOT NULL DEFAULT ''"},
+	{"code_point", "morton_key", "INTEGER"},
+	{"code_point", "source", "TEXT NOT NULL DEFAULT 'codepoint'"},
+	{"company_data", "easting", "INTEGER"},
+	{"company_data", "northing", "INTEGER"},
+	{"company_data", "morton_key", "INTEGER"},
+	{"company_data", "location_precision", "TEXT"},
+	{"company_changes", "easting", "INTEGER"},
+	{"company_changes", "northing", "INTEGER"},
+	{"company_changes", "previous_easting", "INTEGER"},
+	{"company_changes", "previous_northing", "INTEGER"},
+}
+
 func CreateDB(db *sql.DB) error {
-	_, err := db.Exec(migrationSQL)
-	return err
+	added, err := addMissingColumns(db)
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(migrationSQL); err != nil {
+		return err
+	}
+	if err := buildMissingCodePointIndex(db); err != nil {
+		return err
+	}
+	if err := addMissingMortonKeys(db, "code_point", "post_code"); err != nil {
+		return err
+	}
+	if err := buildMissingPostcodeCentroids(db); err != nil {
+		return err
+	}
+	// Imports locate the companies they write, so this is only needed once,
+	// for companies imported before they had locations.
+	if added["company_data.morton_key"] {
+		slog.Info("Locating companies imported before they had locations")
+		if err := LocateCompanies(db); err != nil {
+			return err
+		}
+	}
+	if added["company_changes.easting"] {
+		slog.Info("Locating changes recorded before they had locations")
+		if _, err := db.Exec(backfillCompanyChangeLocationsSQL); err != nil {
+			return fmt.Errorf("failed to locate company changes: %w", err)
+		}
+	}
+	return nil
+}
+
+// buildMissingCodePointIndex builds the spatial index for postcodes imported
+// before it was introduced.
+func buildMissingCodePointIndex(db *sql.DB) error {
+	var missing bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM code_point) AND NOT EXISTS (SELECT 1 FROM code_point_rtree)").Scan(&missing)
+	if err != nil {
+		return fmt.Errorf("failed to check the code_point spatial index: %w", err)
+	}
+	if !missing {
+		return nil
+	}
+	slog.Info("Building the code_point spatial index")
+	return RebuildCodePointIndex(db)
+}
+
+// RebuildCodePointIndex replaces the contents of the code_point_rtree spatial
+// index with the current postcodes.
+func RebuildCodePointIndex(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.Exec(RebuildCodePointRTreeSQL); err != nil {
+		return fmt.Errorf("failed to rebuild the code_point spatial index: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit the code_point spatial index: %w", err)
+	}
+	return nil
+}
+
+// addMissingColumns adds the columns missing from existing tables, returning
+// them as "table.column".
+func addMissingColumns(db *sql.DB) (map[string]bool, error) {
+	added := make(map[string]bool)
+	existing := make(map[string]map[string]bool)
+	for _, col := range addedColumns {
+		columns, ok := existing[col.table]
+		if !ok {
+			var err error
+			if columns, err = tableColumns(db, col.table); err != nil {
+				return nil, err
+			}
+			existing[col.table] = columns
+		}
+
+		// A table that doesn't exist yet will be created in full by the migration.
+		if len(columns) == 0 || columns[col.name] {
+			continue
+		}
+
+		slog.Info("Adding column", "table", col.table, "column", col.name)
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.table, col.name, col.definition)); err != nil {
+			return nil, fmt.Errorf("failed to add column %s.%s: %w", col.table, col.name, err)
+		}
+		columns[col.name] = true
+		added[col.table+"."+col.name] = true
+	}
+	return added, nil
+}
+
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %q: %w", table, err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("error closing rows", "error", err)
+		}
+	}()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    bool
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return nil, fmt.Errorf("failed to scan columns of %q: %w", table, err)
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
 }
 
 func Connect(dbPath string) (*sql.DB, error) {
@@ -50,3 +258,36 @@ func Connect(dbPath string) (*sql.DB, error) {
 	}
 	return db, nil
 }
+
+// ConnectReadOnly opens an existing database without modifying it in any way:
+// no migrations are run. If there is no database at dbPath, an empty
+// in-memory database with the current schema is returned instead.
+func ConnectReadOnly(dbPath string) (*sql.DB, error) {
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		slog.Warn("database does not exist, using an empty in-memory database", "dbPath", dbPath)
+		db, err := sql.Open("sqlite3", "file::memory:")
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		// Each connection would otherwise get its own, empty, database.
+		db.SetMaxOpenConns(1)
+		if err := CreateDB(db); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("failed to create database: %w", err)
+		}
+		return db, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to stat database: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?mode=ro&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err = db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	slog.Info("connected to database (read-only)", "dbPath", dbPath)
+	return db, nil
+}
@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateDBAddsMissingColumns(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "companies_data.db")
+
+	// Simulate a database created before the full CodePoint columns were imported
+	old, err := sql.Open("sqlite3", dbPath)
+	require.NoError(t, err)
+	_, err = old.Exec(`
+		CREATE TABLE code_point (post_code TEXT NOT NULL PRIMARY KEY, easting NUMERIC NOT NULL, northing NUMERIC NOT NULL);
+		INSERT INTO code_point VALUES ('AB12 3CD', 300000, 700000);`)
+	require.NoError(t, err)
+	require.NoError(t, old.Close())
+
+	db, err := Connect(dbPath)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, db.Close())
+	}()
+
+	columns, err := tableColumns(db, "code_point")
+	require.NoError(t, err)
+	for _, col := range addedColumns {
+		if col.table == "code_point" {
+			assert.True(t, columns[col.name], "expected column %s to have been added", col.name)
+		}
+	}
+
+	var quality int
+	var districtCode string
+	require.NoError(t, db.QueryRow("SELECT positional_quality, district_code FROM code_point WHERE post_code = 'AB12 3CD'").Scan(&quality, &districtCode))
+	assert.Equal(t, 0, quality)
+	assert.Equal(t, "", districtCode)
+
+	var indexed string
+	require.NoError(t, db.QueryRow("SELECT post_code FROM code_point_rtree WHERE min_easting <= 300000 AND max_easting >= 300000").Scan(&indexed))
+	assert.Equal(t, "AB12 3CD", indexed, "expected the spatial index to have been built")
+
+	var mortonKey int64
+	require.NoError(t, db.QueryRow("SELECT morton_key FROM code_point WHERE post_code = 'AB12 3CD'").Scan(&mortonKey))
+	assert.Equal(t, MortonKey(300000, 700000), mortonKey)
+}
+
+func TestConnectReadOnly(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "companies_data.db")
+	db, err := Connect(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	ro, err := ConnectReadOnly(dbPath)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, ro.Close())
+	}()
+
+	var count int
+	require.NoError(t, ro.QueryRow("SELECT COUNT(*) FROM company_data").Scan(&count))
+	assert.Zero(t, count)
+	_, err = ro.Exec("DELETE FROM company_data")
+	assert.ErrorContains(t, err, "readonly")
+}
+
+func TestConnectReadOnlyMissingDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "missing.db")
+
+	db, err := ConnectReadOnly(dbPath)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, db.Close())
+	}()
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM company_data").Scan(&count))
+	assert.Zero(t, count)
+	assert.NoFileExists(t, dbPath)
+}
+
+func TestConnectLocatesCompaniesOnlyWhenAddingLocations(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "companies_data.db")
+	reconnect := func(statements ...string) *sql.DB {
+		db, err := Connect(dbPath)
+		require.NoError(t, err)
+		for _, statement := range statements {
+			_, err := db.Exec(statement)
+			require.NoError(t, err, statement)
+		}
+		require.NoError(t, db.Close())
+		db, err = Connect(dbPath)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			assert.NoError(t, db.Close())
+		})
+		return db
+	}
+	located := func(db *sql.DB) bool {
+		var located bool
+		require.NoError(t, db.QueryRow("SELECT morton_key IS NOT NULL FROM company_data").Scan(&located))
+		return located
+	}
+
+	// Imported before companies had locations.
+	db := reconnect(
+		"INSERT INTO code_point (post_code, positional_quality, easting, northing, morton_key) VALUES ('AB12 3CD', 10, 300000, 700000, 0)",
+		`INSERT INTO company_data (company_name, company_number, reg_address_address_line_1, reg_address_post_town,
+			reg_address_post_code, company_category, company_status, incorporation_date, accounts_account_ref_day,
+			accounts_account_ref_month, mortgages_num_charges, mortgages_num_outstanding, mortgages_num_part_satisfied,
+			mortgages_num_satisfied, sic_code_1, limited_partnerships_num_gen_partners, limited_partnerships_num_lim_partners, uri)
+		VALUES ('EXAMPLE LIMITED', '00000001', '1 High Street', 'Town', 'AB12 3CD', 'Private Limited Company', 'Active',
+			'2024-01-01', 31, 12, 0, 0, 0, 0, '62020', 0, 0, '')`,
+		"DROP INDEX idx_company_data_location",
+		"ALTER TABLE company_data DROP COLUMN morton_key",
+	)
+	assert.True(t, located(db))
+
+	// Otherwise locating companies is left to the imports, and connecting
+	// writes nothing.
+	db = reconnect("UPDATE company_data SET easting = NULL, northing = NULL, morton_key = NULL")
+	assert.False(t, located(db))
+}
+
+func TestConnectLocatesChangesRecordedBeforeTheyHadLocations(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "companies_data.db")
+	db, err := Connect(dbPath)
+	require.NoError(t, err)
+	for _, statement := range []string{
+		"INSERT INTO code_point (post_code, positional_quality, easting, northing, morton_key) VALUES ('AB12 3CD', 10, 300000, 700000, 0)",
+		"INSERT INTO code_point (post_code, positional_quality, easting, northing, morton_key) VALUES ('AB12 9ZZ', 10, 301000, 701000, 0)",
+		`INSERT INTO company_changes (company_number, change_type, post_code, previous_post_code, detected_at)
+		VALUES ('00000001', 'address_changed', 'AB12 3CD', 'AB12 9ZZ', '2025-09-01')`,
+		`INSERT INTO company_changes (company_number, change_type, post_code, detected_at)
+		VALUES ('00000002', 'renamed', 'ZZ99 9ZZ', '2025-09-01')`,
+		"ALTER TABLE company_changes DROP COLUMN easting",
+		"ALTER TABLE company_changes DROP COLUMN northing",
+		"ALTER TABLE company_changes DROP COLUMN previous_easting",
+		"ALTER TABLE company_changes DROP COLUMN previous_northing",
+	} {
+		_, err := db.Exec(statement)
+		require.NoError(t, err, statement)
+	}
+	require.NoError(t, db.Close())
+
+	db, err = Connect(dbPath)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, db.Close())
+	}()
+	var locations []string
+	rows, err := db.Query("SELECT format('%s %s %s %s', easting, northing, previous_easting, previous_northing) FROM company_changes ORDER BY id")
+	require.NoError(t, err)
+	for rows.Next() {
+		var location string
+		require.NoError(t, rows.Scan(&location))
+		locations = append(locations, location)
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	assert.Equal(t, []string{"300000 700000 301000 701000", "   "}, locations, "the changes take the locations of their postcodes")
+}
@@ -85,7 +85,10 @@ func TransientDownload(uri string, handler func(tmpfile string, header http.Head
 		}
 	}()
 
-	if _, err := io.Copy(tmp, resp.Body); err != nil {
+	progress := StartProgress("download", resp.ContentLength)
+	_, err = io.Copy(tmp, progress.Reader(resp.Body))
+	progress.Done()
+	if err != nil {
 		_ = tmp.Close()
 		return fmt.Errorf("failed to copy response body: %w", err)
 	}
@@ -0,0 +1,184 @@
+package internal
+
+import "math"
+
+// Coordinates are stored on the British National Grid. Sources on other
+// grids or in latitude and longitude are converted through WGS84 using the
+// seven-parameter Helmert transformations and Transverse Mercator formulae in
+// the Ordnance Survey's "A Guide to Coordinate Systems in Great Britain",
+// which are accurate to within a few metres: well within a postcode.
+
+type ellipsoid struct {
+	a, b float64 // semi-major and semi-minor axes, in metres
+}
+
+var (
+	airy1830     = ellipsoid{a: 6377563.396, b: 6356256.909}
+	airyModified = ellipsoid{a: 6377340.189, b: 6356034.447}
+	wgs84        = ellipsoid{a: 6378137.000, b: 6356752.314245}
+)
+
+func (el ellipsoid) eccentricitySquared() float64 {
+	return 1 - (el.b*el.b)/(el.a*el.a)
+}
+
+// toCartesian converts a latitude and longitude, in radians, at zero height
+// to earth-centred cartesian coordinates.
+func (el ellipsoid) toCartesian(lat, lon float64) (x, y, z float64) {
+	e2 := el.eccentricitySquared()
+	nu := el.a / math.Sqrt(1-e2*math.Sin(lat)*math.Sin(lat))
+	return nu * math.Cos(lat) * math.Cos(lon),
+		nu * math.Cos(lat) * math.Sin(lon),
+		(1 - e2) * nu * math.Sin(lat)
+}
+
+func (el ellipsoid) fromCartesian(x, y, z float64) (lat, lon float64) {
+	e2 := el.eccentricitySquared()
+	p := math.Hypot(x, y)
+	lat = math.Atan2(z, p*(1-e2))
+	for range 10 {
+		nu := el.a / math.Sqrt(1-e2*math.Sin(lat)*math.Sin(lat))
+		lat = math.Atan2(z+e2*nu*math.Sin(lat), p)
+	}
+	return lat, math.Atan2(y, x)
+}
+
+// helmert is a seven-parameter transformation from WGS84 to another datum:
+// translations in metres, scale in parts per million and rotations in
+// arcseconds.
+type helmert struct {
+	tx, ty, tz, s, rx, ry, rz float64
+}
+
+var (
+	wgs84ToOSGB36      = helmert{tx: -446.448, ty: 125.157, tz: -542.060, s: 20.4894, rx: -0.1502, ry: -0.2470, rz: -0.8421}
+	wgs84ToIreland1965 = helmert{tx: -482.530, ty: 130.596, tz: -564.557, s: -8.150, rx: 1.042, ry: 0.214, rz: 0.631}
+)
+
+func (h helmert) apply(x, y, z float64) (float64, float64, float64) {
+	const arcsecond = math.Pi / (180 * 3600)
+	s := 1 + h.s*1e-6
+	rx, ry, rz := h.rx*arcsecond, h.ry*arcsecond, h.rz*arcsecond
+	return h.tx + s*x - rz*y + ry*z,
+		h.ty + rz*x + s*y - rx*z,
+		h.tz - ry*x + rx*y + s*z
+}
+
+func (h helmert) inverse() helmert {
+	return helmert{tx: -h.tx, ty: -h.ty, tz: -h.tz, s: -h.s, rx: -h.rx, ry: -h.ry, rz: -h.rz}
+}
+
+// transverseMercator is a grid: an ellipsoid and the Transverse Mercator
+// projection of it, with the scale factor on the central meridian, the true
+// origin in radians and the false origin in metres.
+type transverseMercator struct {
+	ellipsoid
+	f0         float64
+	lat0, lon0 float64
+	e0, n0     float64
+}
+
+var (
+	britishNationalGrid = transverseMercator{ellipsoid: airy1830, f0: 0.9996012717, lat0: radians(49), lon0: radians(-2), e0: 400000, n0: -100000}
+	irishGrid           = transverseMercator{ellipsoid: airyModified, f0: 1.000035, lat0: radians(53.5), lon0: radians(-8), e0: 200000, n0: 250000}
+)
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// meridionalArc is the distance along the central meridian from the true
+// origin to the given latitude, scaled by f0.
+func (tm transverseMercator) meridionalArc(lat float64) float64 {
+	n := (tm.a - tm.b) / (tm.a + tm.b)
+	n2, n3 := n*n, n*n*n
+	dLat, sLat := lat-tm.lat0, lat+tm.lat0
+	return tm.b * tm.f0 * ((1+n+5.0/4*n2+5.0/4*n3)*dLat -
+		(3*n+3*n2+21.0/8*n3)*math.Sin(dLat)*math.Cos(sLat) +
+		(15.0/8*n2+15.0/8*n3)*math.Sin(2*dLat)*math.Cos(2*sLat) -
+		35.0/24*n3*math.Sin(3*dLat)*math.Cos(3*sLat))
+}
+
+// radiiOfCurvature returns the transverse (nu) and meridional (rho) radii of
+// curvature at the given latitude, and eta squared.
+func (tm transverseMercator) radiiOfCurvature(lat float64) (nu, rho, eta2 float64) {
+	e2 := tm.eccentricitySquared()
+	sin2 := math.Sin(lat) * math.Sin(lat)
+	nu = tm.a * tm.f0 / math.Sqrt(1-e2*sin2)
+	rho = tm.a * tm.f0 * (1 - e2) / math.Pow(1-e2*sin2, 1.5)
+	return nu, rho, nu/rho - 1
+}
+
+// toGrid projects a latitude and longitude on the grid's ellipsoid, in
+// radians, to an easting and northing.
+func (tm transverseMercator) toGrid(lat, lon float64) (easting, northing float64) {
+	nu, rho, eta2 := tm.radiiOfCurvature(lat)
+	sin, cos, tan := math.Sin(lat), math.Cos(lat), math.Tan(lat)
+	cos3, cos5 := cos*cos*cos, cos*cos*cos*cos*cos
+	tan2, tan4 := tan*tan, tan*tan*tan*tan
+
+	i := tm.meridionalArc(lat) + tm.n0
+	ii := nu / 2 * sin * cos
+	iii := nu / 24 * sin * cos3 * (5 - tan2 + 9*eta2)
+	iiiA := nu / 720 * sin * cos5 * (61 - 58*tan2 + tan4)
+	iv := nu * cos
+	v := nu / 6 * cos3 * (nu/rho - tan2)
+	vi := nu / 120 * cos5 * (5 - 18*tan2 + tan4 + 14*eta2 - 58*tan2*eta2)
+
+	dLon := lon - tm.lon0
+	dLon2 := dLon * dLon
+	northing = i + ii*dLon2 + iii*dLon2*dLon2 + iiiA*dLon2*dLon2*dLon2
+	easting = tm.e0 + iv*dLon + v*dLon2*dLon + vi*dLon2*dLon2*dLon
+	return easting, northing
+}
+
+// fromGrid returns the latitude and longitude on the grid's ellipsoid, in
+// radians, of an easting and northing.
+func (tm transverseMercator) fromGrid(easting, northing float64) (lat, lon float64) {
+	lat = tm.lat0
+	m := 0.0
+	for {
+		lat += (northing - tm.n0 - m) / (tm.a * tm.f0)
+		m = tm.meridionalArc(lat)
+		if math.Abs(northing-tm.n0-m) < 0.00001 {
+			break
+		}
+	}
+
+	nu, rho, eta2 := tm.radiiOfCurvature(lat)
+	tan, sec := math.Tan(lat), 1/math.Cos(lat)
+	tan2, tan4, tan6 := tan*tan, tan*tan*tan*tan, tan*tan*tan*tan*tan*tan
+	nu3, nu5, nu7 := nu*nu*nu, nu*nu*nu*nu*nu, nu*nu*nu*nu*nu*nu*nu
+
+	vii := tan / (2 * rho * nu)
+	viii := tan / (24 * rho * nu3) * (5 + 3*tan2 + eta2 - 9*tan2*eta2)
+	ix := tan / (720 * rho * nu5) * (61 + 90*tan2 + 45*tan4)
+	x := sec / nu
+	xi := sec / (6 * nu3) * (nu/rho + 2*tan2)
+	xii := sec / (120 * nu5) * (5 + 28*tan2 + 24*tan4)
+	xiiA := sec / (5040 * nu7) * (61 + 662*tan2 + 1320*tan4 + 720*tan6)
+
+	dE := easting - tm.e0
+	dE2 := dE * dE
+	lat = lat - vii*dE2 + viii*dE2*dE2 - ix*dE2*dE2*dE2
+	lon = tm.lon0 + x*dE - xi*dE2*dE + xii*dE2*dE2*dE - xiiA*dE2*dE2*dE2*dE
+	return lat, lon
+}
+
+// WGS84ToBNG converts a WGS84 latitude and longitude, in degrees, to a
+// British National Grid easting and northing.
+func WGS84ToBNG(lat, lon float64) (easting, northing float64) {
+	x, y, z := wgs84.toCartesian(radians(lat), radians(lon))
+	lat, lon = airy1830.fromCartesian(wgs84ToOSGB36.apply(x, y, z))
+	return britishNationalGrid.toGrid(lat, lon)
+}
+
+// IrishGridToBNG converts an Irish Grid easting and northing, as used in
+// Northern Ireland, to the British National Grid, which is extended west to
+// cover it.
+func IrishGridToBNG(easting, northing float64) (float64, float64) {
+	lat, lon := irishGrid.fromGrid(easting, northing)
+	x, y, z := wgs84ToIreland1965.inverse().apply(airyModified.toCartesian(lat, lon))
+	lat, lon = airy1830.fromCartesian(wgs84ToOSGB36.apply(x, y, z))
+	return britishNationalGrid.toGrid(lat, lon)
+}
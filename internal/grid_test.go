@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dms converts degrees, minutes and seconds to radians.
+func dms(degrees, minutes, seconds float64) float64 {
+	return radians(degrees + minutes/60 + seconds/3600)
+}
+
+func TestTransverseMercator(t *testing.T) {
+	// The worked example in "A Guide to Coordinate Systems in Great Britain".
+	easting, northing := britishNationalGrid.toGrid(dms(52, 39, 27.2531), dms(1, 43, 4.5177))
+	assert.InDelta(t, 651409.903, easting, 0.001)
+	assert.InDelta(t, 313177.270, northing, 0.001)
+
+	lat, lon := britishNationalGrid.fromGrid(651409.903, 313177.270)
+	assert.InDelta(t, dms(52, 39, 27.2531), lat, 1e-9)
+	assert.InDelta(t, dms(1, 43, 4.5177), lon, 1e-9)
+}
+
+func TestWGS84ToBNG(t *testing.T) {
+	easting, northing := WGS84ToBNG(52.65798, 1.71605)
+	assert.InDelta(t, 651409, easting, 5)
+	assert.InDelta(t, 313177, northing, 5)
+}
+
+func TestIrishGridToBNG(t *testing.T) {
+	// Belfast City Hall, at Irish grid reference J 338 740.
+	lat, lon := 54.59655, -5.93009
+	irishEasting, irishNorthing := irishGrid.toGrid(airyModified.fromCartesian(
+		wgs84ToIreland1965.apply(wgs84.toCartesian(radians(lat), radians(lon)))))
+	assert.InDelta(t, 333800, irishEasting, 100)
+	assert.InDelta(t, 374000, irishNorthing, 100)
+
+	easting, northing := IrishGridToBNG(irishEasting, irishNorthing)
+	expectedEasting, expectedNorthing := WGS84ToBNG(lat, lon)
+	assert.InDelta(t, expectedEasting, easting, 1)
+	assert.InDelta(t, expectedNorthing, northing, 1)
+}
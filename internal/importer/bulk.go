@@ -0,0 +1,189 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// SQLite limits the number of bound parameters in a single statement
+// (SQLITE_MAX_VARIABLE_NUMBER, 32766 since 3.32.0).
+const maxSqliteVariables = 32766
+
+// Beyond a few hundred rows, larger statements cost more to prepare and bind
+// than they save in per-statement overhead.
+const maxRowsPerInsert = 250
+
+// bulkLoad switches a database into an import-optimised mode: durability is
+// relaxed, the page cache enlarged, the database locked exclusively and the
+// secondary indexes on the target table dropped, so they can be rebuilt once
+// in one pass rather than maintained row by row.
+type bulkLoad struct {
+	db          *sql.DB
+	table       string
+	synchronous int
+	cacheSize   int
+	// maxOpenConns is the pool's connection limit before the import, where
+	// zero means unlimited.
+	maxOpenConns int
+	indexes      []string
+	finished     bool
+}
+
+func beginBulkLoad(db *sql.DB, table string) (*bulkLoad, error) {
+	// PRAGMAs are per-connection, so pin the pool to a single connection
+	// for the lifetime of the import.
+	bulk := &bulkLoad{db: db, table: table, maxOpenConns: db.Stats().MaxOpenConnections}
+	db.SetMaxOpenConns(1)
+
+	names, ddls, err := bulk.readSettings()
+	if err != nil {
+		db.SetMaxOpenConns(bulk.maxOpenConns)
+		return nil, err
+	}
+
+	for _, pragma := range []string{
+		"PRAGMA synchronous=OFF",
+		"PRAGMA cache_size=-262144", // 256 MiB
+		"PRAGMA temp_store=MEMORY",
+		"PRAGMA locking_mode=EXCLUSIVE",
+	} {
+		if _, err := db.Exec(pragma); err != nil {
+			bulk.abort()
+			return nil, fmt.Errorf("failed to execute %q: %w", pragma, err)
+		}
+	}
+
+	for i, name := range names {
+		slog.Info("Dropping index for bulk load", "index", name)
+		if _, err := db.Exec(fmt.Sprintf("DROP INDEX IF EXISTS %s", name)); err != nil {
+			bulk.abort()
+			return nil, fmt.Errorf("failed to drop index %q: %w", name, err)
+		}
+		bulk.indexes = append(bulk.indexes, ddls[i])
+	}
+
+	return bulk, nil
+}
+
+// readSettings reads the settings the bulk load changes, and the names and
+// definitions of the indexes on its table.
+func (bulk *bulkLoad) readSettings() ([]string, []string, error) {
+	if err := bulk.db.QueryRow("PRAGMA synchronous").Scan(&bulk.synchronous); err != nil {
+		return nil, nil, fmt.Errorf("failed to read synchronous pragma: %w", err)
+	}
+	if err := bulk.db.QueryRow("PRAGMA cache_size").Scan(&bulk.cacheSize); err != nil {
+		return nil, nil, fmt.Errorf("failed to read cache_size pragma: %w", err)
+	}
+
+	rows, err := bulk.db.Query("SELECT name, sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", bulk.table)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list indexes on %q: %w", bulk.table, err)
+	}
+	var names, ddls []string
+	for rows.Next() {
+		var name, ddl string
+		if err := rows.Scan(&name, &ddl); err != nil {
+			_ = rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan index definition: %w", err)
+		}
+		names = append(names, name)
+		ddls = append(ddls, ddl)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to list indexes on %q: %w", bulk.table, err)
+	}
+	return names, ddls, nil
+}
+
+// end rebuilds the dropped indexes and restores the connection settings,
+// including the pool's connection limit, which is restored even if the rest
+// fails.
+func (bulk *bulkLoad) end() error {
+	if bulk == nil || bulk.finished {
+		return nil
+	}
+	bulk.finished = true
+	defer bulk.db.SetMaxOpenConns(bulk.maxOpenConns)
+
+	for _, ddl := range bulk.indexes {
+		slog.Info("Rebuilding index after bulk load", "sql", ddl)
+		if _, err := bulk.db.Exec(ddl); err != nil {
+			return fmt.Errorf("failed to rebuild index: %w", err)
+		}
+	}
+
+	for _, pragma := range []string{
+		fmt.Sprintf("PRAGMA synchronous=%d", bulk.synchronous),
+		fmt.Sprintf("PRAGMA cache_size=%d", bulk.cacheSize),
+		"PRAGMA temp_store=DEFAULT",
+		"PRAGMA locking_mode=NORMAL",
+		// The exclusive lock is only released on the next access to the database.
+		"SELECT 1 FROM sqlite_master LIMIT 1",
+	} {
+		if _, err := bulk.db.Exec(pragma); err != nil {
+			return fmt.Errorf("failed to execute %q: %w", pragma, err)
+		}
+	}
+	return nil
+}
+
+// abort restores safe settings if the import fails part way through.
+func (bulk *bulkLoad) abort() {
+	if err := bulk.end(); err != nil {
+		slog.Error("failed to restore database after bulk load", "table", bulk.table, "error", err)
+	}
+}
+
+// multiRowInsertSQL expands a single-row "INSERT ... VALUES (?,...)" statement
+// into one that inserts the given number of rows at once, keeping any upsert
+// clause that follows the values.
+func multiRowInsertSQL(insertSQL string, rows int) string {
+	idx := strings.LastIndex(insertSQL, "VALUES")
+	values := strings.TrimSpace(insertSQL[idx+len("VALUES"):])
+	end := strings.Index(values, ")") + 1
+	placeholders, upsert := values[:end], values[end:]
+	return insertSQL[:idx] + "VALUES " + strings.Repeat(placeholders+",", rows-1) + placeholders + upsert
+}
+
+// insertMultiRow writes the tuples using multi-row INSERT statements, keeping
+// within SQLite's bound-parameter limit.
+func insertMultiRow(tx *sql.Tx, insertSQL string, tuples [][]any) error {
+	if len(tuples) == 0 {
+		return nil
+	}
+
+	rowsPerStmt := min(maxRowsPerInsert, max(1, maxSqliteVariables/len(tuples[0])))
+	stmts := make(map[int]*sql.Stmt)
+	defer func() {
+		for _, stmt := range stmts {
+			if err := stmt.Close(); err != nil {
+				slog.Error("failed to close statement", "error", err)
+			}
+		}
+	}()
+
+	args := make([]any, 0, rowsPerStmt*len(tuples[0]))
+	for start := 0; start < len(tuples); start += rowsPerStmt {
+		chunk := tuples[start:min(start+rowsPerStmt, len(tuples))]
+
+		stmt, ok := stmts[len(chunk)]
+		if !ok {
+			var err error
+			if stmt, err = tx.Prepare(multiRowInsertSQL(insertSQL, len(chunk))); err != nil {
+				return fmt.Errorf("failed to prepare statement: %w", err)
+			}
+			stmts[len(chunk)] = stmt
+		}
+
+		args = args[:0]
+		for _, tuple := range chunk {
+			args = append(args, tuple...)
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return fmt.Errorf("failed to execute multi-row insert: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package importer
+
+import (
+	"database/sql"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/map-services/company-data-api/internal"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiRowInsertSQL(t *testing.T) {
+	actual := multiRowInsertSQL(internal.InsertCodePointAreaSQL, 3)
+	expected := "INSERT OR REPLACE INTO code_point_area (code, name, area_type) VALUES (?,?,?),(?,?,?),(?,?,?)"
+	assert.Equal(t, expected, actual)
+
+	actual = multiRowInsertSQL("INSERT INTO t (a, b) VALUES (?,'x')\nON CONFLICT (a) DO UPDATE SET b = excluded.b", 2)
+	expected = "INSERT INTO t (a, b) VALUES (?,'x'),(?,'x')\nON CONFLICT (a) DO UPDATE SET b = excluded.b"
+	assert.Equal(t, expected, actual)
+}
+
+func connectTestDB(t testing.TB) *sql.DB {
+	t.Helper()
+	db, err := internal.Connect(filepath.Join(t.TempDir(), "companies_data.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+	return db
+}
+
+func TestBulkLoadCompanyData(t *testing.T) {
+	db := connectTestDB(t)
+	db.SetMaxOpenConns(4)
+
+	var synchronous int
+	require.NoError(t, db.QueryRow("PRAGMA synchronous").Scan(&synchronous))
+
+	numRecords := 2500
+	zipPath := createTestZip(t, numRecords)
+	defer func() {
+		assert.NoError(t, os.Remove(zipPath))
+	}()
+
+	companyData := NewCompanyDataImporter(db, WithBulkLoad(true), WithBatchSize(1000))
+	require.NoError(t, companyData.Import(zipPath, http.Header{}))
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM company_data").Scan(&count))
+	assert.Equal(t, numRecords, count)
+
+	var index string
+	require.NoError(t, db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'index' AND name = 'idx_company_data_reg_address_post_code'").Scan(&index))
+
+	var restored int
+	require.NoError(t, db.QueryRow("PRAGMA synchronous").Scan(&restored))
+	assert.Equal(t, synchronous, restored)
+	assert.Equal(t, 4, db.Stats().MaxOpenConnections)
+}
+
+func TestBulkLoadAbortRestoresConnectionLimit(t *testing.T) {
+	db := connectTestDB(t)
+
+	bulk, err := beginBulkLoad(db, "company_data")
+	require.NoError(t, err)
+	assert.Equal(t, 1, db.Stats().MaxOpenConnections)
+	bulk.abort()
+	assert.Equal(t, 0, db.Stats().MaxOpenConnections, "unlimited, as before")
+
+	var index string
+	require.NoError(t, db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'index' AND name = 'idx_company_data_reg_address_post_code'").Scan(&index))
+}
+
+func BenchmarkCompanyDataImport(b *testing.B) {
+	const numRecords = 20000
+	zipPath := createTestZip(b, numRecords)
+	defer func() {
+		assert.NoError(b, os.Remove(zipPath))
+	}()
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	benchmarks := map[string][]Option{
+		"row-by-row": nil,
+		"bulk-load":  {WithBulkLoad(true)},
+	}
+
+	for name, opts := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			for b.Loop() {
+				b.StopTimer()
+				db := connectTestDB(b)
+				b.StartTimer()
+
+				if err := NewCompanyDataImporter(db, opts...).Import(zipPath, http.Header{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(numRecords*b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
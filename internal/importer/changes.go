@@ -0,0 +1,68 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// changeFeed records how an import differs from the one before it, so that
+// downstream systems can sync incrementally rather than reloading everything.
+type changeFeed[T any] struct {
+	// detect compares a batch with the rows it is about to replace, within
+	// the batch's transaction.
+	detect func(tx *sql.Tx, batch []T, detectedAt time.Time) error
+	// removed records the rows a full refresh is about to delete: those whose
+	// keys are not in seenTable.
+	removed func(tx *sql.Tx, seenTable string, detectedAt time.Time) error
+}
+
+// beginChanges starts recording changes, unless the table is empty: an
+// initial load would otherwise record every row as new.
+func (importer *csvImporter[T]) beginChanges() error {
+	dataset := importer.dataset
+
+	var populated bool
+	if err := importer.db.QueryRow(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s)", dataset.table)).Scan(&populated); err != nil {
+		return fmt.Errorf("failed to check for existing rows in %q: %w", dataset.table, err)
+	}
+	if !populated {
+		slog.Info("No existing rows, so changes will not be recorded for this import", "table", dataset.table)
+		return nil
+	}
+
+	// Every change from one import shares a timestamp, so an import is a
+	// single step in the feed.
+	importer.changedAt = time.Now().UTC().Truncate(time.Second)
+	if importer.stale != nil {
+		importer.stale.beforeRemove = func(tx *sql.Tx) error {
+			return dataset.changes.removed(tx, importer.stale.seenTable, importer.changedAt)
+		}
+	}
+	return nil
+}
+
+// insertChanges writes the given change rows with a single prepared statement.
+func insertChanges(tx *sql.Tx, insertSQL string, changes [][]any) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(insertSQL)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			slog.Error("failed to close statement", "error", err)
+		}
+	}()
+
+	for _, change := range changes {
+		if _, err := stmt.Exec(change...); err != nil {
+			return fmt.Errorf("failed to record change: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package importer
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// companyRecord returns a Companies House CSV record for a company at the
+// given address line and postcode.
+func companyRecord(number string, name string, line1 string, postcode string, status string, dissolved string) []string {
+	record := make([]string, len(companyDataColumns))
+	record[0] = name
+	record[1] = number
+	record[4] = line1
+	record[6] = "Aberdeen"
+	record[9] = postcode
+	record[10] = "Private Limited Company"
+	record[11] = status
+	record[13] = dissolved
+	record[14] = "01/01/2020"
+	return record
+}
+
+// withAccounts sets the SIC codes and accounts made-up date of a company record.
+func withAccounts(record []string, lastMadeUp string, sicCodes ...string) []string {
+	record[18] = lastMadeUp
+	copy(record[26:30], sicCodes)
+	return record
+}
+
+func companyChanges(t *testing.T, importer *csvImporter[models.CompanyData]) []models.CompanyChange {
+	t.Helper()
+	rows, err := importer.db.Query(`
+		SELECT company_number, change_type, old_value, new_value, post_code, previous_post_code, detected_at
+		FROM company_changes ORDER BY id`)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, rows.Close())
+	}()
+
+	var changes []models.CompanyChange
+	for rows.Next() {
+		var change models.CompanyChange
+		require.NoError(t, rows.Scan(&change.CompanyNumber, &change.ChangeType, &change.OldValue, &change.NewValue,
+			&change.PostCode, &change.PreviousPostCode, &change.DetectedAt))
+		changes = append(changes, change)
+	}
+	require.NoError(t, rows.Err())
+	return changes
+}
+
+func TestImportCompanyDataRecordsChanges(t *testing.T) {
+	db := connectTestDB(t)
+
+	initial := writeCompanyRecords(t, [][]string{
+		companyRecord("00000001", "ONE LIMITED", "1 High Street", "AB10 1AB", "Active", ""),
+		companyRecord("00000002", "TWO LIMITED", "2 High Street", "AB10 1AB", "Active", ""),
+		companyRecord("00000003", "THREE LIMITED", "3 High Street", "AB10 1AB", "Active", ""),
+		companyRecord("00000005", "FIVE LIMITED", "5 High Street", "AB10 1AB", "Active", ""),
+		companyRecord("00000006", "SIX LIMITED", "6 High Street", "AB10 1AB", "Active", ""),
+		withAccounts(companyRecord("00000007", "SEVEN LIMITED", "7 High Street", "AB10 1AB", "Active", ""),
+			"31/12/2023", "62020 - Information technology consultancy activities"),
+	})
+	importer := NewCompanyDataImporter(db, WithFullRefresh(true))
+	require.NoError(t, importer.Import(initial, http.Header{}))
+	assert.Empty(t, companyChanges(t, importer), "the initial load should not be recorded")
+
+	next := writeCompanyRecords(t, [][]string{
+		companyRecord("00000001", "ONE RENAMED LIMITED", "1 High Street", "AB10 1AB", "Active", ""),
+		companyRecord("00000002", "TWO LIMITED", "9 Union Street", "AB11 6BA", "Active", ""),
+		companyRecord("00000003", "THREE LIMITED", "3 High Street", "AB10 1AB", "Liquidation", ""),
+		companyRecord("00000004", "FOUR LIMITED", "4 High Street", "AB10 1AB", "Active", ""),
+		companyRecord("00000005", "FIVE LIMITED", "5 High Street", "AB10 1AB", "Dissolved", "01/06/2025"),
+		withAccounts(companyRecord("00000007", "SEVEN LIMITED", "7 High Street", "AB10 1AB", "Active", ""),
+			"31/12/2024", "62012 - Business and domestic software development", "62020 - Information technology consultancy activities"),
+	})
+	importer = NewCompanyDataImporter(db, WithFullRefresh(true))
+	require.NoError(t, importer.Import(next, http.Header{}))
+
+	changes := companyChanges(t, importer)
+	require.Len(t, changes, 8)
+	detectedAt := changes[0].DetectedAt
+	assert.False(t, detectedAt.IsZero())
+	for i := range changes {
+		assert.True(t, detectedAt.Equal(changes[i].DetectedAt), "changes from one import share a timestamp")
+		changes[i].DetectedAt = detectedAt
+	}
+
+	change := func(number string, changeType string, oldValue string, newValue string, postCode string, previousPostCode string) models.CompanyChange {
+		return models.CompanyChange{
+			CompanyNumber:    number,
+			ChangeType:       changeType,
+			OldValue:         oldValue,
+			NewValue:         newValue,
+			PostCode:         postCode,
+			PreviousPostCode: previousPostCode,
+			DetectedAt:       detectedAt,
+		}
+	}
+	assert.Equal(t, []models.CompanyChange{
+		change("00000001", models.ChangeRenamed, "ONE LIMITED", "ONE RENAMED LIMITED", "AB10 1AB", ""),
+		change("00000002", models.ChangeAddressChanged, "2 High Street, Aberdeen, AB10 1AB", "9 Union Street, Aberdeen, AB11 6BA", "AB11 6BA", "AB10 1AB"),
+		change("00000003", models.ChangeStatusChanged, "Active", "Liquidation", "AB10 1AB", ""),
+		change("00000004", models.ChangeIncorporated, "", "FOUR LIMITED", "AB10 1AB", ""),
+		change("00000005", models.ChangeDissolved, "Active", "Dissolved", "AB10 1AB", ""),
+		change("00000007", models.ChangeSICChanged,
+			"62020 - Information technology consultancy activities",
+			"62012 - Business and domestic software development; 62020 - Information technology consultancy activities",
+			"AB10 1AB", ""),
+		change("00000007", models.ChangeAccountsFiled, "2023-12-31", "2024-12-31", "AB10 1AB", ""),
+		change("00000006", models.ChangeRemoved, "Active", "", "AB10 1AB", ""),
+	}, changes)
+
+	// Importing the same snapshot again changes nothing.
+	importer = NewCompanyDataImporter(db, WithFullRefresh(true), WithBulkLoad(true))
+	require.NoError(t, importer.Import(next, http.Header{}))
+	assert.Len(t, companyChanges(t, importer), 8)
+}
+
+func TestDryRunDoesNotRecordChanges(t *testing.T) {
+	db := connectTestDB(t)
+	importer := NewCompanyDataImporter(db)
+	require.NoError(t, importer.Import(writeCompanyRecords(t, [][]string{
+		companyRecord("00000001", "ONE LIMITED", "1 High Street", "AB10 1AB", "Active", ""),
+	}), http.Header{}))
+
+	renamed := writeCompanyRecords(t, [][]string{
+		companyRecord("00000001", "ONE RENAMED LIMITED", "1 High Street", "AB10 1AB", "Active", ""),
+	})
+	require.NoError(t, NewCompanyDataImporter(db, WithDryRun(io.Discard)).Import(renamed, http.Header{}))
+	assert.Empty(t, companyChanges(t, importer))
+}
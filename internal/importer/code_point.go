@@ -1,137 +1,319 @@
 package importer
 
 import (
-	"archive/zip"
 	"database/sql"
+	"encoding/csv"
 	"fmt"
 	"log/slog"
-	"net/http"
+	"path"
 	"strings"
 
 	"github.com/map-services/company-data-api/internal"
 )
 
 type CodePoint struct {
-	PostCode string `json:"post_code"`
-	Easting  int    `json:"easting"`
-	Northing int    `json:"northing"`
+	PostCode          string `json:"post_code"`
+	PositionalQuality int    `json:"positional_quality"`
+	Easting           int    `json:"easting"`
+	Northing          int    `json:"northing"`
+	CountryCode       string `json:"country_code"`
+	NHSRegionCode     string `json:"nhs_region_code"`
+	NHSHACode         string `json:"nhs_ha_code"`
+	CountyCode        string `json:"county_code"`
+	DistrictCode      string `json:"district_code"`
+	WardCode          string `json:"ward_code"`
 }
 
+// CodePointArea maps an administrative area code, as used in the CodePoint
+// data files, to its name.
+type CodePointArea struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	AreaType string `json:"area_type"`
+}
+
+// The CodePoint data files have no header row; the column names are shipped
+// separately in Doc/Code-Point_Open_Column_Headers.csv, whose first row holds
+// the short names and whose second row holds the long ones.
+var codePointColumns = []string{"PC", "PQ", "EA", "NO", "CY", "RH", "LH", "CC", "DC", "WC"}
+
+var codePointMapping = &columnMapping{
+	expected: codePointColumns,
+	aliases: map[string]string{
+		"Postcode":                     "PC",
+		"Positional_quality_indicator": "PQ",
+		"Eastings":                     "EA",
+		"Northings":                    "NO",
+		"Country_code":                 "CY",
+		"NHS_regional_HA_code":         "RH",
+		"NHS_HA_code":                  "LH",
+		"Admin_county_code":            "CC",
+		"Admin_district_code":          "DC",
+		"Admin_ward_code":              "WC",
+	},
+}
+
+var codePointDataset = &csvDataset[CodePoint]{
+	name:          "code-point",
+	description:   "Ordnance Survey CodePoint Open postcode locations",
+	defaultSource: "./data/codepo_gb.zip",
+	table:         "code_point",
+	keyColumn:     "post_code",
+	insertSQL:     internal.InsertCodePointSQL,
+	owns:          "source = 'codepoint'",
+	selects: func(name string) bool {
+		return strings.HasPrefix(name, "Data/CSV/")
+	},
+	columns:       codePointMapping,
+	columnHeaders: codePointColumnHeaders,
+	parse:         codePointFromRecord,
+	toTuple:       codePointToTuple,
+	key: func(codePoint CodePoint) string {
+		return codePoint.PostCode
+	},
+	matcher:   codePointMatcher,
+	inspector: newCodePointInspector,
+	prepare:   importCodeLists,
+	finish:    indexCodePoints,
+}
+
+func init() {
+	Register(codePointDataset)
+}
+
+// indexCodePoints rebuilds the spatial index and sector and district
+// centroids of the postcodes, and copies their locations onto the companies
+// registered at them.
+func indexCodePoints(db *sql.DB) error {
+	slog.Info("Rebuilding the code_point spatial index")
+	if err := internal.RebuildCodePointIndex(db); err != nil {
+		return err
+	}
+	if err := internal.RebuildPostcodeCentroids(db); err != nil {
+		return err
+	}
+	return internal.RelocateCompanies(db)
+}
+
+func NewCodePointImporter(db *sql.DB, opts ...Option) *csvImporter[CodePoint] {
+	return codePointDataset.newImporter(db, opts...)
+}
+
+// codePointMatcher selects postcodes by location and postcode area.
+func codePointMatcher(_ *sql.DB, filter Filter) (func(CodePoint) bool, error) {
+	if len(filter.CompanyStatuses) > 0 || len(filter.SICPrefixes) > 0 {
+		slog.Warn("Company status and SIC code filters do not apply to CodePoint data and are ignored")
+	}
+	return func(codePoint CodePoint) bool {
+		return filter.containsPoint(float64(codePoint.Easting), float64(codePoint.Northing)) &&
+			filter.matchesPostcodeArea(codePoint.PostCode)
+	}, nil
+}
+
+// codePointInspector checks CodePoint records for a dry run.
+type codePointInspector struct {
+	quality CodePointQuality
+}
+
+func newCodePointInspector(_ *sql.DB) (recordInspector[CodePoint], error) {
+	return &codePointInspector{
+		quality: CodePointQuality{
+			ByPositionalQuality: make(map[int]int),
+			ByCountry:           make(map[string]int),
+		},
+	}, nil
+}
+
+func (inspector *codePointInspector) inspect(codePoint CodePoint) error {
+	quality := &inspector.quality
+	quality.ByPositionalQuality[codePoint.PositionalQuality]++
+	quality.ByCountry[codePoint.CountryCode]++
+
+	if !internal.IsValidPostcode(codePoint.PostCode) {
+		quality.InvalidPostcodes.add(codePoint.PostCode)
+	}
+	// Quality 90 means CodePoint has no coordinates for the postcode.
+	if codePoint.PositionalQuality == 90 || (codePoint.Easting == 0 && codePoint.Northing == 0) {
+		quality.MissingCoordinates.add(codePoint.PostCode)
+	}
+	return nil
+}
+
+func (inspector *codePointInspector) addTo(report *QualityReport) error {
+	report.CodePoints = &inspector.quality
+	return nil
+}
+
+// fromCodePointCSV parses a record against the given headers, or against the
+// documented column order if headers is nil.
 func fromCodePointCSV(record []string, headers []string) (*CodePoint, error) {
-	easting, err := parseInt(record[2])
+	if headers == nil {
+		headers = codePointColumns
+	}
+	return codePointDataset.newRecordParser(headers).parse(record, nil)
+}
+
+func codePointFromRecord(record []string, columns columnIndex) (*CodePoint, error) {
+	field := func(name string) string {
+		return record[columns[name]]
+	}
+
+	positionalQuality, err := parseInt(field("PQ"))
+	if err != nil {
+		return nil, err
+	}
+	easting, err := parseInt(field("EA"))
 	if err != nil {
 		return nil, err
 	}
-	northing, err := parseInt(record[3])
+	northing, err := parseInt(field("NO"))
 	if err != nil {
 		return nil, err
 	}
 
 	return &CodePoint{
-		PostCode: record[0],
-		Easting:  easting,
-		Northing: northing,
+		PostCode:          internal.NormalisePostcode(field("PC")),
+		PositionalQuality: positionalQuality,
+		Easting:           easting,
+		Northing:          northing,
+		CountryCode:       field("CY"),
+		NHSRegionCode:     field("RH"),
+		NHSHACode:         field("LH"),
+		CountyCode:        field("CC"),
+		DistrictCode:      field("DC"),
+		WardCode:          field("WC"),
 	}, nil
 }
 
+// isColumnHeadersFile reports whether a source file is the file that names the
+// columns of the (headerless) data files.
+func isColumnHeadersFile(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(lower, "doc/") &&
+		strings.HasSuffix(lower, ".csv") &&
+		strings.Contains(lower, "header")
+}
+
+// readColumnHeaders returns the first row of the column headers file, or nil
+// if the source doesn't include one.
+func readColumnHeaders(files []sourceFile) ([]string, error) {
+	for _, f := range files {
+		if !isColumnHeadersFile(f.name) {
+			continue
+		}
+		r, err := f.open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", f.name, err)
+		}
+		defer func() {
+			if err := r.Close(); err != nil {
+				slog.Error("error closing source file", "filename", f.name, "error", err)
+			}
+		}()
+
+		headers, err := csv.NewReader(r).Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read column headers from %s: %w", f.name, err)
+		}
+		return headers, nil
+	}
+	return nil, nil
+}
+
+// codePointColumnHeaders returns the column names from the source's column
+// headers file, falling back to the documented column order.
+func codePointColumnHeaders(files []sourceFile) ([]string, error) {
+	headers, err := readColumnHeaders(files)
+	if err != nil || headers != nil {
+		return headers, err
+	}
+	slog.Warn("No column headers file found, assuming the documented column order", "columns", codePointColumns)
+	return codePointColumns, nil
+}
+
 func codePointToTuple(codePoint CodePoint) []any {
 	return []any{
 		codePoint.PostCode,
+		codePoint.PositionalQuality,
 		codePoint.Easting,
 		codePoint.Northing,
+		codePoint.CountryCode,
+		codePoint.NHSRegionCode,
+		codePoint.NHSHACode,
+		codePoint.CountyCode,
+		codePoint.DistrictCode,
+		codePoint.WardCode,
+		internal.MortonKey(codePoint.Easting, codePoint.Northing),
 	}
 }
 
-type codePointImporter struct {
-	batchSize int
-	db        *sql.DB
+// isCodeListFile reports whether a source file is one of the lookup CSVs in the
+// Doc/ folder that map area codes to names (as opposed to, say, the column
+// headers file).
+func isCodeListFile(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(lower, "doc/") &&
+		strings.HasSuffix(lower, ".csv") &&
+		!strings.Contains(lower, "header")
 }
 
-func NewCodePointImporter(db *sql.DB) *codePointImporter {
-	return &codePointImporter{
-		batchSize: 5000,
-		db:        db,
-	}
+// codeListAreaType derives the area type from the lookup file name, e.g.
+// "Doc/Codelist/DC.csv" becomes "DC".
+func codeListAreaType(name string) string {
+	return strings.TrimSuffix(path.Base(name), path.Ext(name))
 }
 
-func (importer *codePointImporter) Import(zipPath string, _ http.Header) error {
-	r, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return fmt.Errorf("failed to open zip file: %w", err)
-	}
-	defer func() {
-		if err := r.Close(); err != nil {
-			slog.Error("error closing zip file", "error", err)
-		}
-	}()
-
-	totalRecordsImported := 0
-	for _, f := range r.File {
-		if f.FileInfo().IsDir() || !strings.HasPrefix(f.Name, "Data/CSV/") {
+// importCodeLists imports the Doc/ lookup files, which map area codes to names.
+func importCodeLists(db *sql.DB, files []sourceFile) error {
+	for _, f := range files {
+		if !isCodeListFile(f.name) {
 			continue
 		}
-		recordsInFile, err := importer.processCSV(f)
+		areasInFile, err := processCodeList(db, f)
 		if err != nil {
-			return fmt.Errorf("failed to process CSV data: %w", err)
+			return fmt.Errorf("failed to process code list: %w", err)
 		}
-		slog.Info("Processed file", "filename", f.Name, "records", recordsInFile)
-		totalRecordsImported += recordsInFile
-	}
-
-	slog.Info("Completed successfully", "totalRecords", totalRecordsImported)
-	slog.Info("Analyzing \"code_point\" table")
-	if _, err = importer.db.Exec("ANALYZE code_point"); err != nil {
-		return fmt.Errorf("failed to analyze \"code_point\" table: %w", err)
+		slog.Info("Processed code list", "filename", f.name, "areas", areasInFile)
 	}
 	return nil
 }
 
-func (importer *codePointImporter) processCSV(f *zip.File) (int, error) {
-	r, err := f.Open()
+// processCodeList imports one of the Doc/ lookup files, which are headerless
+// CSVs of area name followed by area code.
+func processCodeList(db *sql.DB, f sourceFile) (int, error) {
+	r, err := f.open()
 	if err != nil {
-		return 0, fmt.Errorf("failed to open embedded file %s in zip: %w", f.Name, err)
+		return 0, fmt.Errorf("failed to open %s: %w", f.name, err)
 	}
 	defer func() {
 		if err := r.Close(); err != nil {
-			slog.Error("error closing embedded zip file", "error", err)
+			slog.Error("error closing source file", "filename", f.name, "error", err)
 		}
 	}()
 
-	batch := make([]CodePoint, 0, importer.batchSize)
-	lineNum := 0
-
-	for result := range internal.ParseCSV(r, false, fromCodePointCSV) {
-		lineNum = result.LineNum
-		if result.Error != nil {
-			return 0, fmt.Errorf("error parsing line %d: %w", lineNum, result.Error)
+	areaType := codeListAreaType(f.name)
+	areas := make([]CodePointArea, 0, 1000)
+	for result := range internal.ParseCSV(r, false, func(record []string, _ []string) (CodePointArea, error) {
+		if len(record) < 2 {
+			return CodePointArea{}, fmt.Errorf("expected name and code, got %d fields", len(record))
 		}
-
-		batch = append(batch, *result.Value)
-
-		if len(batch) >= importer.batchSize {
-			if err := importer.insertBatch(batch); err != nil {
-				return 0, fmt.Errorf("failed to insert batch: %w", err)
-			}
-			batch = batch[:0]
+		return CodePointArea{
+			Code:     strings.TrimSpace(record[1]),
+			Name:     strings.TrimSpace(record[0]),
+			AreaType: areaType,
+		}, nil
+	}) {
+		if result.Error != nil {
+			return 0, fmt.Errorf("error parsing line %d: %w", result.LineNum, result.Error)
 		}
-	}
-
-	if len(batch) > 0 {
-		if err := importer.insertBatch(batch); err != nil {
-			return 0, fmt.Errorf("failed to insert batch: %w", err)
+		if result.Value.Code != "" {
+			areas = append(areas, result.Value)
 		}
 	}
-	return lineNum, nil
-}
-
-func (importer *codePointImporter) insertBatch(batch []CodePoint) error {
-	if len(batch) == 0 {
-		return nil
-	}
 
-	tx, err := importer.db.Begin()
+	tx, err := db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer func() {
 		if err != nil {
@@ -141,9 +323,10 @@ func (importer *codePointImporter) insertBatch(batch []CodePoint) error {
 		}
 	}()
 
-	stmt, err := tx.Prepare(internal.InsertCodePointSQL)
+	var stmt *sql.Stmt
+	stmt, err = tx.Prepare(internal.InsertCodePointAreaSQL)
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		return 0, fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer func() {
 		if err := stmt.Close(); err != nil {
@@ -151,15 +334,14 @@ func (importer *codePointImporter) insertBatch(batch []CodePoint) error {
 		}
 	}()
 
-	for _, codePoint := range batch {
-		_, err = stmt.Exec(codePointToTuple(codePoint)...)
-		if err != nil {
-			return fmt.Errorf("failed to execute individual insert: %w", err)
+	for _, area := range areas {
+		if _, err = stmt.Exec(area.Code, area.Name, area.AreaType); err != nil {
+			return 0, fmt.Errorf("failed to insert area %q: %w", area.Code, err)
 		}
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
-	return nil
+	return len(areas), nil
 }
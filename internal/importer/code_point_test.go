@@ -3,12 +3,12 @@ package importer
 import (
 	"archive/zip"
 	"bytes"
+	"database/sql/driver"
 	"encoding/csv"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
-	"strings"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -25,6 +25,22 @@ func setupSlogBuffer() (*bytes.Buffer, *slog.Logger) {
 	return &buf, logger
 }
 
+// expectCodePointIndexRebuild expects the spatial index and centroids to be
+// rebuilt, and companies to be relocated, at the end of a CodePoint import.
+func expectCodePointIndexRebuild(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec(internal.RebuildCodePointRTreeSQL).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(internal.RebuildPostcodeCentroidsSQL).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT code, easting, northing FROM postcode_centroid WHERE morton_key IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"code", "easting", "northing"}))
+	expectLocateCompanies(mock, internal.RelocateCompaniesSQL)
+}
+
 // createTestZipCodePoint creates a temporary zip file with a single CSV file for testing
 func createTestZipCodePoint(t *testing.T, numRecords int) string {
 	t.Helper()
@@ -48,26 +64,46 @@ func createTestZipCodePoint(t *testing.T, numRecords int) string {
 	defer csvWriter.Flush()
 
 	for i := range numRecords {
-		record := make([]string, 4)
-		record[0] = fmt.Sprintf("AB12 3CD%d", i) // Postcode
-		record[1] = "1"                          // Quality
-		record[2] = fmt.Sprintf("%d", 300000+i)  // Easting
-		record[3] = fmt.Sprintf("%d", 700000+i)  // Northing
-		// Fill other fields with dummy data if necessary, or leave empty
+		record := []string{
+			fmt.Sprintf("AB12 3CD%d", i), // Postcode
+			"10",                         // Positional quality indicator
+			fmt.Sprintf("%d", 300000+i),  // Easting
+			fmt.Sprintf("%d", 700000+i),  // Northing
+			"S92000003",                  // Country code
+			"",                           // NHS regional HA code
+			"S08000020",                  // NHS HA code
+			"",                           // Admin county code
+			"S12000033",                  // Admin district code
+			"S13002843",                  // Admin ward code
+		}
 		assert.NoError(t, csvWriter.Write(record))
 	}
 
 	return tempFile.Name()
 }
 
+// codePointArgs returns the insert arguments for the i'th record written by createTestZipCodePoint
+func codePointArgs(i int) []driver.Value {
+	return []driver.Value{
+		fmt.Sprintf("AB12 3CD%d", i), 10, 300000 + i, 700000 + i,
+		"S92000003", "", "S08000020", "", "S12000033", "S13002843",
+		internal.MortonKey(300000+i, 700000+i),
+	}
+}
+
 func TestFromCodePointCSV(t *testing.T) {
-	headers := []string{"Postcode", "Quality", "Easting", "Northing"}
-	record := []string{"AB12 3CD", "1", "300000", "700000"}
+	headers := []string{"PC", "PQ", "EA", "NO", "CY", "RH", "LH", "CC", "DC", "WC"}
+	record := []string{"AB12 3CD", "10", "300000", "700000", "S92000003", "", "S08000020", "", "S12000033", "S13002843"}
 
 	expected := &CodePoint{
-		PostCode: "AB12 3CD",
-		Easting:  300000,
-		Northing: 700000,
+		PostCode:          "AB12 3CD",
+		PositionalQuality: 10,
+		Easting:           300000,
+		Northing:          700000,
+		CountryCode:       "S92000003",
+		NHSHACode:         "S08000020",
+		DistrictCode:      "S12000033",
+		WardCode:          "S13002843",
 	}
 
 	actual, err := fromCodePointCSV(record, headers)
@@ -77,36 +113,85 @@ func TestFromCodePointCSV(t *testing.T) {
 }
 
 func TestFromCodePointCSVInvalidEasting(t *testing.T) {
-	headers := []string{"Postcode", "Quality", "Easting", "Northing"}
-	record := []string{"AB12 3CD", "1", "invalid", "700000"}
+	record := []string{"AB12 3CD", "1", "invalid", "700000", "", "", "", "", "", ""}
 
-	_, err := fromCodePointCSV(record, headers)
+	_, err := fromCodePointCSV(record, nil)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "strconv.Atoi: parsing \"invalid\": invalid syntax")
 }
 
 func TestFromCodePointCSVInvalidNorthing(t *testing.T) {
-	headers := []string{"Postcode", "Quality", "Easting", "Northing"}
-	record := []string{"AB12 3CD", "1", "300000", "invalid"}
+	record := []string{"AB12 3CD", "1", "300000", "invalid", "", "", "", "", "", ""}
 
-	_, err := fromCodePointCSV(record, headers)
+	_, err := fromCodePointCSV(record, nil)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "strconv.Atoi: parsing \"invalid\": invalid syntax")
 }
 
+func TestFromCodePointCSVResolvesReorderedLongHeaders(t *testing.T) {
+	headers := []string{
+		"Eastings", "Northings", "Postcode", "Positional_quality_indicator", "Country_code",
+		"NHS_regional_HA_code", "NHS_HA_code", "Admin_county_code", "Admin_district_code", "Admin_ward_code",
+	}
+	record := []string{"300000", "700000", "AB12 3CD", "10", "S92000003", "", "S08000020", "", "S12000033", "S13002843"}
+
+	actual, err := fromCodePointCSV(record, headers)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "AB12 3CD", actual.PostCode)
+	assert.Equal(t, 10, actual.PositionalQuality)
+	assert.Equal(t, 300000, actual.Easting)
+	assert.Equal(t, 700000, actual.Northing)
+	assert.Equal(t, "S13002843", actual.WardCode)
+}
+
+func TestFromCodePointCSVSchemaDrift(t *testing.T) {
+	headers := []string{"PC", "PQ", "EA", "NO", "CY", "RH", "LH", "CC", "DC", "WARD"}
+	record := []string{"AB12 3CD", "10", "300000", "700000", "", "", "", "", "", ""}
+
+	_, err := fromCodePointCSV(record, headers)
+
+	var drift *SchemaDriftError
+	assert.ErrorAs(t, err, &drift)
+	assert.Equal(t, []string{"WC"}, drift.Missing)
+	assert.Equal(t, []string{"WARD"}, drift.Unexpected)
+}
+
+func TestFromCodePointCSVWrongFieldCount(t *testing.T) {
+	record := []string{"AB12 3CD", "10", "300000", "700000"}
+
+	_, err := fromCodePointCSV(record, nil)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "record has 4 fields but header has 10")
+}
+
 func TestCodePointToTuple(t *testing.T) {
 	codePoint := CodePoint{
-		PostCode: "AB12 3CD",
-		Easting:  300000,
-		Northing: 700000,
+		PostCode:          "AB12 3CD",
+		PositionalQuality: 10,
+		Easting:           300000,
+		Northing:          700000,
+		CountryCode:       "S92000003",
+		NHSHACode:         "S08000020",
+		DistrictCode:      "S12000033",
+		WardCode:          "S13002843",
 	}
 
 	expected := []any{
 		"AB12 3CD",
+		10,
 		300000,
 		700000,
+		"S92000003",
+		"",
+		"S08000020",
+		"",
+		"S12000033",
+		"S13002843",
+		internal.MortonKey(300000, 700000),
 	}
 
 	actual := codePointToTuple(codePoint)
@@ -130,17 +215,21 @@ func TestImportCodePoint(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectPrepare(internal.InsertCodePointSQL)
 	mock.ExpectExec(internal.InsertCodePointSQL).
-		WithArgs("AB12 3CD0", 300000, 700000).
+		WithArgs(codePointArgs(0)...).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
+	expectCodePointIndexRebuild(mock)
 	mock.ExpectExec("ANALYZE code_point").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(internal.RecordDatasetImportSQL).
+		WithArgs("code-point", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err = codePoint.Import(zipPath, http.Header{})
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 
-	assert.Contains(t, buf.String(), "Completed successfully")
+	assert.Contains(t, buf.String(), "Import completed successfully")
 }
 
 func TestImportCodePointMultipleRecords(t *testing.T) {
@@ -161,18 +250,22 @@ func TestImportCodePointMultipleRecords(t *testing.T) {
 	mock.ExpectPrepare(internal.InsertCodePointSQL)
 	for i := 0; i < numRecords; i++ {
 		mock.ExpectExec(internal.InsertCodePointSQL).
-			WithArgs(fmt.Sprintf("AB12 3CD%d", i), 300000+i, 700000+i).
+			WithArgs(codePointArgs(i)...).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 	}
 	mock.ExpectCommit()
+	expectCodePointIndexRebuild(mock)
 	mock.ExpectExec("ANALYZE code_point").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(internal.RecordDatasetImportSQL).
+		WithArgs("code-point", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err = codePoint.Import(zipPath, http.Header{})
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 
-	assert.Contains(t, buf.String(), "Completed successfully")
+	assert.Contains(t, buf.String(), "Import completed successfully")
 }
 
 func TestImportCodePointPrepareError(t *testing.T) {
@@ -210,7 +303,7 @@ func TestImportCodePointExecError(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectPrepare(internal.InsertCodePointSQL)
 	mock.ExpectExec(internal.InsertCodePointSQL).
-		WithArgs("AB12 3CD0", 300000, 700000).
+		WithArgs(codePointArgs(0)...).
 		WillReturnError(fmt.Errorf("exec error"))
 	mock.ExpectRollback()
 
@@ -231,31 +324,165 @@ func TestProcessCodePointCSV(t *testing.T) {
 		assert.NoError(t, os.Remove(zipPath))
 	}()
 
-	r, err := zip.OpenReader(zipPath)
+	src, err := openSource(zipPath)
 	assert.NoError(t, err)
 	defer func() {
-		assert.NoError(t, r.Close())
+		assert.NoError(t, src.Close())
 	}()
 
 	mock.ExpectBegin()
 	mock.ExpectPrepare(internal.InsertCodePointSQL)
 	mock.ExpectExec(internal.InsertCodePointSQL).
-		WithArgs("AB12 3CD0", 300000, 700000).
+		WithArgs(codePointArgs(0)...).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	// Find the CSV file within the zip
-	var csvFile *zip.File
-	for _, f := range r.File {
-		if !f.FileInfo().IsDir() && strings.HasSuffix(f.Name, ".csv") {
-			csvFile = f
-			break
-		}
-	}
-	assert.NotNil(t, csvFile, "CSV file not found in zip")
-
-	numRecords, err := codePoint.processCSV(csvFile)
+	numRecords, err := codePoint.processCSV(src.files[0], codePointColumns)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 	assert.Equal(t, 1, numRecords)
 }
+
+func TestImportCodePointFullRefresh(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+
+	codePoint := NewCodePointImporter(db, WithFullRefresh(true))
+
+	zipPath := createTestZipCodePoint(t, 1)
+	defer func() {
+		assert.NoError(t, os.Remove(zipPath))
+	}()
+
+	buf, _ := setupSlogBuffer()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS import_seen_code_point (key TEXT NOT NULL PRIMARY KEY)").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM import_seen_code_point").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectPrepare(internal.InsertCodePointSQL)
+	mock.ExpectExec(internal.InsertCodePointSQL).
+		WithArgs(codePointArgs(0)...).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("INSERT OR IGNORE INTO import_seen_code_point (key) VALUES (?)")
+	mock.ExpectExec("INSERT OR IGNORE INTO import_seen_code_point (key) VALUES (?)").
+		WithArgs("AB12 3CD0").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT COUNT(*) FROM import_seen_code_point").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM code_point WHERE post_code NOT IN (SELECT key FROM import_seen_code_point) AND source = 'codepoint'").
+		WillReturnResult(sqlmock.NewResult(0, 7))
+	mock.ExpectCommit()
+	mock.ExpectExec("DROP TABLE IF EXISTS import_seen_code_point").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectCodePointIndexRebuild(mock)
+	mock.ExpectExec("ANALYZE code_point").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(internal.RecordDatasetImportSQL).
+		WithArgs("code-point", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = codePoint.Import(zipPath, http.Header{})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Contains(t, buf.String(), `"removedRecords":7`)
+}
+
+func TestImportCodePointFullRefreshSkipsRemovalWhenNothingSeen(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+
+	stale := newStaleKeys("code_point", "post_code")
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM import_seen_code_point").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("DROP TABLE IF EXISTS import_seen_code_point").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	removed, err := stale.removeUnseen(db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), removed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportCodePointWithCodeLists(t *testing.T) {
+	db := connectTestDB(t)
+
+	tempFile, err := os.CreateTemp(t.TempDir(), "test-*.zip")
+	assert.NoError(t, err)
+	zipWriter := zip.NewWriter(tempFile)
+	for name, content := range map[string]string{
+		"Data/CSV/ab.csv":                        "\"AB10 1AB\",10,394251,806376,\"S92000003\",\"\",\"S08000020\",\"\",\"S12000033\",\"S13002842\"\n",
+		"Doc/Code-Point_Open_Column_Headers.csv": "PC,PQ,EA,NO,CY,RH,LH,CC,DC,WC\n",
+		"Doc/Codelist/DC.csv":                    "\"Aberdeen City\",\"S12000033\"\n",
+		"Doc/Codelist/WC.csv":                    "\"George St/Harbour\",\"S13002842\"\n",
+	} {
+		f, err := zipWriter.Create(name)
+		assert.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zipWriter.Close())
+	assert.NoError(t, tempFile.Close())
+
+	err = NewCodePointImporter(db).Import(tempFile.Name(), http.Header{})
+	assert.NoError(t, err)
+
+	var quality int
+	var districtCode, districtName, wardName string
+	err = db.QueryRow(`
+		SELECT cp.positional_quality, cp.district_code, district.name, ward.name
+		FROM code_point cp
+		JOIN code_point_area district ON district.code = cp.district_code
+		JOIN code_point_area ward ON ward.code = cp.ward_code
+		WHERE cp.post_code = 'AB10 1AB'`).Scan(&quality, &districtCode, &districtName, &wardName)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, quality)
+	assert.Equal(t, "S12000033", districtCode)
+	assert.Equal(t, "Aberdeen City", districtName)
+	assert.Equal(t, "George St/Harbour", wardName)
+
+	var areaType string
+	assert.NoError(t, db.QueryRow("SELECT area_type FROM code_point_area WHERE code = 'S12000033'").Scan(&areaType))
+	assert.Equal(t, "DC", areaType)
+}
+
+func TestImportCodePointRejectsSchemaDrift(t *testing.T) {
+	db := connectTestDB(t)
+
+	tempFile, err := os.CreateTemp(t.TempDir(), "test-*.zip")
+	assert.NoError(t, err)
+	zipWriter := zip.NewWriter(tempFile)
+	for name, content := range map[string]string{
+		"Data/CSV/ab.csv":                        "\"AB10 1AB\",10,394251,806376,\"S92000003\",\"\",\"S08000020\",\"\",\"S12000033\",\"S13002842\",\"X\"\n",
+		"Doc/Code-Point_Open_Column_Headers.csv": "PC,PQ,EA,NO,CY,RH,LH,CC,DC,WC,XX\n",
+	} {
+		f, err := zipWriter.Create(name)
+		assert.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zipWriter.Close())
+	assert.NoError(t, tempFile.Close())
+
+	err = NewCodePointImporter(db).Import(tempFile.Name(), http.Header{})
+
+	var drift *SchemaDriftError
+	assert.ErrorAs(t, err, &drift)
+	assert.Equal(t, []string{"XX"}, drift.Unexpected)
+
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM code_point").Scan(&count))
+	assert.Zero(t, count)
+}
+
+func TestIsCodeListFile(t *testing.T) {
+	assert.True(t, isCodeListFile("Doc/Codelist/DC.csv"))
+	assert.False(t, isCodeListFile("Doc/Code-Point_Open_Column_Headers.csv"))
+	assert.False(t, isCodeListFile("Doc/licence.txt"))
+	assert.False(t, isCodeListFile("Data/CSV/ab.csv"))
+}
@@ -0,0 +1,100 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SchemaDriftError reports that the columns of a CSV file don't match those
+// the importer expects. A renamed column shows up as one missing and one
+// unexpected column.
+type SchemaDriftError struct {
+	Missing    []string
+	Unexpected []string
+	Duplicated []string
+}
+
+func (e *SchemaDriftError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("missing columns: %s", strings.Join(e.Missing, ", ")))
+	}
+	if len(e.Unexpected) > 0 {
+		parts = append(parts, fmt.Sprintf("unexpected columns: %s", strings.Join(e.Unexpected, ", ")))
+	}
+	if len(e.Duplicated) > 0 {
+		parts = append(parts, fmt.Sprintf("duplicated columns: %s", strings.Join(e.Duplicated, ", ")))
+	}
+	return "CSV columns do not match the expected schema (" + strings.Join(parts, "; ") + ")"
+}
+
+// columnMapping resolves CSV columns by header name rather than position.
+// Header names are trimmed (Companies House prefixes most of them with a
+// space), and aliases map alternative spellings onto the expected name.
+type columnMapping struct {
+	expected []string
+	aliases  map[string]string
+	// ignoreUnexpected accepts columns other than those expected, for
+	// sources that carry many more than are imported.
+	ignoreUnexpected bool
+}
+
+// columnIndex maps expected column names to their position in a record.
+type columnIndex map[string]int
+
+func (m *columnMapping) resolve(headers []string) (columnIndex, error) {
+	index := make(columnIndex, len(headers))
+	drift := &SchemaDriftError{}
+
+	for i, header := range headers {
+		name := strings.TrimSpace(header)
+		if canonical, ok := m.aliases[name]; ok {
+			name = canonical
+		}
+		if _, seen := index[name]; seen {
+			drift.Duplicated = append(drift.Duplicated, name)
+			continue
+		}
+		index[name] = i
+	}
+
+	expected := make(map[string]bool, len(m.expected))
+	for _, name := range m.expected {
+		expected[name] = true
+		if _, ok := index[name]; !ok {
+			drift.Missing = append(drift.Missing, name)
+		}
+	}
+	for _, header := range headers {
+		name := strings.TrimSpace(header)
+		if canonical, ok := m.aliases[name]; ok {
+			name = canonical
+		}
+		if !expected[name] && !m.ignoreUnexpected {
+			drift.Unexpected = append(drift.Unexpected, name)
+		}
+	}
+
+	if len(drift.Missing) > 0 || len(drift.Unexpected) > 0 || len(drift.Duplicated) > 0 {
+		return nil, drift
+	}
+	return index, nil
+}
+
+// lazyColumnIndex resolves the column index from the first record's headers
+// and reuses it for the rest of the file; it is safe for use by concurrent
+// parse workers.
+type lazyColumnIndex struct {
+	mapping *columnMapping
+	once    sync.Once
+	index   columnIndex
+	err     error
+}
+
+func (l *lazyColumnIndex) get(headers []string) (columnIndex, error) {
+	l.once.Do(func() {
+		l.index, l.err = l.mapping.resolve(headers)
+	})
+	return l.index, l.err
+}
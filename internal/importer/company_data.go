@@ -1,83 +1,462 @@
 package importer
 
 import (
-	"archive/zip"
 	"database/sql"
 	"fmt"
 	"log/slog"
-	"net/http"
+	"strings"
 	"time"
 
 	"github.com/map-services/company-data-api/internal"
 	"github.com/map-services/company-data-api/internal/models"
 )
 
-func fromCompanyDataCSV(record []string, headers []string) (*models.CompanyData, error) {
-	if len(record) < len(headers) {
-		return nil, fmt.Errorf("record has fewer fields than headers: %d vs %d", len(record), len(headers))
+// companyDataColumns lists, in order, the columns of the Companies House
+// Basic Company Data CSV. The previous names are not imported, but are still
+// expected so that any change to the file layout is detected.
+var companyDataColumns = func() []string {
+	columns := []string{
+		"CompanyName", "CompanyNumber", "RegAddress.CareOf", "RegAddress.POBox",
+		"RegAddress.AddressLine1", "RegAddress.AddressLine2", "RegAddress.PostTown",
+		"RegAddress.County", "RegAddress.Country", "RegAddress.PostCode",
+		"CompanyCategory", "CompanyStatus", "CountryOfOrigin", "DissolutionDate",
+		"IncorporationDate", "Accounts.AccountRefDay", "Accounts.AccountRefMonth",
+		"Accounts.NextDueDate", "Accounts.LastMadeUpDate", "Accounts.AccountCategory",
+		"Returns.NextDueDate", "Returns.LastMadeUpDate", "Mortgages.NumMortCharges",
+		"Mortgages.NumMortOutstanding", "Mortgages.NumMortPartSatisfied", "Mortgages.NumMortSatisfied",
+		"SICCode.SicText_1", "SICCode.SicText_2", "SICCode.SicText_3", "SICCode.SicText_4",
+		"LimitedPartnerships.NumGenPartners", "LimitedPartnerships.NumLimPartners", "URI",
+	}
+	for i := 1; i <= 10; i++ {
+		columns = append(columns,
+			fmt.Sprintf("PreviousName_%d.CONDATE", i),
+			fmt.Sprintf("PreviousName_%d.CompanyName", i),
+		)
+	}
+	return append(columns, "ConfStmtNextDueDate", "ConfStmtLastMadeUpDate")
+}()
+
+var companyDataMapping = &columnMapping{expected: companyDataColumns}
+
+var companyDataset = &csvDataset[models.CompanyData]{
+	name:          "companies-house",
+	description:   "Companies House Basic Company Data",
+	defaultSource: "./data/BasicCompanyDataAsOneFile-2025-09-01.zip",
+	table:         "company_data",
+	keyColumn:     "company_number",
+	insertSQL:     internal.InsertCompanyDataSQL,
+	selects:       isCSVFile,
+	columns:       companyDataMapping,
+	parse:         companyDataFromRecord,
+	toTuple:       companyDataToTuple,
+	key: func(companyData models.CompanyData) string {
+		return companyData.CompanyNumber
+	},
+	matcher:   companyDataMatcher,
+	inspector: newCompanyDataInspector,
+	finish:    locateCompaniesAndChanges,
+	changes: &changeFeed[models.CompanyData]{
+		detect:  detectCompanyChanges,
+		removed: recordRemovedCompanies,
+	},
+}
+
+func init() {
+	Register(companyDataset)
+}
+
+func NewCompanyDataImporter(db *sql.DB, opts ...Option) *csvImporter[models.CompanyData] {
+	return companyDataset.newImporter(db, opts...)
+}
+
+// locateCompaniesAndChanges locates the imported companies, then the changes
+// the import found, which take the location of the company.
+func locateCompaniesAndChanges(db *sql.DB) error {
+	if err := internal.LocateCompanies(db); err != nil {
+		return err
+	}
+	return internal.LocateCompanyChanges(db)
+}
+
+// companyDataMatcher selects companies by registered postcode, status and
+// SIC code. Area filters are resolved to the set of CodePoint postcodes they
+// contain.
+func companyDataMatcher(db *sql.DB, filter Filter) (func(models.CompanyData) bool, error) {
+	var postcodes map[string]bool
+	if filter.hasArea() {
+		var err error
+		if postcodes, err = postcodesInArea(db, filter); err != nil {
+			return nil, err
+		}
+	}
+	return func(companyData models.CompanyData) bool {
+		return (postcodes == nil || postcodes[companyData.RegAddressPostCode]) &&
+			filter.matchesPostcodeArea(companyData.RegAddressPostCode) &&
+			filter.matchesCompanyStatus(companyData.CompanyStatus) &&
+			filter.matchesSICCodes(companyData.SICCode1, companyData.SICCode2, companyData.SICCode3, companyData.SICCode4)
+	}, nil
+}
+
+// companyDataInspector checks Companies House records for a dry run, and
+// counts the changes they would make to the companies already imported.
+type companyDataInspector struct {
+	db        *sql.DB
+	quality   CompanyQuality
+	postcodes map[string]bool
+	now       time.Time
+	// pending are the records still to be compared with the database, which
+	// is done in batches.
+	pending []models.CompanyData
+	changes map[string]int
+}
+
+func newCompanyDataInspector(db *sql.DB) (recordInspector[models.CompanyData], error) {
+	inspector := &companyDataInspector{
+		db: db,
+		quality: CompanyQuality{
+			ByStatus:   make(map[string]int),
+			ByCategory: make(map[string]int),
+		},
+		postcodes: make(map[string]bool),
+		now:       time.Now(),
+		changes:   make(map[string]int),
+	}
+	err := queryStrings(db, "SELECT post_code FROM code_point", func(postcode string) {
+		inspector.postcodes[postcode] = true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read postcodes: %w", err)
+	}
+	if len(inspector.postcodes) > 0 {
+		inspector.quality.UnmatchedPostcodes = &Finding{}
+	} else {
+		slog.Warn("code_point is empty, so postcodes will not be matched")
+	}
+	return inspector, nil
+}
+
+func (inspector *companyDataInspector) inspect(companyData models.CompanyData) error {
+	quality := &inspector.quality
+	quality.ByStatus[companyData.CompanyStatus]++
+	quality.ByCategory[companyData.CompanyCategory]++
+
+	number := companyData.CompanyNumber
+	postcode := companyData.RegAddressPostCode
+	switch {
+	case strings.TrimSpace(postcode) == "":
+		quality.MissingPostcodes.add(number)
+	case !internal.IsValidPostcode(postcode):
+		quality.InvalidPostcodes.add(number)
+	case quality.UnmatchedPostcodes != nil && !inspector.postcodes[postcode]:
+		quality.UnmatchedPostcodes.add(number)
+	}
+
+	incorporated := companyData.IncorporationDate
+	switch {
+	case incorporated == nil:
+		quality.MissingIncorporationDate.add(number)
+	case incorporated.After(inspector.now):
+		quality.IncorporatedInFuture.add(number)
+	}
+	if incorporated != nil && companyData.DissolutionDate != nil && incorporated.After(*companyData.DissolutionDate) {
+		quality.IncorporatedAfterDissolution.add(number)
+	}
+
+	inspector.pending = append(inspector.pending, companyData)
+	if len(inspector.pending) < maxKeysPerLookup {
+		return nil
+	}
+	return inspector.countChanges()
+}
+
+// countChanges compares the pending records with the companies already
+// imported, as detectCompanyChanges would.
+func (inspector *companyDataInspector) countChanges() error {
+	previous, err := previousCompanies(inspector.db, inspector.pending)
+	if err != nil {
+		return err
+	}
+	for _, companyData := range inspector.pending {
+		old, ok := previous[companyData.CompanyNumber]
+		previous[companyData.CompanyNumber] = companyData
+		if !ok {
+			continue
+		}
+		for _, change := range compareCompanies(old, companyData) {
+			inspector.changes[change.changeType]++
+		}
+	}
+	inspector.pending = inspector.pending[:0]
+	return nil
+}
+
+func (inspector *companyDataInspector) addTo(report *QualityReport) error {
+	if len(inspector.pending) > 0 {
+		if err := inspector.countChanges(); err != nil {
+			return err
+		}
+	}
+	report.Companies = &inspector.quality
+	report.Diff.Changes = inspector.changes
+	return nil
+}
+
+// maxKeysPerLookup caps the number of company numbers looked up in one query.
+const maxKeysPerLookup = 500
+
+// detectCompanyChanges records new companies, and renames, address moves,
+// status changes (including dissolutions), SIC code changes and accounts
+// filings of existing ones.
+func detectCompanyChanges(tx *sql.Tx, batch []models.CompanyData, detectedAt time.Time) error {
+	previous, err := previousCompanies(tx, batch)
+	if err != nil {
+		return err
+	}
+
+	var changes [][]any
+	for _, companyData := range batch {
+		old, ok := previous[companyData.CompanyNumber]
+		// A company repeated later in the source is compared with this record.
+		previous[companyData.CompanyNumber] = companyData
+		found := []companyChange{{changeType: models.ChangeIncorporated, newValue: companyData.CompanyName}}
+		if ok {
+			found = compareCompanies(old, companyData)
+		}
+		for _, change := range found {
+			changes = append(changes, []any{
+				companyData.CompanyNumber, change.changeType, change.oldValue, change.newValue,
+				companyData.RegAddressPostCode, change.previousPostCode, detectedAt,
+			})
+		}
+	}
+
+	return insertChanges(tx, internal.InsertCompanyChangeSQL, changes)
+}
+
+// companyChange is one difference between a company's record and the one
+// before it.
+type companyChange struct {
+	changeType       string
+	oldValue         string
+	newValue         string
+	previousPostCode string
+}
+
+// compareCompanies returns the changes from a company's old record to its
+// new one.
+func compareCompanies(old models.CompanyData, companyData models.CompanyData) []companyChange {
+	var changes []companyChange
+	change := func(changeType string, oldValue string, newValue string, previousPostCode string) {
+		changes = append(changes, companyChange{changeType, oldValue, newValue, previousPostCode})
+	}
+
+	if old.CompanyName != companyData.CompanyName {
+		change(models.ChangeRenamed, old.CompanyName, companyData.CompanyName, "")
+	}
+	if companyAddress(old) != companyAddress(companyData) {
+		change(models.ChangeAddressChanged, formatAddress(old), formatAddress(companyData), old.RegAddressPostCode)
 	}
+	switch {
+	case isDissolved(companyData) && !isDissolved(old):
+		change(models.ChangeDissolved, old.CompanyStatus, companyData.CompanyStatus, "")
+	case old.CompanyStatus != companyData.CompanyStatus:
+		change(models.ChangeStatusChanged, old.CompanyStatus, companyData.CompanyStatus, "")
+	}
+	if formatSICCodes(old) != formatSICCodes(companyData) {
+		change(models.ChangeSICChanged, formatSICCodes(old), formatSICCodes(companyData), "")
+	}
+	// A filing moves the made-up date on; it is only cleared by corrections.
+	if lastMadeUp := formatDate(companyData.AccountsLastMadeUpDate); lastMadeUp != "" && lastMadeUp != formatDate(old.AccountsLastMadeUpDate) {
+		change(models.ChangeAccountsFiled, formatDate(old.AccountsLastMadeUpDate), lastMadeUp, "")
+	}
+	return changes
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// previousCompanies reads the current name, address, status, SIC codes and
+// accounts made-up date of the companies in the batch that are already in
+// the database.
+func previousCompanies(tx queryer, batch []models.CompanyData) (map[string]models.CompanyData, error) {
+	previous := make(map[string]models.CompanyData, len(batch))
+	for start := 0; start < len(batch); start += maxKeysPerLookup {
+		chunk := batch[start:min(start+maxKeysPerLookup, len(batch))]
+		keys := make([]any, len(chunk))
+		for i, companyData := range chunk {
+			keys[i] = companyData.CompanyNumber
+		}
+
+		rows, err := tx.Query(`
+			SELECT company_number, company_name,
+				COALESCE(reg_address_care_of, ''), COALESCE(reg_address_po_box, ''),
+				reg_address_address_line_1, COALESCE(reg_address_address_line_2, ''),
+				reg_address_post_town, COALESCE(reg_address_county, ''),
+				COALESCE(reg_address_country, ''), reg_address_post_code,
+				company_status, dissolution_date,
+				sic_code_1, COALESCE(sic_code_2, ''), COALESCE(sic_code_3, ''), COALESCE(sic_code_4, ''),
+				accounts_last_made_up_date
+			FROM company_data
+			WHERE company_number IN (?`+strings.Repeat(",?", len(keys)-1)+`)`, keys...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read existing companies: %w", err)
+		}
+
+		for rows.Next() {
+			var cd models.CompanyData
+			if err := rows.Scan(
+				&cd.CompanyNumber, &cd.CompanyName,
+				&cd.RegAddressCareOf, &cd.RegAddressPOBox,
+				&cd.RegAddressAddressLine1, &cd.RegAddressAddressLine2,
+				&cd.RegAddressPostTown, &cd.RegAddressCounty,
+				&cd.RegAddressCountry, &cd.RegAddressPostCode,
+				&cd.CompanyStatus, &cd.DissolutionDate,
+				&cd.SICCode1, &cd.SICCode2, &cd.SICCode3, &cd.SICCode4,
+				&cd.AccountsLastMadeUpDate,
+			); err != nil {
+				_ = rows.Close()
+				return nil, fmt.Errorf("failed to scan existing company: %w", err)
+			}
+			previous[cd.CompanyNumber] = cd
+		}
+		if err := rows.Close(); err != nil {
+			return nil, fmt.Errorf("failed to read existing companies: %w", err)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read existing companies: %w", err)
+		}
+	}
+	return previous, nil
+}
+
+// recordRemovedCompanies records the companies a full refresh is about to
+// delete, which have typically been dissolved and struck off.
+func recordRemovedCompanies(tx *sql.Tx, seenTable string, detectedAt time.Time) error {
+	query := strings.Replace(internal.InsertRemovedCompanyChangesSQL, "{{seen_table}}", seenTable, 1)
+	if _, err := tx.Exec(query, detectedAt); err != nil {
+		return fmt.Errorf("failed to record removed companies: %w", err)
+	}
+	return nil
+}
 
+func companyAddress(companyData models.CompanyData) [8]string {
+	return [8]string{
+		companyData.RegAddressCareOf,
+		companyData.RegAddressPOBox,
+		companyData.RegAddressAddressLine1,
+		companyData.RegAddressAddressLine2,
+		companyData.RegAddressPostTown,
+		companyData.RegAddressCounty,
+		companyData.RegAddressCountry,
+		companyData.RegAddressPostCode,
+	}
+}
+
+// formatAddress returns the registered address on a single line.
+func formatAddress(companyData models.CompanyData) string {
+	var parts []string
+	for _, part := range companyAddress(companyData) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatSICCodes returns the company's SIC codes as a single value.
+func formatSICCodes(companyData models.CompanyData) string {
+	var codes []string
+	for _, code := range []string{companyData.SICCode1, companyData.SICCode2, companyData.SICCode3, companyData.SICCode4} {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return strings.Join(codes, "; ")
+}
+
+func formatDate(date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.Format(time.DateOnly)
+}
+
+func isDissolved(companyData models.CompanyData) bool {
+	return companyData.DissolutionDate != nil || strings.HasPrefix(strings.ToLower(companyData.CompanyStatus), "dissolved")
+}
+
+func fromCompanyDataCSV(record []string, headers []string) (*models.CompanyData, error) {
+	return companyDataset.newRecordParser(nil).parse(record, headers)
+}
+
+func companyDataFromRecord(record []string, columns columnIndex) (*models.CompanyData, error) {
 	var err error
-	parseDateField := func(index int, fieldName string) *time.Time {
+	field := func(name string) string {
+		return record[columns[name]]
+	}
+
+	parseDateField := func(name string) *time.Time {
 		if err != nil {
 			return nil
 		}
 		var date *time.Time
-		date, err = parseDate(record[index])
+		date, err = parseDate(field(name))
 		if err != nil {
-			err = fmt.Errorf("invalid %s: %w", fieldName, err)
+			err = fmt.Errorf("invalid %s: %w", name, err)
 		}
 		return date
 	}
 
-	parseIntField := func(index int, fieldName string) int {
+	parseIntField := func(name string) int {
 		if err != nil {
 			return 0
 		}
 		var val int
-		val, err = parseInt(record[index])
+		val, err = parseInt(field(name))
 		if err != nil {
-			err = fmt.Errorf("invalid %s: %w", fieldName, err)
+			err = fmt.Errorf("invalid %s: %w", name, err)
 		}
 		return val
 	}
 
 	company := models.CompanyData{
-		CompanyName:                       record[0],
-		CompanyNumber:                     record[1],
-		RegAddressCareOf:                  record[2],
-		RegAddressPOBox:                   record[3],
-		RegAddressAddressLine1:            record[4],
-		RegAddressAddressLine2:            record[5],
-		RegAddressPostTown:                record[6],
-		RegAddressCounty:                  record[7],
-		RegAddressCountry:                 record[8],
-		RegAddressPostCode:                record[9],
-		CompanyCategory:                   record[10],
-		CompanyStatus:                     record[11],
-		CountryOfOrigin:                   record[12],
-		DissolutionDate:                   parseDateField(13, "DissolutionDate"),
-		IncorporationDate:                 parseDateField(14, "IncorporationDate"),
-		AccountsAccountRefDay:             parseIntField(15, "AccountsAccountRefDay"),
-		AccountsAccountRefMonth:           parseIntField(16, "AccountsAccountRefMonth"),
-		AccountsNextDueDate:               parseDateField(17, "AccountsNextDueDate"),
-		AccountsLastMadeUpDate:            parseDateField(18, "AccountsLastMadeUpDate"),
-		AccountsAccountCategory:           record[19],
-		ReturnsNextDueDate:                parseDateField(20, "ReturnsNextDueDate"),
-		ReturnsLastMadeUpDate:             parseDateField(21, "ReturnsLastMadeUpDate"),
-		MortgagesNumCharges:               parseIntField(22, "MortgagesNumCharges"),
-		MortgagesNumOutstanding:           parseIntField(23, "MortgagesNumOutstanding"),
-		MortgagesNumPartSatisfied:         parseIntField(24, "MortgagesNumPartSatisfied"),
-		MortgagesNumSatisfied:             parseIntField(25, "MortgagesNumSatisfied"),
-		SICCode1:                          record[26],
-		SICCode2:                          record[27],
-		SICCode3:                          record[28],
-		SICCode4:                          record[29],
-		LimitedPartnershipsNumGenPartners: parseIntField(30, "LimitedPartnershipsNumGenPartners"),
-		LimitedPartnershipsNumLimPartners: parseIntField(31, "LimitedPartnershipsNumLimPartners"),
-		URI:                               record[32],
-		ConfStmtNextDueDate:               parseDateField(53, "ConfStmtNextDueDate"),
-		ConfStmtLastMadeUpDate:            parseDateField(54, "ConfStmtLastMadeUpDate"),
+		CompanyName:                       field("CompanyName"),
+		CompanyNumber:                     field("CompanyNumber"),
+		RegAddressCareOf:                  field("RegAddress.CareOf"),
+		RegAddressPOBox:                   field("RegAddress.POBox"),
+		RegAddressAddressLine1:            field("RegAddress.AddressLine1"),
+		RegAddressAddressLine2:            field("RegAddress.AddressLine2"),
+		RegAddressPostTown:                field("RegAddress.PostTown"),
+		RegAddressCounty:                  field("RegAddress.County"),
+		RegAddressCountry:                 field("RegAddress.Country"),
+		RegAddressPostCode:                internal.NormalisePostcode(field("RegAddress.PostCode")),
+		CompanyCategory:                   field("CompanyCategory"),
+		CompanyStatus:                     field("CompanyStatus"),
+		CountryOfOrigin:                   field("CountryOfOrigin"),
+		DissolutionDate:                   parseDateField("DissolutionDate"),
+		IncorporationDate:                 parseDateField("IncorporationDate"),
+		AccountsAccountRefDay:             parseIntField("Accounts.AccountRefDay"),
+		AccountsAccountRefMonth:           parseIntField("Accounts.AccountRefMonth"),
+		AccountsNextDueDate:               parseDateField("Accounts.NextDueDate"),
+		AccountsLastMadeUpDate:            parseDateField("Accounts.LastMadeUpDate"),
+		AccountsAccountCategory:           field("Accounts.AccountCategory"),
+		ReturnsNextDueDate:                parseDateField("Returns.NextDueDate"),
+		ReturnsLastMadeUpDate:             parseDateField("Returns.LastMadeUpDate"),
+		MortgagesNumCharges:               parseIntField("Mortgages.NumMortCharges"),
+		MortgagesNumOutstanding:           parseIntField("Mortgages.NumMortOutstanding"),
+		MortgagesNumPartSatisfied:         parseIntField("Mortgages.NumMortPartSatisfied"),
+		MortgagesNumSatisfied:             parseIntField("Mortgages.NumMortSatisfied"),
+		SICCode1:                          field("SICCode.SicText_1"),
+		SICCode2:                          field("SICCode.SicText_2"),
+		SICCode3:                          field("SICCode.SicText_3"),
+		SICCode4:                          field("SICCode.SicText_4"),
+		LimitedPartnershipsNumGenPartners: parseIntField("LimitedPartnerships.NumGenPartners"),
+		LimitedPartnershipsNumLimPartners: parseIntField("LimitedPartnerships.NumLimPartners"),
+		URI:                               field("URI"),
+		ConfStmtNextDueDate:               parseDateField("ConfStmtNextDueDate"),
+		ConfStmtLastMadeUpDate:            parseDateField("ConfStmtLastMadeUpDate"),
 	}
 
 	if err != nil {
@@ -126,122 +505,3 @@ func companyDataToTuple(companyData models.CompanyData) []any {
 		companyData.ConfStmtLastMadeUpDate,
 	}
 }
-
-type companyDataImporter struct {
-	batchSize int
-	db        *sql.DB
-}
-
-func NewCompanyDataImporter(db *sql.DB) *companyDataImporter {
-	return &companyDataImporter{
-		batchSize: 5000,
-		db:        db,
-	}
-}
-
-func (importer *companyDataImporter) Import(zipPath string, _ http.Header) error {
-	r, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return fmt.Errorf("failed to open zip file: %w", err)
-	}
-	defer func() {
-		if err := r.Close(); err != nil {
-			slog.Error("error closing zip file", "error", err)
-		}
-	}()
-
-	for _, f := range r.File {
-		if err := importer.processCSV(f); err != nil {
-			return fmt.Errorf("failed to process CSV data: %w", err)
-		}
-	}
-
-	slog.Info("Import completed successfully!")
-	slog.Info("Analyzing \"company_data\" table")
-	if _, err = importer.db.Exec("ANALYZE company_data"); err != nil {
-		return fmt.Errorf("failed to analyze \"company_data\" table: %w", err)
-	}
-	return nil
-}
-
-func (importer *companyDataImporter) processCSV(f *zip.File) error {
-	r, err := f.Open()
-	if err != nil {
-		return fmt.Errorf("failed to open embedded file %s in zip: %w", f.Name, err)
-	}
-	defer func() {
-		if err := r.Close(); err != nil {
-			slog.Error("error closing embedded zip file", "error", err)
-		}
-	}()
-
-	batch := make([]models.CompanyData, 0, importer.batchSize)
-	lineNum := 0
-
-	for result := range internal.ParseCSV(r, true, fromCompanyDataCSV) {
-		lineNum = result.LineNum
-		if result.Error != nil {
-			return fmt.Errorf("error parsing line %d: %w", lineNum, result.Error)
-		}
-
-		batch = append(batch, *result.Value)
-
-		if len(batch) >= importer.batchSize {
-			if err := importer.insertBatch(batch, lineNum); err != nil {
-				return fmt.Errorf("failed to insert company data batch at line %d: %w", lineNum, err)
-			}
-			batch = batch[:0] // Clear the buffer, retaining capacity
-		}
-	}
-
-	// Insert any remaining records in the buffer
-	if len(batch) > 0 {
-		if err := importer.insertBatch(batch, lineNum); err != nil {
-			return fmt.Errorf("failed to insert final company data batch at line %d: %w", lineNum, err)
-		}
-	}
-
-	return nil
-}
-
-func (importer *companyDataImporter) insertBatch(batch []models.CompanyData, lastLineNum int) error {
-	if len(batch) == 0 {
-		return nil
-	}
-
-	tx, err := importer.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer func() {
-		if err != nil {
-			if rbErr := tx.Rollback(); rbErr != nil {
-				slog.Error("error rolling back transaction", "error", rbErr)
-			}
-		}
-	}()
-
-	stmt, err := tx.Prepare(internal.InsertCompanyDataSQL)
-	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
-	}
-	defer func() {
-		if err := stmt.Close(); err != nil {
-			slog.Error("failed to close statement", "error", err)
-		}
-	}()
-
-	for _, companyData := range batch {
-		_, err = stmt.Exec(companyDataToTuple(companyData)...)
-		if err != nil {
-			return fmt.Errorf("failed to execute individual insert: %w", err)
-		}
-	}
-
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	slog.Info("Inserted records", "lastLineNum", lastLineNum)
-	return nil
-}
@@ -16,20 +16,19 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// expectLocateCompanies expects companies to be located by postcode, using
+// the given query, and then by sector or district centroid.
+func expectLocateCompanies(mock sqlmock.Sqlmock, query string) {
+	mock.ExpectExec(query).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(internal.LocateCompaniesByCentroidSQL).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COUNT(*) FROM company_data WHERE morton_key IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+}
+
 func TestFromCompanyDataCSV(t *testing.T) {
-	headers := []string{
-		"CompanyName", "CompanyNumber", "RegAddress.CareOf", "RegAddress.POBox",
-		"RegAddress.AddressLine1", "RegAddress.AddressLine2", "RegAddress.PostTown",
-		"RegAddress.County", "RegAddress.Country", "RegAddress.PostCode",
-		"CompanyCategory", "CompanyStatus", "CountryOfOrigin", "DissolutionDate",
-		"IncorporationDate", "Accounts.AccountRefDay", "Accounts.AccountRefMonth",
-		"Accounts.NextDueDate", "Accounts.LastMadeUpDate", "Accounts.AccountCategory",
-		"Returns.NextDueDate", "Returns.LastMadeUpDate", "Mortgages.NumCharges",
-		"Mortgages.NumOutstanding", "Mortgages.NumPartSatisfied", "Mortgages.NumSatisfied",
-		"SICCode.SicText_1", "SICCode.SicText_2", "SICCode.SicText_3", "SICCode.SicText_4",
-		"LimitedPartnerships.NumGenPartners", "LimitedPartnerships.NumLimPartners", "URI",
-		"ConfStmtNextDueDate", "ConfStmtLastMadeUpDate",
-	}
+	headers := companyDataHeaders()
 
 	record := make([]string, 55)
 	record[0] = "company"
@@ -118,27 +117,56 @@ func TestFromCompanyDataCSV(t *testing.T) {
 }
 
 func TestFromCompanyDataCSVShortRecord(t *testing.T) {
-	headers := []string{"CompanyName", "CompanyNumber"}
 	record := []string{"company"}
 
-	_, err := fromCompanyDataCSV(record, headers)
+	_, err := fromCompanyDataCSV(record, companyDataHeaders())
 
 	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "record has 1 fields but header has 55")
 }
 
 func TestFromCompanyDataCSVInvalidDate(t *testing.T) {
-	headers := []string{
-		"CompanyName", "CompanyNumber", "RegAddress.CareOf", "RegAddress.POBox",
-		"RegAddress.AddressLine1", "RegAddress.AddressLine2", "RegAddress.PostTown",
-		"RegAddress.County", "RegAddress.Country", "RegAddress.PostCode",
-		"CompanyCategory", "CompanyStatus", "CountryOfOrigin", "DissolutionDate",
-	}
+	headers := companyDataHeaders()
 	record := make([]string, 55)
 	record[13] = "invalid-date"
 
 	_, err := fromCompanyDataCSV(record, headers)
 
 	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid DissolutionDate")
+}
+
+func TestFromCompanyDataCSVResolvesColumnsByName(t *testing.T) {
+	headers := companyDataHeaders()
+	headers[0], headers[1] = headers[1], headers[0]
+	record := make([]string, 55)
+	record[0] = "123456"
+	record[1] = "company"
+
+	actual, err := fromCompanyDataCSV(record, headers)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "company", actual.CompanyName)
+	assert.Equal(t, "123456", actual.CompanyNumber)
+}
+
+func TestFromCompanyDataCSVSchemaDrift(t *testing.T) {
+	headers := companyDataHeaders()
+	headers[9] = " RegAddress.Postcode"
+	headers = append(headers, " SICCode.SicText_5", " URI")
+	record := make([]string, len(headers))
+
+	_, err := fromCompanyDataCSV(record, headers)
+
+	var drift *SchemaDriftError
+	assert.ErrorAs(t, err, &drift)
+	assert.Equal(t, []string{"RegAddress.PostCode"}, drift.Missing)
+	assert.Equal(t, []string{"RegAddress.Postcode", "SICCode.SicText_5"}, drift.Unexpected)
+	assert.Equal(t, []string{"URI"}, drift.Duplicated)
+	assert.EqualError(t, err, "CSV columns do not match the expected schema ("+
+		"missing columns: RegAddress.PostCode; "+
+		"unexpected columns: RegAddress.Postcode, SICCode.SicText_5; "+
+		"duplicated columns: URI)")
 }
 
 func TestCompanyDataToTuple(t *testing.T) {
@@ -205,8 +233,21 @@ func TestCompanyDataToTuple(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+// companyDataHeaders returns the header row as published by Companies House,
+// with a leading space on every name but the first.
+func companyDataHeaders() []string {
+	headers := make([]string, len(companyDataColumns))
+	for i, name := range companyDataColumns {
+		if i > 0 {
+			name = " " + name
+		}
+		headers[i] = name
+	}
+	return headers
+}
+
 // createTestZip creates a temporary zip file with a single CSV file for testing
-func createTestZip(t *testing.T, numRecords int) string {
+func createTestZip(t testing.TB, numRecords int) string {
 	t.Helper()
 	tempFile, err := os.CreateTemp("", "test-*.zip")
 	assert.NoError(t, err)
@@ -227,9 +268,7 @@ func createTestZip(t *testing.T, numRecords int) string {
 	csvWriter := csv.NewWriter(f)
 	defer csvWriter.Flush()
 
-	headers := make([]string, 55)
-	headers[0] = "CompanyName"
-	assert.NoError(t, csvWriter.Write(headers))
+	assert.NoError(t, csvWriter.Write(companyDataHeaders()))
 
 	for i := 0; i < numRecords; i++ {
 		record := make([]string, 55)
@@ -283,6 +322,8 @@ func TestImportCompanyData(t *testing.T) {
 		assert.NoError(t, os.Remove(zipPath))
 	}()
 
+	mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM company_data)").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 	mock.ExpectBegin()
 	mock.ExpectPrepare(internal.InsertCompanyDataSQL)
 	mock.ExpectExec(internal.InsertCompanyDataSQL).
@@ -296,8 +337,14 @@ func TestImportCompanyData(t *testing.T) {
 			sqlmock.AnyArg(), sqlmock.AnyArg(),
 		).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
+	expectLocateCompanies(mock, internal.LocateCompaniesSQL)
+	mock.ExpectExec(internal.LocateCompanyChangesSQL).
+		WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectExec("ANALYZE company_data").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(internal.RecordDatasetImportSQL).
+		WithArgs("companies-house", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 	err = companyData.Import(zipPath, http.Header{})
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -314,10 +361,10 @@ func TestProcessCompanyDataCSV(t *testing.T) {
 		assert.NoError(t, os.Remove(zipPath))
 	}()
 
-	r, err := zip.OpenReader(zipPath)
+	src, err := openSource(zipPath)
 	assert.NoError(t, err)
 	defer func() {
-		assert.NoError(t, r.Close())
+		assert.NoError(t, src.Close())
 	}()
 
 	mock.ExpectBegin()
@@ -333,7 +380,7 @@ func TestProcessCompanyDataCSV(t *testing.T) {
 			sqlmock.AnyArg(), sqlmock.AnyArg(),
 		).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
-	err = companyData.processCSV(r.File[0])
+	_, err = companyData.processCSV(src.files[0], nil)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -350,10 +397,10 @@ func TestProcessCompanyDataCSVBatching(t *testing.T) {
 		assert.NoError(t, os.Remove(zipPath))
 	}()
 
-	r, err := zip.OpenReader(zipPath)
+	src, err := openSource(zipPath)
 	assert.NoError(t, err)
 	defer func() {
-		assert.NoError(t, r.Close())
+		assert.NoError(t, src.Close())
 	}()
 
 	mock.ExpectBegin()
@@ -376,7 +423,7 @@ func TestProcessCompanyDataCSVBatching(t *testing.T) {
 		}
 	}
 	mock.ExpectCommit()
-	err = companyData.processCSV(r.File[0])
+	_, err = companyData.processCSV(src.files[0], nil)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -407,3 +454,51 @@ func TestInsertCompanyDataBatchRollbackOnError(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to execute individual insert: mock insert error")
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestImportCompanyDataFullRefresh(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+
+	companyData := NewCompanyDataImporter(db, WithFullRefresh(true))
+
+	zipPath := createTestZip(t, 1)
+	defer func() {
+		assert.NoError(t, os.Remove(zipPath))
+	}()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS import_seen_company_data (key TEXT NOT NULL PRIMARY KEY)").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM import_seen_company_data").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM company_data)").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectBegin()
+	mock.ExpectPrepare(internal.InsertCompanyDataSQL)
+	mock.ExpectExec(internal.InsertCompanyDataSQL).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("INSERT OR IGNORE INTO import_seen_company_data (key) VALUES (?)")
+	mock.ExpectExec("INSERT OR IGNORE INTO import_seen_company_data (key) VALUES (?)").
+		WithArgs("1234560").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT COUNT(*) FROM import_seen_company_data").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM company_data WHERE company_number NOT IN (SELECT key FROM import_seen_company_data)").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	mock.ExpectExec("DROP TABLE IF EXISTS import_seen_company_data").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLocateCompanies(mock, internal.LocateCompaniesSQL)
+	mock.ExpectExec(internal.LocateCompanyChangesSQL).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ANALYZE company_data").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(internal.RecordDatasetImportSQL).
+		WithArgs("companies-house", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = companyData.Import(zipPath, http.Header{})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
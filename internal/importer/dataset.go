@@ -0,0 +1,423 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/map-services/company-data-api/internal"
+)
+
+// csvDataset describes a dataset shipped as one or more CSV files: which
+// files in the zip (or directory) hold its records, how their columns are
+// mapped and parsed, and which table they are written to.
+type csvDataset[T any] struct {
+	name          string
+	description   string
+	defaultSource string
+
+	table     string
+	keyColumn string
+	insertSQL string
+	// owns, if set, is the condition selecting the rows of table written by
+	// this dataset, when it shares the table with another. A full refresh
+	// only removes those rows.
+	owns string
+
+	// selects reports whether a file in the source holds records. A lone
+	// CSV file is always imported.
+	selects func(name string) bool
+	// columns resolves the columns of the data files by name.
+	columns *columnMapping
+	// columnHeaders, if set, supplies the column names for data files that
+	// have no header row of their own.
+	columnHeaders func(files []sourceFile) ([]string, error)
+	// parse converts a single record.
+	parse func(record []string, columns columnIndex) (*T, error)
+	// toTuple returns the insertSQL arguments for a record.
+	toTuple func(T) []any
+	// key returns the value of keyColumn for a record.
+	key func(T) string
+	// matcher, if set, builds the predicate deciding which records pass an
+	// import filter.
+	matcher func(db *sql.DB, filter Filter) (func(T) bool, error)
+	// inspector, if set, builds the dataset-specific checks for a dry run.
+	inspector func(db *sql.DB) (recordInspector[T], error)
+	// prepare, if set, imports supporting files (such as lookup tables)
+	// before the records.
+	prepare func(db *sql.DB, files []sourceFile) error
+	// finish, if set, rebuilds anything derived from the table (such as a
+	// spatial index) once the records have been written.
+	finish func(db *sql.DB) error
+	// changes, if set, records how each import differs from the last.
+	changes *changeFeed[T]
+}
+
+func (d *csvDataset[T]) Name() string          { return d.name }
+func (d *csvDataset[T]) Description() string   { return d.description }
+func (d *csvDataset[T]) DefaultSource() string { return d.defaultSource }
+func (d *csvDataset[T]) Table() string         { return d.table }
+
+func (d *csvDataset[T]) NewImporter(db *sql.DB, opts ...Option) ZipImporter {
+	return d.newImporter(db, opts...)
+}
+
+func (d *csvDataset[T]) newImporter(db *sql.DB, opts ...Option) *csvImporter[T] {
+	importer := &csvImporter[T]{
+		config:  newConfig(opts),
+		dataset: d,
+		db:      db,
+	}
+	if importer.fullRefresh {
+		importer.stale = newStaleKeys(d.table, d.keyColumn)
+		importer.stale.where = d.owns
+	}
+	return importer
+}
+
+// isCSVFile reports whether a source file is a CSV file.
+func isCSVFile(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".csv")
+}
+
+// recordParser parses the records of a single file, resolving the column
+// positions from the first header it sees. Headerless files are parsed
+// against a fixed set of headers instead.
+type recordParser[T any] struct {
+	dataset *csvDataset[T]
+	headers []string
+	columns lazyColumnIndex
+}
+
+func (d *csvDataset[T]) newRecordParser(headers []string) *recordParser[T] {
+	return &recordParser[T]{
+		dataset: d,
+		headers: headers,
+		columns: lazyColumnIndex{mapping: d.columns},
+	}
+}
+
+func (parser *recordParser[T]) parse(record []string, headers []string) (*T, error) {
+	if headers == nil {
+		headers = parser.headers
+	}
+	columns, err := parser.columns.get(headers)
+	if err != nil {
+		return nil, err
+	}
+	if len(record) != len(headers) {
+		return nil, fmt.Errorf("record has %d fields but header has %d", len(record), len(headers))
+	}
+	return parser.dataset.parse(record, columns)
+}
+
+// csvImporter imports a csvDataset: records are parsed by a pool of workers
+// and written in batches by a single writer.
+type csvImporter[T any] struct {
+	config
+	dataset    *csvDataset[T]
+	db         *sql.DB
+	stale      *staleKeys
+	keep       func(T) bool
+	dryRun     *dryRun[T]
+	changedAt  time.Time
+	filtered   int
+	progress   *internal.Progress
+	throughput throughput
+}
+
+func (importer *csvImporter[T]) Import(path string, _ http.Header) error {
+	dataset := importer.dataset
+	importer.throughput.start()
+
+	src, err := openSource(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := src.Close(); err != nil {
+			slog.Error("error closing source", "error", err)
+		}
+	}()
+
+	// Check the column layout up front, so that drift fails the import
+	// before anything is written.
+	var headers []string
+	if dataset.columnHeaders != nil {
+		if headers, err = dataset.columnHeaders(src.files); err != nil {
+			return err
+		}
+		if _, err = dataset.columns.resolve(headers); err != nil {
+			return err
+		}
+	}
+
+	if !importer.filter.isEmpty() && dataset.matcher != nil {
+		if importer.keep, err = dataset.matcher(importer.db, importer.filter); err != nil {
+			return err
+		}
+	}
+
+	if importer.dryRunReport != nil {
+		if importer.dryRun, err = newDryRun(importer.db, dataset); err != nil {
+			return err
+		}
+		return importer.importFiles(src, headers)
+	}
+
+	if importer.stale != nil {
+		if err := importer.stale.begin(importer.db); err != nil {
+			return err
+		}
+	}
+
+	if dataset.changes != nil {
+		if err := importer.beginChanges(); err != nil {
+			return err
+		}
+	}
+
+	var bulk *bulkLoad
+	if importer.bulkLoad {
+		if bulk, err = beginBulkLoad(importer.db, dataset.table); err != nil {
+			return err
+		}
+		defer bulk.abort()
+	}
+
+	if dataset.prepare != nil {
+		if err := dataset.prepare(importer.db, src.files); err != nil {
+			return err
+		}
+	}
+
+	if err := importer.importFiles(src, headers); err != nil {
+		return err
+	}
+
+	var removed int64
+	if importer.stale != nil {
+		if removed, err = importer.stale.removeUnseen(importer.db); err != nil {
+			return err
+		}
+	}
+
+	if err = bulk.end(); err != nil {
+		return err
+	}
+
+	if dataset.finish != nil {
+		if err := dataset.finish(importer.db); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Import completed successfully",
+		"dataset", dataset.name,
+		"totalRecords", importer.throughput.rows,
+		"removedRecords", removed,
+		"filteredRecords", importer.filtered,
+		"elapsed", importer.throughput.elapsed().Round(time.Second).String(),
+		"rowsPerSecond", importer.throughput.rowsPerSecond(),
+	)
+	slog.Info(fmt.Sprintf("Analyzing %q table", dataset.table))
+	if _, err = importer.db.Exec("ANALYZE " + dataset.table); err != nil {
+		return fmt.Errorf("failed to analyze %q table: %w", dataset.table, err)
+	}
+
+	// Stamped last, so the version of the data only changes once the import
+	// has fully completed.
+	if _, err = importer.db.Exec(internal.RecordDatasetImportSQL, dataset.name, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to record the %s import: %w", dataset.name, err)
+	}
+	return nil
+}
+
+// importFiles processes the data files in the source, reporting progress,
+// and completes the quality report for a dry run.
+func (importer *csvImporter[T]) importFiles(src *source, headers []string) error {
+	dataset := importer.dataset
+
+	var files []sourceFile
+	var totalBytes int64
+	for _, f := range src.files {
+		if !src.single && !dataset.selects(f.name) {
+			continue
+		}
+		files = append(files, f)
+		if f.size < 0 || totalBytes < 0 {
+			totalBytes = -1
+		} else {
+			totalBytes += f.size
+		}
+	}
+
+	// Progress is measured through the uncompressed CSV data, as row counts
+	// aren't known up front.
+	importer.progress = internal.StartProgress("import "+dataset.name, totalBytes)
+	defer importer.progress.Done()
+
+	for _, f := range files {
+		recordsInFile, err := importer.processCSV(f, headers)
+		if err != nil {
+			return fmt.Errorf("failed to process CSV data: %w", err)
+		}
+		slog.Info("Processed file", "filename", f.name, "records", recordsInFile)
+	}
+	importer.progress.Done()
+
+	if importer.dryRun != nil {
+		snapshot := importer.progress.Snapshot()
+		return importer.dryRun.finish(importer.dryRunReport, importer.filtered, ThroughputReport{
+			Workers:        importer.workers,
+			ElapsedSeconds: snapshot.Elapsed.Seconds(),
+			RowsPerSecond:  int(snapshot.RowsPerSecond),
+			BytesPerSecond: int64(snapshot.BytesPerSecond),
+		})
+	}
+	return nil
+}
+
+// processCSV imports a single data file, returning the number of records
+// read. Headers are only given for headerless files.
+func (importer *csvImporter[T]) processCSV(f sourceFile, headers []string) (int, error) {
+	r, err := f.open()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", f.name, err)
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			slog.Error("error closing source file", "filename", f.name, "error", err)
+		}
+	}()
+
+	batch := make([]T, 0, importer.batchSize)
+	lineNum := 0
+
+	var reader io.Reader = r
+	if importer.progress != nil {
+		reader = importer.progress.Reader(r)
+	}
+
+	parser := importer.dataset.newRecordParser(headers)
+	for result := range internal.ParseCSVConcurrently(reader, headers == nil, importer.workers, parser.parse) {
+		lineNum = result.LineNum
+		if result.Error != nil {
+			return 0, fmt.Errorf("error parsing line %d: %w", lineNum, result.Error)
+		}
+		if importer.progress != nil {
+			importer.progress.AddRows(1)
+		}
+
+		if importer.keep != nil && !importer.keep(*result.Value) {
+			importer.filtered++
+			if importer.dryRun != nil {
+				importer.dryRun.filter(*result.Value)
+			}
+			continue
+		}
+		if importer.dryRun != nil {
+			if err := importer.dryRun.inspect(*result.Value); err != nil {
+				return 0, fmt.Errorf("failed to inspect line %d: %w", lineNum, err)
+			}
+			continue
+		}
+		batch = append(batch, *result.Value)
+
+		if len(batch) >= importer.batchSize {
+			if err := importer.insertBatch(batch, lineNum); err != nil {
+				return 0, fmt.Errorf("failed to insert batch at line %d: %w", lineNum, err)
+			}
+			batch = batch[:0] // Clear the buffer, retaining capacity
+		}
+	}
+
+	// Insert any remaining records in the buffer
+	if len(batch) > 0 {
+		if err := importer.insertBatch(batch, lineNum); err != nil {
+			return 0, fmt.Errorf("failed to insert final batch at line %d: %w", lineNum, err)
+		}
+	}
+	return lineNum, nil
+}
+
+func (importer *csvImporter[T]) insertBatch(batch []T, lastLineNum int) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	dataset := importer.dataset
+
+	tx, err := importer.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Error("error rolling back transaction", "error", rbErr)
+			}
+		}
+	}()
+
+	// Changes are detected before the batch overwrites the rows it is
+	// compared with.
+	if !importer.changedAt.IsZero() {
+		if err = dataset.changes.detect(tx, batch, importer.changedAt); err != nil {
+			return err
+		}
+	}
+
+	if importer.bulkLoad {
+		tuples := make([][]any, len(batch))
+		for i, record := range batch {
+			tuples[i] = dataset.toTuple(record)
+		}
+		if err = insertMultiRow(tx, dataset.insertSQL, tuples); err != nil {
+			return err
+		}
+	} else {
+		var stmt *sql.Stmt
+		stmt, err = tx.Prepare(dataset.insertSQL)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer func() {
+			if err := stmt.Close(); err != nil {
+				slog.Error("failed to close statement", "error", err)
+			}
+		}()
+
+		for _, record := range batch {
+			_, err = stmt.Exec(dataset.toTuple(record)...)
+			if err != nil {
+				return fmt.Errorf("failed to execute individual insert: %w", err)
+			}
+		}
+	}
+
+	if importer.stale != nil {
+		keys := make([]string, len(batch))
+		for i, record := range batch {
+			keys[i] = dataset.key(record)
+		}
+		if err = importer.stale.record(tx, keys); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	importer.throughput.add(len(batch))
+	slog.Debug("Inserted records",
+		"dataset", dataset.name,
+		"lastLineNum", lastLineNum,
+		"totalRecords", importer.throughput.rows,
+	)
+	return nil
+}
@@ -0,0 +1,218 @@
+package importer
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// maxReportSamples caps how many example keys are listed for each finding.
+const maxReportSamples = 10
+
+// QualityReport summarises a dry-run import: what the source contains, what
+// looks wrong with it, and how it differs from the current database.
+type QualityReport struct {
+	Dataset      string            `json:"dataset"`
+	Rows         int               `json:"rows"`
+	FilteredRows int               `json:"filtered_rows"`
+	Throughput   ThroughputReport  `json:"throughput"`
+	Diff         DiffReport        `json:"diff"`
+	Companies    *CompanyQuality   `json:"companies,omitempty"`
+	CodePoints   *CodePointQuality `json:"code_points,omitempty"`
+}
+
+// ThroughputReport is how quickly the source was read and parsed, including
+// filtered rows, with the given number of parsing workers. A dry run writes
+// nothing, so this is the rate an import could at best write at.
+type ThroughputReport struct {
+	Workers        int     `json:"workers"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	RowsPerSecond  int     `json:"rows_per_second"`
+	BytesPerSecond int64   `json:"bytes_per_second"`
+}
+
+// Finding counts the rows with a particular problem, with a few example keys.
+type Finding struct {
+	Count   int      `json:"count"`
+	Samples []string `json:"samples,omitempty"`
+}
+
+func (f *Finding) add(sample string) {
+	f.Count++
+	if len(f.Samples) < maxReportSamples {
+		f.Samples = append(f.Samples, sample)
+	}
+}
+
+// DiffReport compares the keys in the source with those in the current
+// database. Removed rows are those missing from the source, which a full
+// refresh would delete; Filtered rows are in the source but excluded by the
+// import's filters. For datasets with a change feed, Changes counts the
+// changes the import would record to existing rows, by change type.
+type DiffReport struct {
+	CurrentRows int            `json:"current_rows"`
+	Existing    int            `json:"existing"`
+	New         Finding        `json:"new"`
+	Removed     Finding        `json:"removed"`
+	Filtered    Finding        `json:"filtered"`
+	Duplicates  Finding        `json:"duplicates"`
+	Changes     map[string]int `json:"changes,omitempty"`
+}
+
+// CompanyQuality reports on Companies House data.
+type CompanyQuality struct {
+	ByStatus         map[string]int `json:"by_status"`
+	ByCategory       map[string]int `json:"by_category"`
+	MissingPostcodes Finding        `json:"missing_postcodes"`
+	InvalidPostcodes Finding        `json:"invalid_postcodes"`
+	// UnmatchedPostcodes are well-formed postcodes that aren't in code_point,
+	// so the company can't be located. It is omitted if code_point is empty.
+	UnmatchedPostcodes           *Finding `json:"unmatched_postcodes,omitempty"`
+	MissingIncorporationDate     Finding  `json:"missing_incorporation_date"`
+	IncorporatedAfterDissolution Finding  `json:"incorporated_after_dissolution"`
+	IncorporatedInFuture         Finding  `json:"incorporated_in_future"`
+}
+
+// CodePointQuality reports on CodePoint Open data.
+type CodePointQuality struct {
+	ByPositionalQuality map[int]int    `json:"by_positional_quality"`
+	ByCountry           map[string]int `json:"by_country"`
+	InvalidPostcodes    Finding        `json:"invalid_postcodes"`
+	MissingCoordinates  Finding        `json:"missing_coordinates"`
+}
+
+// WithDryRun parses the whole source without writing anything to the
+// database, and writes a JSON quality report to w instead.
+func WithDryRun(w io.Writer) Option {
+	return func(cfg *config) {
+		cfg.dryRunReport = w
+	}
+}
+
+// recordInspector accumulates the dataset-specific parts of the report.
+type recordInspector[T any] interface {
+	inspect(record T) error
+	addTo(report *QualityReport) error
+}
+
+const (
+	keyInDatabase = 1 << iota
+	keyInSource
+	keyFiltered
+)
+
+// dryRun builds a QualityReport from the parsed records.
+type dryRun[T any] struct {
+	dataset   *csvDataset[T]
+	report    QualityReport
+	keys      map[string]uint8
+	inspector recordInspector[T]
+}
+
+func newDryRun[T any](db *sql.DB, dataset *csvDataset[T]) (*dryRun[T], error) {
+	run := &dryRun[T]{
+		dataset: dataset,
+		report:  QualityReport{Dataset: dataset.name},
+		keys:    make(map[string]uint8),
+	}
+
+	err := queryStrings(db, fmt.Sprintf("SELECT %s FROM %s", dataset.keyColumn, dataset.table), func(key string) {
+		run.keys[key] = keyInDatabase
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current keys: %w", err)
+	}
+	run.report.Diff.CurrentRows = len(run.keys)
+
+	if dataset.inspector != nil {
+		if run.inspector, err = dataset.inspector(db); err != nil {
+			return nil, err
+		}
+	}
+	return run, nil
+}
+
+func (run *dryRun[T]) inspect(record T) error {
+	run.report.Rows++
+
+	key := run.dataset.key(record)
+	state := run.keys[key]
+	switch {
+	case state&keyInSource != 0:
+		run.report.Diff.Duplicates.add(key)
+	case state&keyInDatabase != 0:
+		run.report.Diff.Existing++
+	default:
+		run.report.Diff.New.add(key)
+	}
+	run.keys[key] = state | keyInSource
+
+	if run.inspector != nil {
+		return run.inspector.inspect(record)
+	}
+	return nil
+}
+
+// filter records that the filters excluded a record from the import.
+func (run *dryRun[T]) filter(record T) {
+	key := run.dataset.key(record)
+	run.keys[key] |= keyFiltered
+}
+
+// finish completes the report and writes it as JSON.
+func (run *dryRun[T]) finish(w io.Writer, filtered int, throughput ThroughputReport) error {
+	run.report.FilteredRows = filtered
+	run.report.Throughput = throughput
+	for key, state := range run.keys {
+		switch state {
+		case keyInDatabase:
+			run.report.Diff.Removed.add(key)
+		case keyInDatabase | keyFiltered:
+			run.report.Diff.Filtered.add(key)
+		}
+	}
+	if run.inspector != nil {
+		if err := run.inspector.addTo(&run.report); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Dry run completed, nothing was written",
+		"dataset", run.report.Dataset,
+		"rows", run.report.Rows,
+		"new", run.report.Diff.New.Count,
+		"removed", run.report.Diff.Removed.Count,
+		"rowsPerSecond", throughput.RowsPerSecond,
+	)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(run.report); err != nil {
+		return fmt.Errorf("failed to write quality report: %w", err)
+	}
+	return nil
+}
+
+// queryStrings calls fn with the first column of each row of the query.
+func queryStrings(db *sql.DB, query string, fn func(string)) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("error closing rows", "error", err)
+		}
+	}()
+
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return err
+		}
+		fn(value)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,223 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCompanyCSV writes a Companies House CSV with one row per company,
+// each given as number, status, category, postcode, incorporation date and
+// dissolution date.
+func writeCompanyCSV(t *testing.T, companies [][6]string) string {
+	t.Helper()
+	records := make([][]string, len(companies))
+	for i, company := range companies {
+		record := make([]string, len(companyDataColumns))
+		record[0] = "Company " + company[0]
+		record[1] = company[0]
+		record[11] = company[1]
+		record[10] = company[2]
+		record[9] = company[3]
+		record[14] = company[4]
+		record[13] = company[5]
+		records[i] = record
+	}
+	return writeCompanyRecords(t, records)
+}
+
+// writeCompanyRecords writes a Companies House CSV file with a header row.
+func writeCompanyRecords(t *testing.T, records [][]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "companies.csv")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+
+	w := csv.NewWriter(f)
+	require.NoError(t, w.Write(companyDataHeaders()))
+	require.NoError(t, w.WriteAll(records))
+	require.NoError(t, f.Close())
+	return path
+}
+
+func TestDryRunCompanyData(t *testing.T) {
+	db := connectTestDB(t)
+	_, err := db.Exec(`INSERT INTO code_point (post_code, easting, northing) VALUES ('AB10 1AB', 394251, 806376)`)
+	require.NoError(t, err)
+	existing := writeCompanyCSV(t, [][6]string{
+		{"00000001", "Active", "PLC", "AB10 1AB", "01/01/2020", ""},
+		{"00000009", "Active", "PLC", "AB10 1AB", "01/01/2020", ""},
+	})
+	require.NoError(t, NewCompanyDataImporter(db).Import(existing, http.Header{}))
+
+	path := writeCompanyCSV(t, [][6]string{
+		{"00000001", "Active", "Private Limited Company", "AB10 1AB", "01/01/2020", ""},
+		{"00000002", "Active", "Private Limited Company", "", "01/01/2020", ""},
+		{"00000003", "Dissolved", "PLC", "NOT A POSTCODE", "01/01/2021", "01/01/2020"},
+		{"00000004", "Active", "PLC", "ZZ1 1ZZ", "", ""},
+		{"00000004", "Active", "PLC", "ZZ1 1ZZ", "01/01/2099", ""},
+	})
+
+	var report bytes.Buffer
+	err = NewCompanyDataImporter(db, WithDryRun(&report), WithFullRefresh(true), WithBulkLoad(true), WithWorkers(2)).Import(path, http.Header{})
+	require.NoError(t, err)
+
+	var actual QualityReport
+	require.NoError(t, json.Unmarshal(report.Bytes(), &actual))
+
+	assert.Equal(t, "companies-house", actual.Dataset)
+	assert.Equal(t, 5, actual.Rows)
+	assert.Equal(t, 2, actual.Throughput.Workers)
+	assert.Positive(t, actual.Throughput.ElapsedSeconds)
+	assert.Positive(t, actual.Throughput.RowsPerSecond)
+	assert.Positive(t, actual.Throughput.BytesPerSecond)
+	assert.Equal(t, DiffReport{
+		CurrentRows: 2,
+		Existing:    1,
+		New:         Finding{Count: 3, Samples: []string{"00000002", "00000003", "00000004"}},
+		Removed:     Finding{Count: 1, Samples: []string{"00000009"}},
+		Duplicates:  Finding{Count: 1, Samples: []string{"00000004"}},
+	}, actual.Diff)
+
+	companies := actual.Companies
+	require.NotNil(t, companies)
+	assert.Equal(t, map[string]int{"Active": 4, "Dissolved": 1}, companies.ByStatus)
+	assert.Equal(t, map[string]int{"Private Limited Company": 2, "PLC": 3}, companies.ByCategory)
+	assert.Equal(t, Finding{Count: 1, Samples: []string{"00000002"}}, companies.MissingPostcodes)
+	assert.Equal(t, Finding{Count: 1, Samples: []string{"00000003"}}, companies.InvalidPostcodes)
+	assert.Equal(t, &Finding{Count: 2, Samples: []string{"00000004", "00000004"}}, companies.UnmatchedPostcodes)
+	assert.Equal(t, Finding{Count: 1, Samples: []string{"00000004"}}, companies.MissingIncorporationDate)
+	assert.Equal(t, Finding{Count: 1, Samples: []string{"00000003"}}, companies.IncorporatedAfterDissolution)
+	assert.Equal(t, Finding{Count: 1, Samples: []string{"00000004"}}, companies.IncorporatedInFuture)
+
+	// Nothing was written.
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM company_data").Scan(&count))
+	assert.Equal(t, 2, count)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'import_seen_company_data'").Scan(&count))
+	assert.Zero(t, count)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = 'company_data'").Scan(&count))
+	assert.NotZero(t, count, "indexes should not have been dropped")
+}
+
+func TestDryRunReportsFilteredRowsSeparately(t *testing.T) {
+	db := connectTestDB(t)
+	existing := writeCompanyCSV(t, [][6]string{
+		{"00000001", "Active", "PLC", "AB10 1AB", "01/01/2020", ""},
+		{"00000002", "Active", "PLC", "AB10 1AB", "01/01/2020", ""},
+		{"00000009", "Active", "PLC", "AB10 1AB", "01/01/2020", ""},
+	})
+	require.NoError(t, NewCompanyDataImporter(db).Import(existing, http.Header{}))
+
+	path := writeCompanyCSV(t, [][6]string{
+		{"00000001", "Active", "PLC", "AB10 1AB", "01/01/2020", ""},
+		{"00000002", "Dissolved", "PLC", "AB10 1AB", "01/01/2020", "01/01/2025"},
+		{"00000003", "Dissolved", "PLC", "AB10 1AB", "01/01/2020", "01/01/2025"},
+	})
+
+	var report bytes.Buffer
+	err := NewCompanyDataImporter(db, WithDryRun(&report), WithFilter(Filter{CompanyStatuses: []string{"Active"}})).Import(path, http.Header{})
+	require.NoError(t, err)
+
+	var actual QualityReport
+	require.NoError(t, json.Unmarshal(report.Bytes(), &actual))
+	assert.Equal(t, 1, actual.Rows)
+	assert.Equal(t, 2, actual.FilteredRows)
+	assert.Equal(t, DiffReport{
+		CurrentRows: 3,
+		Existing:    1,
+		Removed:     Finding{Count: 1, Samples: []string{"00000009"}},
+		Filtered:    Finding{Count: 1, Samples: []string{"00000002"}},
+	}, actual.Diff)
+}
+
+func TestDryRunCountsCompanyChanges(t *testing.T) {
+	db := connectTestDB(t)
+	existing := writeCompanyRecords(t, [][]string{
+		companyRecord("00000001", "ONE LIMITED", "1 High Street", "AB10 1AB", "Active", ""),
+		companyRecord("00000002", "TWO LIMITED", "2 High Street", "AB10 1AB", "Active", ""),
+		companyRecord("00000003", "THREE LIMITED", "3 High Street", "AB10 1AB", "Active", ""),
+		withAccounts(companyRecord("00000004", "FOUR LIMITED", "4 High Street", "AB10 1AB", "Active", ""),
+			"31/12/2023", "62020 - Information technology consultancy activities"),
+		companyRecord("00000005", "FIVE LIMITED", "5 High Street", "AB10 1AB", "Active", ""),
+	})
+	require.NoError(t, NewCompanyDataImporter(db).Import(existing, http.Header{}))
+
+	path := writeCompanyRecords(t, [][]string{
+		companyRecord("00000001", "ONE RENAMED LIMITED", "1 High Street", "AB10 1AB", "Active", ""),
+		companyRecord("00000002", "TWO LIMITED", "9 Union Street", "AB11 6BA", "Liquidation", ""),
+		companyRecord("00000003", "THREE LIMITED", "3 High Street", "AB10 1AB", "Dissolved", "01/06/2025"),
+		withAccounts(companyRecord("00000004", "FOUR LIMITED", "4 High Street", "AB10 1AB", "Active", ""),
+			"31/12/2024", "62012 - Business and domestic software development"),
+		companyRecord("00000005", "FIVE LIMITED", "5 High Street", "AB10 1AB", "Active", ""),
+		companyRecord("00000006", "SIX LIMITED", "6 High Street", "AB10 1AB", "Active", ""),
+	})
+
+	var report bytes.Buffer
+	require.NoError(t, NewCompanyDataImporter(db, WithDryRun(&report)).Import(path, http.Header{}))
+
+	var actual QualityReport
+	require.NoError(t, json.Unmarshal(report.Bytes(), &actual))
+	assert.Equal(t, map[string]int{
+		models.ChangeRenamed:        1,
+		models.ChangeAddressChanged: 1,
+		models.ChangeStatusChanged:  1,
+		models.ChangeDissolved:      1,
+		models.ChangeSICChanged:     1,
+		models.ChangeAccountsFiled:  1,
+	}, actual.Diff.Changes)
+	assert.Equal(t, 1, actual.Diff.New.Count)
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM company_changes").Scan(&count))
+	assert.Zero(t, count, "nothing was recorded")
+}
+
+func TestDryRunWithoutCodePoints(t *testing.T) {
+	db := connectTestDB(t)
+	path := writeCompanyCSV(t, [][6]string{
+		{"00000001", "Active", "PLC", "AB10 1AB", "01/01/2020", ""},
+	})
+
+	var report bytes.Buffer
+	require.NoError(t, NewCompanyDataImporter(db, WithDryRun(&report)).Import(path, http.Header{}))
+
+	var actual QualityReport
+	require.NoError(t, json.Unmarshal(report.Bytes(), &actual))
+	assert.Nil(t, actual.Companies.UnmatchedPostcodes)
+	assert.NotContains(t, report.String(), "unmatched_postcodes")
+}
+
+func TestDryRunCodePoint(t *testing.T) {
+	db := connectTestDB(t)
+	path := filepath.Join(t.TempDir(), "codes.csv")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"\"AB10 1AB\",10,394251,806376,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n"+
+			"\"AB10 1AF\",90,0,0,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n"+
+			"\"XX\",10,394235,806529,\"E92000001\",\"\",\"\",\"\",\"\",\"\"\n",
+	), 0o644))
+
+	var report bytes.Buffer
+	require.NoError(t, NewCodePointImporter(db, WithDryRun(&report)).Import(path, http.Header{}))
+
+	var actual QualityReport
+	require.NoError(t, json.Unmarshal(report.Bytes(), &actual))
+	assert.Equal(t, 3, actual.Rows)
+	require.NotNil(t, actual.CodePoints)
+	assert.Equal(t, map[int]int{10: 2, 90: 1}, actual.CodePoints.ByPositionalQuality)
+	assert.Equal(t, map[string]int{"S92000003": 2, "E92000001": 1}, actual.CodePoints.ByCountry)
+	assert.Equal(t, Finding{Count: 1, Samples: []string{"XX"}}, actual.CodePoints.InvalidPostcodes)
+	assert.Equal(t, Finding{Count: 1, Samples: []string{"AB10 1AF"}}, actual.CodePoints.MissingCoordinates)
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM code_point").Scan(&count))
+	assert.Zero(t, count)
+}
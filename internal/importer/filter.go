@@ -0,0 +1,231 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Filter restricts an import to a subset of the dataset, so that compact
+// regional databases can be built. Rows that don't match are dropped before
+// they are written. An empty filter matches everything.
+//
+// Locations are British National Grid eastings and northings, as used by
+// CodePoint Open. Companies have no location of their own, so BoundingBox
+// and Polygon select companies whose registered postcode lies within the
+// area; CodePoint must therefore be imported first. CompanyStatuses and
+// SICPrefixes only apply to Companies House data.
+type Filter struct {
+	// BoundingBox is minEasting, minNorthing, maxEasting, maxNorthing.
+	BoundingBox []float64
+	// Polygon is a closed ring of easting, northing vertices.
+	Polygon [][2]float64
+	// PostcodeAreas are the leading letters of the postcode, e.g. "AB" or "E".
+	PostcodeAreas []string
+	// CompanyStatuses match the CompanyStatus column exactly, ignoring case.
+	CompanyStatuses []string
+	// SICPrefixes match the start of any of the four SIC code columns.
+	SICPrefixes []string
+}
+
+// WithFilter drops rows that don't match the filter.
+func WithFilter(filter Filter) Option {
+	return func(cfg *config) {
+		cfg.filter = filter
+	}
+}
+
+func (f Filter) isEmpty() bool {
+	return !f.hasArea() && len(f.PostcodeAreas) == 0 && len(f.CompanyStatuses) == 0 && len(f.SICPrefixes) == 0
+}
+
+func (f Filter) hasArea() bool {
+	return len(f.BoundingBox) > 0 || len(f.Polygon) > 0
+}
+
+// containsPoint reports whether the point lies within the bounding box and
+// polygon, where set.
+func (f Filter) containsPoint(easting float64, northing float64) bool {
+	if len(f.BoundingBox) == 4 {
+		if easting < f.BoundingBox[0] || easting > f.BoundingBox[2] ||
+			northing < f.BoundingBox[1] || northing > f.BoundingBox[3] {
+			return false
+		}
+	}
+	if len(f.Polygon) > 0 && !pointInPolygon(f.Polygon, easting, northing) {
+		return false
+	}
+	return true
+}
+
+// areaBounds returns the bounding box enclosing the area filter, for use in
+// an indexed range query.
+func (f Filter) areaBounds() []float64 {
+	if len(f.BoundingBox) == 4 {
+		return f.BoundingBox
+	}
+	bounds := []float64{f.Polygon[0][0], f.Polygon[0][1], f.Polygon[0][0], f.Polygon[0][1]}
+	for _, vertex := range f.Polygon[1:] {
+		bounds[0] = min(bounds[0], vertex[0])
+		bounds[1] = min(bounds[1], vertex[1])
+		bounds[2] = max(bounds[2], vertex[0])
+		bounds[3] = max(bounds[3], vertex[1])
+	}
+	return bounds
+}
+
+func (f Filter) matchesPostcodeArea(postcode string) bool {
+	if len(f.PostcodeAreas) == 0 {
+		return true
+	}
+	area := postcodeArea(postcode)
+	for _, want := range f.PostcodeAreas {
+		if strings.EqualFold(area, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Filter) matchesCompanyStatus(status string) bool {
+	if len(f.CompanyStatuses) == 0 {
+		return true
+	}
+	for _, want := range f.CompanyStatuses {
+		if strings.EqualFold(strings.TrimSpace(status), want) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Filter) matchesSICCodes(sicCodes ...string) bool {
+	if len(f.SICPrefixes) == 0 {
+		return true
+	}
+	for _, sicCode := range sicCodes {
+		sicCode = strings.TrimSpace(sicCode)
+		if sicCode == "" {
+			continue
+		}
+		for _, prefix := range f.SICPrefixes {
+			if strings.HasPrefix(sicCode, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// postcodeArea returns the leading letters of a postcode, e.g. "AB" for
+// "AB10 1AB" and "E" for "E1 6AN".
+func postcodeArea(postcode string) string {
+	postcode = strings.TrimSpace(postcode)
+	end := 0
+	for end < len(postcode) && end < 2 && isLetter(postcode[end]) {
+		end++
+	}
+	return strings.ToUpper(postcode[:end])
+}
+
+func isLetter(b byte) bool {
+	return (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')
+}
+
+// pointInPolygon uses the even-odd rule: a point is inside if a ray cast
+// from it crosses the polygon's edges an odd number of times.
+func pointInPolygon(polygon [][2]float64, x float64, y float64) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		xi, yi := polygon[i][0], polygon[i][1]
+		xj, yj := polygon[j][0], polygon[j][1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// postcodesInArea returns the CodePoint postcodes that lie within the
+// filter's bounding box and polygon.
+func postcodesInArea(db *sql.DB, filter Filter) (map[string]bool, error) {
+	bounds := filter.areaBounds()
+	rows, err := db.Query(
+		"SELECT post_code, easting, northing FROM code_point WHERE easting BETWEEN ? AND ? AND northing BETWEEN ? AND ?",
+		bounds[0], bounds[2], bounds[1], bounds[3],
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up postcodes in area: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("error closing rows", "error", err)
+		}
+	}()
+
+	postcodes := make(map[string]bool)
+	for rows.Next() {
+		var postcode string
+		var easting, northing float64
+		if err := rows.Scan(&postcode, &easting, &northing); err != nil {
+			return nil, fmt.Errorf("failed to scan postcode: %w", err)
+		}
+		if filter.containsPoint(easting, northing) {
+			postcodes[postcode] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to look up postcodes in area: %w", err)
+	}
+	if len(postcodes) == 0 {
+		slog.Warn("No postcodes found in the filter area; has CodePoint been imported?")
+	}
+	return postcodes, nil
+}
+
+// ParseBoundingBox parses "minEasting,minNorthing,maxEasting,maxNorthing".
+func ParseBoundingBox(value string) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bbox must have 4 comma-separated values")
+	}
+	bbox := make([]float64, 4)
+	for i, part := range parts {
+		val, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bbox value '%s': not a valid float", part)
+		}
+		bbox[i] = val
+	}
+	if bbox[0] > bbox[2] || bbox[1] > bbox[3] {
+		return nil, fmt.Errorf("bbox minimums must not exceed its maximums")
+	}
+	return bbox, nil
+}
+
+// ParsePolygon parses semicolon-separated "easting,northing" vertices, e.g.
+// "390000,800000;400000,800000;400000,810000".
+func ParsePolygon(value string) ([][2]float64, error) {
+	var polygon [][2]float64
+	for vertex := range strings.SplitSeq(value, ";") {
+		parts := strings.Split(vertex, ",")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid polygon vertex '%s': expected easting,northing", vertex)
+		}
+		var point [2]float64
+		for i, part := range parts {
+			val, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid polygon value '%s': not a valid float", part)
+			}
+			point[i] = val
+		}
+		polygon = append(polygon, point)
+	}
+	if len(polygon) < 3 {
+		return nil, fmt.Errorf("polygon must have at least 3 vertices")
+	}
+	return polygon, nil
+}
@@ -0,0 +1,164 @@
+package importer
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostcodeArea(t *testing.T) {
+	assert.Equal(t, "AB", postcodeArea("AB10 1AB"))
+	assert.Equal(t, "E", postcodeArea("E1 6AN"))
+	assert.Equal(t, "EH", postcodeArea(" eh1 1aa"))
+	assert.Equal(t, "", postcodeArea(""))
+}
+
+func TestPointInPolygon(t *testing.T) {
+	triangle := [][2]float64{{0, 0}, {10, 0}, {0, 10}}
+
+	assert.True(t, pointInPolygon(triangle, 2, 2))
+	assert.False(t, pointInPolygon(triangle, 8, 8))
+	assert.False(t, pointInPolygon(triangle, -1, 5))
+}
+
+func TestFilterContainsPoint(t *testing.T) {
+	filter := Filter{
+		BoundingBox: []float64{0, 0, 100, 100},
+		Polygon:     [][2]float64{{0, 0}, {150, 0}, {0, 150}},
+	}
+
+	assert.True(t, filter.containsPoint(10, 10))
+	assert.False(t, filter.containsPoint(150, 10), "outside bbox")
+	assert.False(t, filter.containsPoint(90, 90), "outside polygon")
+	assert.Equal(t, []float64{0, 0, 100, 100}, filter.areaBounds())
+	assert.Equal(t, []float64{0, 0, 150, 150}, Filter{Polygon: filter.Polygon}.areaBounds())
+}
+
+func TestFilterMatchesCompanyFields(t *testing.T) {
+	filter := Filter{
+		CompanyStatuses: []string{"Active"},
+		SICPrefixes:     []string{"62"},
+	}
+
+	assert.True(t, filter.matchesCompanyStatus("active"))
+	assert.False(t, filter.matchesCompanyStatus("Dissolved"))
+	assert.True(t, filter.matchesSICCodes("", "62012 - Business and domestic software development"))
+	assert.False(t, filter.matchesSICCodes("56101 - Licensed restaurants", ""))
+	assert.True(t, Filter{}.matchesSICCodes(""))
+	assert.True(t, Filter{}.isEmpty())
+	assert.False(t, filter.isEmpty())
+}
+
+func TestParseBoundingBox(t *testing.T) {
+	bbox, err := ParseBoundingBox("390000, 800000,400000,810000")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{390000, 800000, 400000, 810000}, bbox)
+
+	_, err = ParseBoundingBox("1,2,3")
+	assert.EqualError(t, err, "bbox must have 4 comma-separated values")
+
+	_, err = ParseBoundingBox("1,2,x,4")
+	assert.EqualError(t, err, "invalid bbox value 'x': not a valid float")
+
+	_, err = ParseBoundingBox("10,2,3,4")
+	assert.EqualError(t, err, "bbox minimums must not exceed its maximums")
+}
+
+func TestParsePolygon(t *testing.T) {
+	polygon, err := ParsePolygon("0,0;10,0;0,10")
+	require.NoError(t, err)
+	assert.Equal(t, [][2]float64{{0, 0}, {10, 0}, {0, 10}}, polygon)
+
+	_, err = ParsePolygon("0,0;10,0")
+	assert.EqualError(t, err, "polygon must have at least 3 vertices")
+
+	_, err = ParsePolygon("0,0;10;0,10")
+	assert.EqualError(t, err, "invalid polygon vertex '10': expected easting,northing")
+}
+
+func TestImportCodePointWithFilter(t *testing.T) {
+	db := connectTestDB(t)
+	path := filepath.Join(t.TempDir(), "codes.csv")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"\"AB10 1AB\",10,394251,806376,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n"+
+			"\"AB10 1AF\",10,394235,806529,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n"+
+			"\"EH1 1AA\",10,325000,673000,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n"+
+			"\"AB99 1AA\",10,100000,100000,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n",
+	), 0o644))
+
+	buf, _ := setupSlogBuffer()
+	filter := Filter{
+		BoundingBox:   []float64{390000, 800000, 400000, 810000},
+		PostcodeAreas: []string{"ab"},
+	}
+	require.NoError(t, NewCodePointImporter(db, WithFilter(filter)).Import(path, http.Header{}))
+
+	var postcodes []string
+	rows, err := db.Query("SELECT post_code FROM code_point ORDER BY post_code")
+	require.NoError(t, err)
+	for rows.Next() {
+		var postcode string
+		require.NoError(t, rows.Scan(&postcode))
+		postcodes = append(postcodes, postcode)
+	}
+	require.NoError(t, rows.Close())
+
+	assert.Equal(t, []string{"AB10 1AB", "AB10 1AF"}, postcodes)
+	assert.Contains(t, buf.String(), `"filteredRecords":2`)
+}
+
+func TestCompanyDataMatcher(t *testing.T) {
+	db := connectTestDB(t)
+	_, err := db.Exec(`INSERT INTO code_point (post_code, easting, northing) VALUES ('AB10 1AB', 394251, 806376), ('EH1 1AA', 325000, 673000)`)
+	require.NoError(t, err)
+
+	keep, err := companyDataMatcher(db, Filter{
+		Polygon:         [][2]float64{{390000, 800000}, {400000, 800000}, {400000, 810000}, {390000, 810000}},
+		CompanyStatuses: []string{"Active"},
+		SICPrefixes:     []string{"62"},
+	})
+	require.NoError(t, err)
+
+	company := models.CompanyData{RegAddressPostCode: "AB10 1AB", CompanyStatus: "Active", SICCode1: "62012 - Software"}
+	assert.True(t, keep(company))
+
+	outside := company
+	outside.RegAddressPostCode = "EH1 1AA"
+	assert.False(t, keep(outside), "postcode outside the area")
+
+	unknown := company
+	unknown.RegAddressPostCode = "ZZ1 1ZZ"
+	assert.False(t, keep(unknown), "postcode not in CodePoint")
+
+	dissolved := company
+	dissolved.CompanyStatus = "Dissolved"
+	assert.False(t, keep(dissolved), "status does not match")
+
+	restaurant := company
+	restaurant.SICCode1 = "56101 - Licensed restaurants"
+	assert.False(t, keep(restaurant), "SIC code does not match")
+}
+
+func TestImportCompanyDataWithFilter(t *testing.T) {
+	db := connectTestDB(t)
+	zipPath := createTestZip(t, 3)
+	defer func() {
+		assert.NoError(t, os.Remove(zipPath))
+	}()
+
+	require.NoError(t, NewCompanyDataImporter(db, WithFilter(Filter{CompanyStatuses: []string{"dissolved"}})).Import(zipPath, http.Header{}))
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM company_data").Scan(&count))
+	assert.Zero(t, count)
+
+	require.NoError(t, NewCompanyDataImporter(db, WithFilter(Filter{SICPrefixes: []string{"sic"}})).Import(zipPath, http.Header{}))
+
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM company_data").Scan(&count))
+	assert.Equal(t, 3, count)
+}
@@ -0,0 +1,140 @@
+package importer
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCodePointCSV(t *testing.T, lines string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "codes.csv")
+	require.NoError(t, os.WriteFile(path, []byte(lines), 0o644))
+	return path
+}
+
+type companyLocation struct {
+	Easting   sql.NullInt64
+	Northing  sql.NullInt64
+	MortonKey sql.NullInt64
+	Precision sql.NullString
+}
+
+func locationOf(t *testing.T, db *sql.DB, companyNumber string) companyLocation {
+	t.Helper()
+	var location companyLocation
+	require.NoError(t, db.QueryRow("SELECT easting, northing, morton_key, location_precision FROM company_data WHERE company_number = ?", companyNumber).
+		Scan(&location.Easting, &location.Northing, &location.MortonKey, &location.Precision))
+	return location
+}
+
+func located(easting int64, northing int64, precision string) companyLocation {
+	return companyLocation{
+		Easting:   sql.NullInt64{Int64: easting, Valid: true},
+		Northing:  sql.NullInt64{Int64: northing, Valid: true},
+		MortonKey: sql.NullInt64{Int64: internal.MortonKey(int(easting), int(northing)), Valid: true},
+		Precision: sql.NullString{String: precision, Valid: true},
+	}
+}
+
+func TestImportsLocateCompanies(t *testing.T) {
+	db := connectTestDB(t)
+
+	// CodePoint pads outward codes to four characters.
+	codePoints := writeCodePointCSV(t,
+		"\"AB101AB\",10,394251,806376,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n"+
+			"\"AB101AF\",10,394300,806400,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n"+
+			"\"AB106RN\",10,394000,805000,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n"+
+			"\"AB106ZZ\",90,0,0,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n")
+	require.NoError(t, NewCodePointImporter(db).Import(codePoints, http.Header{}))
+
+	companies := writeCompanyRecords(t, [][]string{
+		companyRecord("00000001", "ONE LIMITED", "1 High Street", "AB10 1AB", "Active", ""),
+		companyRecord("00000002", "TWO LIMITED", "2 High Street", "AB10 1ZZ", "Active", ""),
+		companyRecord("00000003", "THREE LIMITED", "3 High Street", "AB10 9ZZ", "Active", ""),
+		companyRecord("00000004", "FOUR LIMITED", "4 High Street", "ZZ99 9ZZ", "Active", ""),
+		companyRecord("00000005", "FIVE LIMITED", "5 High Street", "ab101ab", "Active", ""),
+		companyRecord("00000006", "SIX LIMITED", "6 High Street", "AB10 6ZZ", "Active", ""),
+	})
+	require.NoError(t, NewCompanyDataImporter(db).Import(companies, http.Header{}))
+
+	assert.Equal(t, located(394251, 806376, "postcode"), locationOf(t, db, "00000001"))
+	assert.Equal(t, located(394276, 806388, "sector"), locationOf(t, db, "00000002"))
+	assert.Equal(t, located(394184, 805925, "district"), locationOf(t, db, "00000003"))
+	assert.Equal(t, companyLocation{}, locationOf(t, db, "00000004"), "the postcode did not geocode")
+	assert.Equal(t, located(394251, 806376, "postcode"), locationOf(t, db, "00000005"), "the postcode is normalised")
+	assert.Equal(t, located(394000, 805000, "sector"), locationOf(t, db, "00000006"), "the postcode has no coordinates")
+
+	// A new CodePoint release adds one postcode and moves another.
+	codePoints = writeCodePointCSV(t,
+		"\"AB101AB\",10,394200,806300,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n"+
+			"\"AB101ZZ\",10,394500,806500,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n")
+	require.NoError(t, NewCodePointImporter(db).Import(codePoints, http.Header{}))
+
+	assert.Equal(t, located(394200, 806300, "postcode"), locationOf(t, db, "00000001"))
+	assert.Equal(t, located(394500, 806500, "postcode"), locationOf(t, db, "00000002"))
+	assert.Equal(t, located(394250, 806050, "district"), locationOf(t, db, "00000003"), "the district centroid moves too")
+}
+
+// changeLocations returns the locations recorded on the changes to a company,
+// new then previous.
+func changeLocations(t *testing.T, db *sql.DB, companyNumber string) [][4]sql.NullInt64 {
+	t.Helper()
+	rows, err := db.Query("SELECT easting, northing, previous_easting, previous_northing FROM company_changes WHERE company_number = ? ORDER BY id", companyNumber)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, rows.Close())
+	}()
+	var locations [][4]sql.NullInt64
+	for rows.Next() {
+		var location [4]sql.NullInt64
+		require.NoError(t, rows.Scan(&location[0], &location[1], &location[2], &location[3]))
+		locations = append(locations, location)
+	}
+	require.NoError(t, rows.Err())
+	return locations
+}
+
+func TestImportsLocateCompanyChanges(t *testing.T) {
+	db := connectTestDB(t)
+
+	codePoints := writeCodePointCSV(t,
+		"\"AB101AB\",10,394251,806376,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n"+
+			"\"AB101AF\",10,394300,806400,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n"+
+			"\"AB106RN\",10,394000,805000,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n")
+	require.NoError(t, NewCodePointImporter(db).Import(codePoints, http.Header{}))
+
+	initial := writeCompanyRecords(t, [][]string{
+		companyRecord("00000001", "ONE LIMITED", "1 High Street", "AB10 1AB", "Active", ""),
+		companyRecord("00000002", "TWO LIMITED", "2 High Street", "AB10 1ZZ", "Active", ""),
+		companyRecord("00000003", "THREE LIMITED", "3 High Street", "AB10 1AB", "Active", ""),
+	})
+	require.NoError(t, NewCompanyDataImporter(db, WithFullRefresh(true)).Import(initial, http.Header{}))
+
+	// AB10 1ZZ is located at its sector centroid and AB10 9ZZ at its
+	// district centroid, neither being in CodePoint.
+	next := writeCompanyRecords(t, [][]string{
+		companyRecord("00000001", "ONE LIMITED", "1 Side Street", "AB10 1ZZ", "Active", ""),
+		companyRecord("00000002", "TWO RENAMED LIMITED", "2 High Street", "AB10 1ZZ", "Active", ""),
+		companyRecord("00000004", "FOUR LIMITED", "4 High Street", "AB10 9ZZ", "Active", ""),
+	})
+	require.NoError(t, NewCompanyDataImporter(db, WithFullRefresh(true)).Import(next, http.Header{}))
+
+	point := func(easting int64, northing int64) [2]sql.NullInt64 {
+		return [2]sql.NullInt64{{Int64: easting, Valid: true}, {Int64: northing, Valid: true}}
+	}
+	change := func(location [2]sql.NullInt64, previous [2]sql.NullInt64) [4]sql.NullInt64 {
+		return [4]sql.NullInt64{location[0], location[1], previous[0], previous[1]}
+	}
+	none := [2]sql.NullInt64{}
+	assert.Equal(t, [][4]sql.NullInt64{change(point(394276, 806388), point(394251, 806376))}, changeLocations(t, db, "00000001"), "moved to a sector centroid")
+	assert.Equal(t, [][4]sql.NullInt64{change(point(394276, 806388), none)}, changeLocations(t, db, "00000002"), "renamed at a sector centroid")
+	assert.Equal(t, [][4]sql.NullInt64{change(point(394251, 806376), none)}, changeLocations(t, db, "00000003"), "removed")
+	assert.Equal(t, [][4]sql.NullInt64{change(point(394184, 805925), none)}, changeLocations(t, db, "00000004"), "incorporated at a district centroid")
+}
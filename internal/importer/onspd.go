@@ -0,0 +1,164 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/map-services/company-data-api/internal"
+)
+
+// The ONS Postcode Directory (ONSPD), and the National Statistics Postcode
+// Lookup (NSPL) derived from it, cover the whole UK and the Crown
+// Dependencies, so fill the gaps in CodePoint Open, which is GB-only. Their
+// postcodes are written to code_point alongside CodePoint's, with their
+// source recorded, and are located the same way.
+//
+// Only the columns below are imported; the rest (census and electoral
+// geographies, deprivation indices and so on) are ignored.
+var onspdColumns = []string{
+	"pcds", "oseast1m", "osnrth1m", "osgrdind", "lat", "long",
+	"ctry", "nhser", "oshlthau", "oscty", "oslaua", "osward",
+}
+
+const (
+	// northernIreland is the GSS country code of Northern Ireland, whose
+	// grid references are on the Irish Grid.
+	northernIreland = "N92000002"
+	// noLatitude is the latitude given to postcodes without a location.
+	noLatitude = "99.999999"
+)
+
+var onspdMapping = &columnMapping{
+	expected: onspdColumns,
+	// NSPL names the administrative geographies without the "os" prefix.
+	aliases: map[string]string{
+		"hlthau": "oshlthau",
+		"cty":    "oscty",
+		"laua":   "oslaua",
+		"ward":   "osward",
+	},
+	ignoreUnexpected: true,
+}
+
+var onspdDataset = &csvDataset[CodePoint]{
+	name:          "onspd",
+	description:   "ONS Postcode Directory or NSPL postcode locations, including Northern Ireland",
+	defaultSource: "./data/onspd.zip",
+	table:         "code_point",
+	keyColumn:     "post_code",
+	insertSQL:     internal.InsertONSPDPostcodeSQL,
+	owns:          "source = 'onspd'",
+	// The zip holds the whole directory in one file, and again split by
+	// postcode area under Data/multi_csv/.
+	selects: func(name string) bool {
+		return strings.HasPrefix(name, "Data/") && isCSVFile(name) && !strings.Contains(name, "/multi_csv/")
+	},
+	columns: onspdMapping,
+	parse:   onspdFromRecord,
+	toTuple: codePointToTuple,
+	key: func(codePoint CodePoint) string {
+		return codePoint.PostCode
+	},
+	matcher:   codePointMatcher,
+	inspector: newCodePointInspector,
+	finish:    indexCodePoints,
+}
+
+func init() {
+	Register(onspdDataset)
+}
+
+func NewONSPDImporter(db *sql.DB, opts ...Option) *csvImporter[CodePoint] {
+	return onspdDataset.newImporter(db, opts...)
+}
+
+// onspdFromRecord parses an ONSPD or NSPL record into the CodePoint form. The
+// grid reference indicator has the same meaning as CodePoint's positional
+// quality, on a scale of 1 to 9 rather than 10 to 90.
+func onspdFromRecord(record []string, columns columnIndex) (*CodePoint, error) {
+	field := func(name string) string {
+		return strings.TrimSpace(record[columns[name]])
+	}
+
+	indicator, err := parseInt(field("osgrdind"))
+	if err != nil {
+		return nil, err
+	}
+	codePoint := &CodePoint{
+		PostCode:          internal.NormalisePostcode(field("pcds")),
+		PositionalQuality: indicator * 10,
+		CountryCode:       onspdCode(field("ctry")),
+		NHSRegionCode:     onspdCode(field("nhser")),
+		NHSHACode:         onspdCode(field("oshlthau")),
+		CountyCode:        onspdCode(field("oscty")),
+		DistrictCode:      onspdCode(field("oslaua")),
+		WardCode:          onspdCode(field("osward")),
+	}
+
+	easting, northing, ok, err := onspdLocation(field, codePoint.CountryCode)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		codePoint.PositionalQuality = 90
+		return codePoint, nil
+	}
+	codePoint.Easting, codePoint.Northing = int(math.Round(easting)), int(math.Round(northing))
+	if codePoint.PositionalQuality == 90 {
+		// Located by latitude and longitude, to no stated quality.
+		codePoint.PositionalQuality = 0
+	}
+	return codePoint, nil
+}
+
+// onspdLocation returns the British National Grid location of a record. Grid
+// references in Northern Ireland are on the Irish Grid and are converted.
+// Postcodes without one, in the Channel Islands and the Isle of Man, are
+// located by latitude and longitude where they have them. Locations south or
+// west of the grid's origin, such as the Channel Islands, have negative
+// coordinates the search index cannot hold, and are treated as having none.
+func onspdLocation(field func(string) string, country string) (float64, float64, bool, error) {
+	if field("oseast1m") != "" && field("osnrth1m") != "" && field("osgrdind") != "9" {
+		easting, err := strconv.ParseFloat(field("oseast1m"), 64)
+		if err != nil {
+			return 0, 0, false, fmt.Errorf("failed to parse easting %q: %w", field("oseast1m"), err)
+		}
+		northing, err := strconv.ParseFloat(field("osnrth1m"), 64)
+		if err != nil {
+			return 0, 0, false, fmt.Errorf("failed to parse northing %q: %w", field("osnrth1m"), err)
+		}
+		if country == northernIreland {
+			easting, northing = internal.IrishGridToBNG(easting, northing)
+		}
+		return easting, northing, true, nil
+	}
+
+	if field("lat") == "" || field("long") == "" || field("lat") == noLatitude {
+		return 0, 0, false, nil
+	}
+	lat, err := strconv.ParseFloat(field("lat"), 64)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to parse latitude %q: %w", field("lat"), err)
+	}
+	lon, err := strconv.ParseFloat(field("long"), 64)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to parse longitude %q: %w", field("long"), err)
+	}
+	easting, northing := internal.WGS84ToBNG(lat, lon)
+	if math.Round(easting) < 0 || math.Round(northing) < 0 {
+		return 0, 0, false, nil
+	}
+	return easting, northing, true, nil
+}
+
+// onspdCode blanks the pseudo codes ("N99999999", "L99999999") ONSPD gives
+// postcodes outside a geography, as CodePoint leaves them empty.
+func onspdCode(code string) string {
+	if strings.HasSuffix(code, "99999999") {
+		return ""
+	}
+	return code
+}
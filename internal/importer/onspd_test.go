@@ -0,0 +1,200 @@
+package importer
+
+import (
+	"archive/zip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// onspdHeader has a handful of the columns that are not imported.
+const onspdHeader = "pcd,pcds,dointr,doterm,oscty,oslaua,osward,oseast1m,osnrth1m,osgrdind,oshlthau,nhser,ctry,lsoa21,lat,long\n"
+
+// writeONSPDZip writes an ONSPD release with the given data rows, and a
+// malformed copy of them under multi_csv that must not be read.
+func writeONSPDZip(t *testing.T, rows string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "onspd.zip")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"Data/ONSPD_TEST_UK.csv":              onspdHeader + rows,
+		"Data/multi_csv/ONSPD_TEST_UK_BT.csv": "not,the,same,columns\n",
+		"Documents/LA_UA names and codes.csv": "LAD23CD,LAD23NM\n",
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+	return path
+}
+
+func parseONSPD(t *testing.T, header string, row string) *CodePoint {
+	t.Helper()
+	codePoint, err := onspdDataset.newRecordParser(nil).parse(strings.Split(row, ","), strings.Split(header, ","))
+	require.NoError(t, err)
+	return codePoint
+}
+
+func TestONSPDFromRecord(t *testing.T) {
+	header := strings.TrimSpace(onspdHeader)
+
+	gb := parseONSPD(t, header, "AB101AB,AB10 1AB,198001,,S99999999,S12000033,S13002842,394251,806376,1,S08000020,S99999999,S92000003,S01006514,57.148233,-2.096648")
+	assert.Equal(t, CodePoint{
+		PostCode:          "AB10 1AB",
+		PositionalQuality: 10,
+		Easting:           394251,
+		Northing:          806376,
+		CountryCode:       "S92000003",
+		NHSHACode:         "S08000020",
+		DistrictCode:      "S12000033",
+		WardCode:          "S13002842",
+	}, *gb)
+
+	// Northern Ireland grid references are on the Irish Grid.
+	ni := parseONSPD(t, header, "BT1 5GS,BT1 5GS,198001,,N99999999,N09000003,N08000312,333831,374004,1,ZC0000001,N99999999,N92000002,95AA01S1,54.596550,-5.930090")
+	easting, northing := internal.WGS84ToBNG(54.59655, -5.93009)
+	assert.InDelta(t, easting, ni.Easting, 1)
+	assert.InDelta(t, northing, ni.Northing, 1)
+	assert.Equal(t, 10, ni.PositionalQuality)
+	assert.Equal(t, "N09000003", ni.DistrictCode)
+	assert.Empty(t, ni.CountyCode)
+
+	// The Channel Islands and Isle of Man have no grid references.
+	jersey := parseONSPD(t, header, "JE2 3AB,JE2 3AB,199601,,L99999999,L99999999,L99999999,,,9,L99999999,L99999999,L93000001,L99999999,99.999999,0.000000")
+	assert.Equal(t, CodePoint{PostCode: "JE2 3AB", PositionalQuality: 90, CountryCode: "L93000001"}, *jersey)
+	douglas := parseONSPD(t, header, "IM1 1AA,IM1 1AA,198001,,L99999999,L99999999,L99999999,,,9,L99999999,L99999999,L93000001,L99999999,54.150000,-4.480000")
+	easting, northing = internal.WGS84ToBNG(54.15, -4.48)
+	assert.InDelta(t, easting, douglas.Easting, 1)
+	assert.InDelta(t, northing, douglas.Northing, 1)
+	assert.Equal(t, 0, douglas.PositionalQuality)
+
+	// The Channel Islands lie south of the National Grid's origin.
+	for _, record := range []string{
+		"JE2 4WE,JE2 4WE,199601,,L99999999,L99999999,L99999999,,,9,L99999999,L99999999,L93000001,L99999999,49.183600,-2.106400",
+		"GY1 1AA,GY1 1AA,199601,,L99999999,L99999999,L99999999,,,9,L99999999,L99999999,L93000001,L99999999,49.456500,-2.537000",
+	} {
+		channelIslands := parseONSPD(t, header, record)
+		assert.Equal(t, 90, channelIslands.PositionalQuality, channelIslands.PostCode)
+		assert.Zero(t, channelIslands.Northing, channelIslands.PostCode)
+	}
+
+	// NSPL names some columns differently.
+	nsplHeader := "pcds,oseast1m,osnrth1m,osgrdind,lat,long,ctry,nhser,hlthau,cty,laua,ward,usertype"
+	nspl := parseONSPD(t, nsplHeader, "E1 6AN,533575,181856,1,51.518561,-0.074373,E92000001,E40000003,E18000007,E99999999,E09000030,E05009317,0")
+	assert.Equal(t, "E09000030", nspl.DistrictCode)
+	assert.Equal(t, 533575, nspl.Easting)
+}
+
+func TestONSPDFromRecordSchemaDrift(t *testing.T) {
+	_, err := onspdDataset.newRecordParser(nil).parse([]string{"E1 6AN", "533575"}, []string{"pcds", "oseast1m"})
+	var drift *SchemaDriftError
+	require.True(t, errors.As(err, &drift))
+	assert.Contains(t, drift.Missing, "osnrth1m")
+	assert.Empty(t, drift.Unexpected)
+}
+
+func sourceOf(t *testing.T, db *sql.DB, postcode string) string {
+	t.Helper()
+	var source string
+	require.NoError(t, db.QueryRow("SELECT source FROM code_point WHERE post_code = ?", postcode).Scan(&source))
+	return source
+}
+
+func TestImportsONSPDAlongsideCodePoint(t *testing.T) {
+	db := connectTestDB(t)
+
+	codePoints := writeCodePointCSV(t, "\"AB101AB\",10,394251,806376,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n")
+	require.NoError(t, NewCodePointImporter(db).Import(codePoints, http.Header{}))
+
+	companies := writeCompanyRecords(t, [][]string{
+		companyRecord("00000001", "ABERDEEN LIMITED", "1 High Street", "AB10 1AB", "Active", ""),
+		companyRecord("NI000002", "BELFAST LIMITED", "2 High Street", "BT1 5GS", "Active", ""),
+		companyRecord("NI000003", "BELFAST TWO LIMITED", "3 High Street", "BT1 5ZZ", "Active", ""),
+		companyRecord("FC000004", "JERSEY LIMITED", "4 High Street", "JE2 3AB", "Active", ""),
+		companyRecord("FC000005", "GUERNSEY LIMITED", "5 High Street", "GY1 1AA", "Active", ""),
+	})
+	require.NoError(t, NewCompanyDataImporter(db).Import(companies, http.Header{}))
+	assert.Equal(t, companyLocation{}, locationOf(t, db, "NI000002"))
+
+	onspd := writeONSPDZip(t,
+		"BT1 5GS,BT1 5GS,198001,,N99999999,N09000003,N08000312,333831,374004,1,ZC0000001,N99999999,N92000002,95AA01S1,54.596550,-5.930090\n"+
+			"JE2 3AB,JE2 3AB,199601,,L99999999,L99999999,L99999999,,,9,L99999999,L99999999,L93000001,L99999999,99.999999,0.000000\n"+
+			"GY1 1AA,GY1 1AA,199601,,L99999999,L99999999,L99999999,,,9,L99999999,L99999999,L93000001,L99999999,49.456500,-2.537000\n")
+	require.NoError(t, NewONSPDImporter(db, WithFullRefresh(true)).Import(onspd, http.Header{}))
+
+	assert.Equal(t, "codepoint", sourceOf(t, db, "AB10 1AB"))
+	assert.Equal(t, "onspd", sourceOf(t, db, "BT1 5GS"))
+
+	easting, northing := internal.IrishGridToBNG(333831, 374004)
+	belfast := located(int64(math.Round(easting)), int64(math.Round(northing)), "postcode")
+	assert.Equal(t, belfast, locationOf(t, db, "NI000002"))
+	belfast.Precision.String = "sector"
+	assert.Equal(t, belfast, locationOf(t, db, "NI000003"))
+	assert.Equal(t, companyLocation{}, locationOf(t, db, "FC000004"), "ONSPD has no location for the postcode")
+	assert.Equal(t, companyLocation{}, locationOf(t, db, "FC000005"), "the postcode is off the National Grid")
+	assert.Equal(t, internal.UnmatchedOutsideCoverage, internal.UnmatchedReason("GY1 1AA"))
+
+	// Neither dataset's full refresh removes the other's postcodes.
+	require.NoError(t, NewCodePointImporter(db, WithFullRefresh(true)).Import(codePoints, http.Header{}))
+	assert.Equal(t, "onspd", sourceOf(t, db, "BT1 5GS"))
+	assert.Equal(t, located(394251, 806376, "postcode"), locationOf(t, db, "00000001"))
+	assert.Equal(t, belfast.Easting, locationOf(t, db, "NI000002").Easting)
+}
+
+func TestCodePointTakesPrecedenceOverONSPD(t *testing.T) {
+	codePoints := writeCodePointCSV(t, "\"AB101AB\",10,394251,806376,\"S92000003\",\"\",\"\",\"\",\"\",\"\"\n")
+	// ONSPD has both postcodes, one of them also in CodePoint but placed
+	// slightly differently.
+	onspd := func(easting int) string {
+		return writeONSPDZip(t,
+			"AB10 1AB,AB10 1AB,198001,,S99999999,S12000033,S13002842,394260,806380,1,S08000020,S99999999,S92000003,S01006514,57.1,-2.1\n"+
+				fmt.Sprintf("AB10 1AD,AB10 1AD,198001,,S99999999,S12000033,S13002842,%d,806400,1,S08000020,S99999999,S92000003,S01006514,57.1,-2.1\n", easting))
+	}
+	eastingOf := func(db *sql.DB, postcode string) int {
+		t.Helper()
+		var easting int
+		require.NoError(t, db.QueryRow("SELECT easting FROM code_point WHERE post_code = ?", postcode).Scan(&easting))
+		return easting
+	}
+
+	for name, codePointFirst := range map[string]bool{"CodePoint first": true, "ONSPD first": false} {
+		t.Run(name, func(t *testing.T) {
+			db := connectTestDB(t)
+			importCodePoint := func() {
+				require.NoError(t, NewCodePointImporter(db, WithFullRefresh(true)).Import(codePoints, http.Header{}))
+			}
+			if codePointFirst {
+				importCodePoint()
+			}
+			require.NoError(t, NewONSPDImporter(db, WithFullRefresh(true), WithBulkLoad(true)).Import(onspd(394300), http.Header{}))
+			if !codePointFirst {
+				importCodePoint()
+			}
+
+			assert.Equal(t, "codepoint", sourceOf(t, db, "AB10 1AB"))
+			assert.Equal(t, 394251, eastingOf(db, "AB10 1AB"))
+			assert.Equal(t, "onspd", sourceOf(t, db, "AB10 1AD"))
+			assert.Equal(t, 394300, eastingOf(db, "AB10 1AD"))
+
+			// ONSPD still updates the postcodes it imported.
+			require.NoError(t, NewONSPDImporter(db, WithFullRefresh(true)).Import(onspd(394310), http.Header{}))
+			assert.Equal(t, 394251, eastingOf(db, "AB10 1AB"))
+			assert.Equal(t, 394310, eastingOf(db, "AB10 1AD"))
+		})
+	}
+}
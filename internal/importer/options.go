@@ -0,0 +1,67 @@
+package importer
+
+import (
+	"io"
+	"runtime"
+)
+
+// Option configures optional behaviour shared by the importers.
+type Option func(*config)
+
+type config struct {
+	batchSize   int
+	workers     int
+	fullRefresh bool
+	bulkLoad    bool
+	filter      Filter
+	// dryRunReport, when set, receives the quality report of a dry run.
+	dryRunReport io.Writer
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{
+		batchSize: 5000,
+		workers:   runtime.GOMAXPROCS(0),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithFullRefresh treats the import as a complete snapshot of the dataset:
+// any existing rows whose key was not seen during the run are removed once
+// every file has been processed successfully.
+func WithFullRefresh(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.fullRefresh = enabled
+	}
+}
+
+// WithBatchSize sets how many rows are written per transaction.
+func WithBatchSize(size int) Option {
+	return func(cfg *config) {
+		if size > 0 {
+			cfg.batchSize = size
+		}
+	}
+}
+
+// WithWorkers sets how many goroutines convert CSV records in parallel while
+// a single writer inserts them. It defaults to GOMAXPROCS.
+func WithWorkers(workers int) Option {
+	return func(cfg *config) {
+		if workers > 0 {
+			cfg.workers = workers
+		}
+	}
+}
+
+// WithBulkLoad enables the bulk-load fast path: import-time PRAGMAs, secondary
+// indexes dropped and rebuilt around the load, and multi-row INSERTs. Safe
+// settings are restored when the import finishes.
+func WithBulkLoad(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.bulkLoad = enabled
+	}
+}
@@ -0,0 +1,239 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// StdinSource is the source path that reads the dataset from standard input.
+const StdinSource = "-"
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// sourceFile is a single file within an import source. Names use forward
+// slashes and are relative to the root of the zip file or directory. Size is
+// the uncompressed size in bytes, or -1 if it isn't known up front.
+type sourceFile struct {
+	name string
+	size int64
+	open func() (io.ReadCloser, error)
+}
+
+// source is the set of files making up an import: the entries of a zip file
+// or directory, or a single (possibly gzip-compressed) CSV file.
+type source struct {
+	files []sourceFile
+	// single is set when the source is a lone CSV file, which is imported
+	// whatever its name.
+	single bool
+	close  func() error
+}
+
+func (s *source) Close() error {
+	if s.close == nil {
+		return nil
+	}
+	return s.close()
+}
+
+// openSource opens a zip file, directory, CSV file or gzip-compressed CSV
+// file, or reads from stdin when path is "-". Files are identified by their
+// magic bytes, so no particular extension is required.
+func openSource(path string) (*source, error) {
+	if path == StdinSource {
+		return openStdin()
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source: %w", err)
+	}
+	if info.IsDir() {
+		return openDirectory(path)
+	}
+
+	magic, err := readMagic(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, zipMagic):
+		return openZip(path)
+	case bytes.HasPrefix(magic, gzipMagic):
+		return singleFileSource(strings.TrimSuffix(filepath.Base(path), ".gz"), -1, func() (io.ReadCloser, error) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			return newGzipReadCloser(f)
+		}), nil
+	default:
+		return singleFileSource(filepath.Base(path), info.Size(), func() (io.ReadCloser, error) {
+			return os.Open(path)
+		}), nil
+	}
+}
+
+func readMagic(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			slog.Error("error closing source file", "error", err)
+		}
+	}()
+
+	magic := make([]byte, len(zipMagic))
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read source: %w", err)
+	}
+	return magic[:n], nil
+}
+
+func openZip(path string) (*source, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip file: %w", err)
+	}
+
+	src := &source{close: r.Close}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		src.files = append(src.files, sourceFile{name: f.Name, size: int64(f.UncompressedSize64), open: f.Open})
+	}
+	return src, nil
+}
+
+func openDirectory(root string) (*source, error) {
+	src := &source{}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		src.files = append(src.files, sourceFile{
+			name: filepath.ToSlash(rel),
+			size: info.Size(),
+			open: func() (io.ReadCloser, error) {
+				return os.Open(path)
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", root, err)
+	}
+	return src, nil
+}
+
+// openStdin detects the format of standard input. A zip file can only be
+// read with random access, so it is first copied to a temporary file.
+func openStdin() (*source, error) {
+	r := bufio.NewReader(os.Stdin)
+	magic, err := r.Peek(len(zipMagic))
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, zipMagic):
+		return spoolZip(r)
+	case bytes.HasPrefix(magic, gzipMagic):
+		return singleFileSource("stdin", -1, func() (io.ReadCloser, error) {
+			return newGzipReadCloser(io.NopCloser(r))
+		}), nil
+	default:
+		return singleFileSource("stdin", -1, func() (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		}), nil
+	}
+}
+
+func spoolZip(r io.Reader) (*source, error) {
+	tmp, err := os.CreateTemp("", "stdin-*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	removeTmp := func() {
+		if err := os.Remove(tmp.Name()); err != nil {
+			slog.Error("error removing temporary file", "path", tmp.Name(), "error", err)
+		}
+	}
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		removeTmp()
+		return nil, fmt.Errorf("failed to copy stdin to temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		removeTmp()
+		return nil, fmt.Errorf("failed to copy stdin to temporary file: %w", err)
+	}
+
+	src, err := openZip(tmp.Name())
+	if err != nil {
+		removeTmp()
+		return nil, err
+	}
+	closeZip := src.close
+	src.close = func() error {
+		defer removeTmp()
+		return closeZip()
+	}
+	return src, nil
+}
+
+func singleFileSource(name string, size int64, open func() (io.ReadCloser, error)) *source {
+	return &source{
+		files:  []sourceFile{{name: path.Clean(name), size: size, open: open}},
+		single: true,
+	}
+}
+
+// gzipReadCloser closes both the decompressor and the underlying file.
+type gzipReadCloser struct {
+	*gzip.Reader
+	underlying io.Closer
+}
+
+func newGzipReadCloser(rc io.ReadCloser) (io.ReadCloser, error) {
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		_ = rc.Close()
+		return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+	}
+	return &gzipReadCloser{Reader: gz, underlying: rc}, nil
+}
+
+func (g *gzipReadCloser) Close() error {
+	err := g.Reader.Close()
+	if closeErr := g.underlying.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
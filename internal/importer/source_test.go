@@ -0,0 +1,173 @@
+package importer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const codePointCSV = "\"AB10 1AB\",10,394251,806376,\"S92000003\",\"\",\"S08000020\",\"\",\"S12000033\",\"S13002842\"\n" +
+	"\"AB10 1AF\",10,394235,806529,\"S92000003\",\"\",\"S08000020\",\"\",\"S12000033\",\"S13002842\"\n"
+
+func gzipped(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func readSourceFiles(t *testing.T, src *source) map[string]string {
+	t.Helper()
+	contents := make(map[string]string)
+	for _, f := range src.files {
+		r, err := f.open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		contents[f.name] = string(data)
+	}
+	return contents
+}
+
+func countCodePoints(t *testing.T, path string) int {
+	t.Helper()
+	db := connectTestDB(t)
+	require.NoError(t, NewCodePointImporter(db).Import(path, http.Header{}))
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM code_point").Scan(&count))
+	return count
+}
+
+func TestOpenSourceZip(t *testing.T) {
+	zipPath := createTestZipCodePoint(t, 2)
+	defer func() {
+		assert.NoError(t, os.Remove(zipPath))
+	}()
+
+	src, err := openSource(zipPath)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, src.Close())
+	}()
+
+	assert.False(t, src.single)
+	require.Len(t, src.files, 1)
+	assert.Equal(t, "Data/CSV/test.csv", src.files[0].name)
+}
+
+func TestOpenSourceCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ab.csv")
+	require.NoError(t, os.WriteFile(path, []byte(codePointCSV), 0o644))
+
+	src, err := openSource(path)
+	require.NoError(t, err)
+
+	assert.True(t, src.single)
+	assert.Equal(t, map[string]string{"ab.csv": codePointCSV}, readSourceFiles(t, src))
+	assert.Equal(t, 2, countCodePoints(t, path))
+}
+
+func TestOpenSourceGzipDetectedByMagicBytes(t *testing.T) {
+	// No .gz extension: the format is detected from the content.
+	path := filepath.Join(t.TempDir(), "ab.csv")
+	require.NoError(t, os.WriteFile(path, gzipped(t, codePointCSV), 0o644))
+
+	src, err := openSource(path)
+	require.NoError(t, err)
+
+	assert.True(t, src.single)
+	assert.Equal(t, map[string]string{"ab.csv": codePointCSV}, readSourceFiles(t, src))
+	assert.Equal(t, 2, countCodePoints(t, path))
+}
+
+func TestOpenSourceGzipName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ab.csv.gz")
+	require.NoError(t, os.WriteFile(path, gzipped(t, codePointCSV), 0o644))
+
+	src, err := openSource(path)
+	require.NoError(t, err)
+
+	require.Len(t, src.files, 1)
+	assert.Equal(t, "ab.csv", src.files[0].name)
+}
+
+func TestOpenSourceDirectory(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "Data", "CSV"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "Doc", "Codelist"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "Data", "CSV", "ab.csv"), []byte(codePointCSV), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "Doc", "Codelist", "DC.csv"), []byte("\"Aberdeen City\",\"S12000033\"\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "README.txt"), []byte("not data"), 0o644))
+
+	src, err := openSource(root)
+	require.NoError(t, err)
+
+	assert.False(t, src.single)
+	assert.Equal(t, map[string]string{
+		"Data/CSV/ab.csv":     codePointCSV,
+		"Doc/Codelist/DC.csv": "\"Aberdeen City\",\"S12000033\"\n",
+		"README.txt":          "not data",
+	}, readSourceFiles(t, src))
+	assert.Equal(t, 2, countCodePoints(t, root))
+}
+
+func TestOpenSourceStdin(t *testing.T) {
+	for name, content := range map[string][]byte{
+		"csv":  []byte(codePointCSV),
+		"gzip": gzipped(t, codePointCSV),
+	} {
+		t.Run(name, func(t *testing.T) {
+			stdin, err := os.CreateTemp(t.TempDir(), "stdin-*")
+			require.NoError(t, err)
+			_, err = stdin.Write(content)
+			require.NoError(t, err)
+			_, err = stdin.Seek(0, io.SeekStart)
+			require.NoError(t, err)
+
+			original := os.Stdin
+			os.Stdin = stdin
+			defer func() {
+				os.Stdin = original
+				assert.NoError(t, stdin.Close())
+			}()
+
+			assert.Equal(t, 2, countCodePoints(t, StdinSource))
+		})
+	}
+}
+
+func TestOpenSourceStdinZip(t *testing.T) {
+	zipPath := createTestZipCodePoint(t, 3)
+	defer func() {
+		assert.NoError(t, os.Remove(zipPath))
+	}()
+
+	stdin, err := os.Open(zipPath)
+	require.NoError(t, err)
+	original := os.Stdin
+	os.Stdin = stdin
+	defer func() {
+		os.Stdin = original
+		assert.NoError(t, stdin.Close())
+	}()
+
+	assert.Equal(t, 3, countCodePoints(t, StdinSource))
+}
+
+func TestOpenSourceMissing(t *testing.T) {
+	_, err := openSource(filepath.Join(t.TempDir(), "missing.zip"))
+
+	assert.ErrorContains(t, err, "failed to open source")
+}
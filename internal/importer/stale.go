@@ -0,0 +1,120 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// staleKeys tracks which primary keys were seen during a full-refresh import,
+// so that rows which have disappeared from the upstream snapshot (struck-off
+// companies, terminated postcodes) can be removed afterwards.
+type staleKeys struct {
+	table     string
+	keyColumn string
+	seenTable string
+	// where, if set, restricts removal to the rows matching it, for tables
+	// written to by more than one dataset.
+	where string
+	// beforeRemove, if set, is called in the same transaction just before
+	// the unseen rows are deleted.
+	beforeRemove func(tx *sql.Tx) error
+}
+
+func newStaleKeys(table string, keyColumn string) *staleKeys {
+	return &staleKeys{
+		table:     table,
+		keyColumn: keyColumn,
+		seenTable: "import_seen_" + table,
+	}
+}
+
+func (s *staleKeys) insertSQL() string {
+	return fmt.Sprintf("INSERT OR IGNORE INTO %s (key) VALUES (?)", s.seenTable)
+}
+
+// begin creates (or empties) the table used to record the keys seen in this run.
+func (s *staleKeys) begin(db *sql.DB) error {
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (key TEXT NOT NULL PRIMARY KEY)", s.seenTable)); err != nil {
+		return fmt.Errorf("failed to create %q table: %w", s.seenTable, err)
+	}
+	if _, err := db.Exec(fmt.Sprintf("DELETE FROM %s", s.seenTable)); err != nil {
+		return fmt.Errorf("failed to clear %q table: %w", s.seenTable, err)
+	}
+	return nil
+}
+
+// record marks the given keys as seen, as part of the batch transaction.
+func (s *staleKeys) record(tx *sql.Tx, keys []string) error {
+	stmt, err := tx.Prepare(s.insertSQL())
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			slog.Error("failed to close statement", "error", err)
+		}
+	}()
+
+	for _, key := range keys {
+		if _, err := stmt.Exec(key); err != nil {
+			return fmt.Errorf("failed to record seen key %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// removeUnseen deletes every row whose key was not recorded during this run,
+// returning the number of rows removed.
+func (s *staleKeys) removeUnseen(db *sql.DB) (int64, error) {
+	defer func() {
+		if _, err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", s.seenTable)); err != nil {
+			slog.Error("failed to drop seen keys table", "table", s.seenTable, "error", err)
+		}
+	}()
+
+	var seen int64
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", s.seenTable)).Scan(&seen); err != nil {
+		return 0, fmt.Errorf("failed to count seen keys: %w", err)
+	}
+	if seen == 0 {
+		// Refuse to wipe the table on the back of an empty (most likely broken) import.
+		slog.Warn("No keys recorded during full refresh, skipping removal of stale rows", "table", s.table)
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Error("error rolling back transaction", "error", rbErr)
+			}
+		}
+	}()
+
+	if s.beforeRemove != nil {
+		if err = s.beforeRemove(tx); err != nil {
+			return 0, err
+		}
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s NOT IN (SELECT key FROM %s)", s.table, s.keyColumn, s.seenTable)
+	if s.where != "" {
+		query += " AND " + s.where
+	}
+	result, err := tx.Exec(query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove stale rows from %q: %w", s.table, err)
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count stale rows removed from %q: %w", s.table, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return removed, nil
+}
@@ -0,0 +1,35 @@
+package importer
+
+import "time"
+
+// throughput tracks how many rows have been written and how quickly, so that
+// progress can be reported as the import runs. The rate is measured from the
+// start of the import, so includes the time spent parsing the source.
+type throughput struct {
+	started time.Time
+	rows    int
+}
+
+func (t *throughput) start() {
+	t.started = time.Now()
+	t.rows = 0
+}
+
+func (t *throughput) add(rows int) {
+	t.rows += rows
+}
+
+func (t *throughput) elapsed() time.Duration {
+	if t.started.IsZero() {
+		return 0
+	}
+	return time.Since(t.started)
+}
+
+func (t *throughput) rowsPerSecond() int {
+	seconds := t.elapsed().Seconds()
+	if seconds == 0 {
+		return 0
+	}
+	return int(float64(t.rows) / seconds)
+}
@@ -0,0 +1,20 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThroughputIsMeasuredFromTheStart(t *testing.T) {
+	var tp throughput
+	assert.Zero(t, tp.rowsPerSecond())
+
+	tp.start()
+	time.Sleep(100 * time.Millisecond)
+	tp.add(10)
+
+	assert.GreaterOrEqual(t, tp.elapsed(), 100*time.Millisecond, "time before the first rows were written should count")
+	assert.LessOrEqual(t, tp.rowsPerSecond(), 100)
+}
@@ -1,5 +1,73 @@
 package importer
 
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ZipImporter imports a dataset from a zip file, a directory, a plain or
+// gzip-compressed CSV file, or stdin ("-"). The header holds the HTTP response
+// headers when the file was downloaded, and is empty for local files.
 type ZipImporter interface {
-	Import(zipPath string) error
+	Import(path string, header http.Header) error
+}
+
+// Dataset is an importable dataset, as registered with the importer registry.
+type Dataset interface {
+	// Name identifies the dataset on the command line.
+	Name() string
+	// Description is a one-line summary of the dataset.
+	Description() string
+	// DefaultSource is the file or URL imported when no source is given.
+	DefaultSource() string
+	// Table is the table the dataset's records are written to.
+	Table() string
+	// NewImporter returns an importer that writes the dataset to db.
+	NewImporter(db *sql.DB, opts ...Option) ZipImporter
+}
+
+var registry = map[string]Dataset{}
+
+// Register makes a dataset available to Lookup. It panics if a dataset with
+// the same name is already registered.
+func Register(dataset Dataset) {
+	if _, exists := registry[dataset.Name()]; exists {
+		panic(fmt.Sprintf("importer: dataset %q registered twice", dataset.Name()))
+	}
+	registry[dataset.Name()] = dataset
+}
+
+// Lookup returns the registered dataset with the given name.
+func Lookup(name string) (Dataset, error) {
+	dataset, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown dataset %q (available: %s)", name, strings.Join(DatasetNames(), ", "))
+	}
+	return dataset, nil
+}
+
+// Datasets returns every registered dataset, ordered by name.
+func Datasets() []Dataset {
+	datasets := make([]Dataset, 0, len(registry))
+	for _, dataset := range registry {
+		datasets = append(datasets, dataset)
+	}
+	sort.Slice(datasets, func(i, j int) bool {
+		return datasets[i].Name() < datasets[j].Name()
+	})
+	return datasets
+}
+
+// DatasetNames returns the names of the registered datasets, in order.
+func DatasetNames() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
 }
@@ -0,0 +1,47 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupRegisteredDatasets(t *testing.T) {
+	companies, err := Lookup("companies-house")
+	require.NoError(t, err)
+	assert.Equal(t, "company_data", companies.Table())
+
+	codePoint, err := Lookup("code-point")
+	require.NoError(t, err)
+	assert.Equal(t, "code_point", codePoint.Table())
+}
+
+func TestLookupUnknownDataset(t *testing.T) {
+	_, err := Lookup("unknown")
+
+	assert.EqualError(t, err, `unknown dataset "unknown" (available: code-point, companies-house, onspd)`)
+}
+
+func TestDatasetsOrderedByName(t *testing.T) {
+	var names []string
+	for _, dataset := range Datasets() {
+		names = append(names, dataset.Name())
+	}
+
+	assert.Equal(t, []string{"code-point", "companies-house", "onspd"}, names)
+	assert.Equal(t, names, DatasetNames())
+}
+
+func TestRegisterDuplicateDatasetPanics(t *testing.T) {
+	assert.PanicsWithValue(t, `importer: dataset "code-point" registered twice`, func() {
+		Register(codePointDataset)
+	})
+}
+
+func TestDatasetFileSelection(t *testing.T) {
+	assert.True(t, codePointDataset.selects("Data/CSV/ab.csv"))
+	assert.False(t, codePointDataset.selects("Doc/Codelist/DC.csv"))
+	assert.True(t, companyDataset.selects("BasicCompanyDataAsOneFile-2025-09-01.csv"))
+	assert.False(t, companyDataset.selects("README.txt"))
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// LocateCompanies copies the location of their registered postcode onto
+// the companies that have none: those written by the latest import, and
+// those whose postcode did not match before. Companies whose postcode is not
+// in CodePoint are given the centroid of its sector or, failing that, its
+// district.
+func LocateCompanies(db *sql.DB) error {
+	return locateCompanies(db, LocateCompaniesSQL)
+}
+
+// LocateCompanyChanges copies onto the changes found by the latest import
+// the location LocateCompanies gave the company, so that the change feed can
+// be filtered by where companies are rather than by their postcodes, which
+// may only have a sector or district centroid.
+func LocateCompanyChanges(db *sql.DB) error {
+	if _, err := db.Exec(LocateCompanyChangesSQL); err != nil {
+		return fmt.Errorf("failed to locate company changes: %w", err)
+	}
+	return nil
+}
+
+// RelocateCompanies copies the location of their registered postcode, or its
+// sector or district centroid, onto every company, after postcodes have been
+// added, moved or terminated.
+func RelocateCompanies(db *sql.DB) error {
+	return locateCompanies(db, RelocateCompaniesSQL)
+}
+
+func locateCompanies(db *sql.DB, query string) error {
+	result, err := db.Exec(query)
+	if err != nil {
+		return fmt.Errorf("failed to locate companies: %w", err)
+	}
+	located, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to locate companies: %w", err)
+	}
+	if _, err := db.Exec(LocateCompaniesByCentroidSQL); err != nil {
+		return fmt.Errorf("failed to locate companies by postcode sector or district: %w", err)
+	}
+
+	var unmatched int64
+	if err := db.QueryRow("SELECT COUNT(*) FROM company_data WHERE morton_key IS NULL").Scan(&unmatched); err != nil {
+		return fmt.Errorf("failed to count unmatched companies: %w", err)
+	}
+	slog.Info("Located companies by registered postcode", "updated", located, "unmatched", unmatched)
+	return nil
+}
+
+// RebuildPostcodeCentroids replaces the sector and district centroids with
+// those of the current postcodes.
+func RebuildPostcodeCentroids(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.Exec(RebuildPostcodeCentroidsSQL); err != nil {
+		return fmt.Errorf("failed to rebuild postcode centroids: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit postcode centroids: %w", err)
+	}
+	return addMissingMortonKeys(db, "postcode_centroid", "code")
+}
+
+// buildMissingPostcodeCentroids builds the centroids of postcodes imported
+// before they were introduced.
+func buildMissingPostcodeCentroids(db *sql.DB) error {
+	var missing bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM code_point) AND NOT EXISTS (SELECT 1 FROM postcode_centroid)").Scan(&missing)
+	if err != nil {
+		return fmt.Errorf("failed to check postcode centroids: %w", err)
+	}
+	if !missing {
+		return nil
+	}
+	slog.Info("Building postcode sector and district centroids")
+	return RebuildPostcodeCentroids(db)
+}
+
+// addMissingMortonKeys computes the keys of the locations in table that have
+// none: postcodes imported before keys were introduced, and centroids, which
+// are averaged in SQL.
+func addMissingMortonKeys(db *sql.DB, table string, keyColumn string) error {
+	rows, err := db.Query(fmt.Sprintf("SELECT %s, easting, northing FROM %s WHERE morton_key IS NULL", keyColumn, table))
+	if err != nil {
+		return fmt.Errorf("failed to read %s without keys: %w", table, err)
+	}
+	keys := make(map[string]int64)
+	for rows.Next() {
+		var key string
+		var easting, northing int
+		if err := rows.Scan(&key, &easting, &northing); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to scan %s: %w", table, err)
+		}
+		keys[key] = MortonKey(easting, northing)
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("failed to read %s without keys: %w", table, err)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s without keys: %w", table, err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	slog.Debug("Adding Morton keys", "table", table, "rows", len(keys))
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	stmt, err := tx.Prepare(fmt.Sprintf("UPDATE %s SET morton_key = ? WHERE %s = ?", table, keyColumn))
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	for key, mortonKey := range keys {
+		if _, err := stmt.Exec(mortonKey, key); err != nil {
+			return fmt.Errorf("failed to add Morton key: %w", err)
+		}
+	}
+	if err := stmt.Close(); err != nil {
+		return fmt.Errorf("failed to close statement: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit Morton keys: %w", err)
+	}
+	return nil
+}
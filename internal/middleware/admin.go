@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin only lets through requests with an API key, sent in the
+// X-API-Key header, whose settings include "admin": true. Without any admin
+// keys, the routes it guards can't be reached at all.
+func RequireAdmin(keys map[string]APIKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(APIKeyHeader)
+		if key == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "an admin API key is required"})
+			return
+		}
+		settings, ok := keys[key]
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unrecognised API key"})
+			return
+		}
+		if !settings.Admin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key is not an admin key"})
+			return
+		}
+		c.Next()
+	}
+}
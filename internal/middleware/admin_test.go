@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := map[string]struct {
+		keys   map[string]APIKey
+		key    string
+		status int
+		body   string
+	}{
+		"admin key":         {map[string]APIKey{"ops": {Admin: true}}, "ops", http.StatusOK, "ok"},
+		"no key":            {map[string]APIKey{"ops": {Admin: true}}, "", http.StatusUnauthorized, `{"error":"an admin API key is required"}`},
+		"unrecognised key":  {map[string]APIKey{"ops": {Admin: true}}, "mistyped", http.StatusUnauthorized, `{"error":"unrecognised API key"}`},
+		"non-admin key":     {map[string]APIKey{"partner": {MaxResults: 5000}}, "partner", http.StatusForbidden, `{"error":"API key is not an admin key"}`},
+		"no keys available": {nil, "", http.StatusUnauthorized, `{"error":"an admin API key is required"}`},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/admin", RequireAdmin(tc.keys), func(c *gin.Context) {
+				c.String(http.StatusOK, "ok")
+			})
+
+			req, _ := http.NewRequest("GET", "/admin", nil)
+			if tc.key != "" {
+				req.Header.Set(APIKeyHeader, tc.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.body, w.Body.String())
+		})
+	}
+}
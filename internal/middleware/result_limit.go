@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader is the request header clients send their API key in.
+const APIKeyHeader = "X-API-Key"
+
+const maxResultsKey = "maxResults"
+
+// APIKey holds the settings for a client's API key.
+type APIKey struct {
+	// MaxResults overrides the default maximum number of results a search
+	// may return. Zero keeps the default.
+	MaxResults int `json:"max_results"`
+	// Admin allows the key to use the admin routes (see RequireAdmin).
+	Admin bool `json:"admin"`
+}
+
+// LoadAPIKeys reads a JSON file mapping each API key to its settings, e.g.
+// {"3f9c...": {"max_results": 50000}}.
+func LoadAPIKeys(path string) (map[string]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+
+	var keys map[string]APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse API keys in %s: %w", path, err)
+	}
+	for key, settings := range keys {
+		if settings.MaxResults < 0 {
+			return nil, fmt.Errorf("API key %q has a negative max_results", key)
+		}
+	}
+	return keys, nil
+}
+
+// ResultLimit sets the maximum number of results a search may return: the
+// limit for the API key sent in the X-API-Key header, or maxResults for
+// requests without one. Requests with an unrecognised key are rejected, so
+// that a mistyped key isn't silently given the default. As responses differ
+// by key, caches are told to store them separately.
+func ResultLimit(maxResults int, keys map[string]APIKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", APIKeyHeader)
+		limit := maxResults
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			settings, ok := keys[key]
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unrecognised API key"})
+				return
+			}
+			if settings.MaxResults > 0 {
+				limit = settings.MaxResults
+			}
+		}
+		c.Set(maxResultsKey, limit)
+		c.Next()
+	}
+}
+
+// MaxResults returns the maximum number of results set by ResultLimit, or
+// zero (no limit) if it isn't in use.
+func MaxResults(c *gin.Context) int {
+	return c.GetInt(maxResultsKey)
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(ResultLimit(100, map[string]APIKey{
+		"partner": {MaxResults: 5000},
+		"default": {},
+	}))
+	r.GET("/test", func(c *gin.Context) {
+		c.String(http.StatusOK, strconv.Itoa(MaxResults(c)))
+	})
+
+	cases := map[string]struct {
+		key    string
+		status int
+		body   string
+	}{
+		"no key":            {"", http.StatusOK, "100"},
+		"key with limit":    {"partner", http.StatusOK, "5000"},
+		"key without limit": {"default", http.StatusOK, "100"},
+		"unrecognised key":  {"mistyped", http.StatusUnauthorized, `{"error":"unrecognised API key"}`},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/test", nil)
+			if tc.key != "" {
+				req.Header.Set(APIKeyHeader, tc.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.body, w.Body.String())
+			assert.Equal(t, APIKeyHeader, w.Header().Get("Vary"))
+		})
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	write := func(content string) string {
+		path := filepath.Join(t.TempDir(), "api-keys.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	keys, err := LoadAPIKeys(write(`{"partner": {"max_results": 5000}, "default": {}}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]APIKey{"partner": {MaxResults: 5000}, "default": {}}, keys)
+
+	_, err = LoadAPIKeys(write(`["partner"]`))
+	assert.ErrorContains(t, err, "failed to parse API keys")
+
+	_, err = LoadAPIKeys(write(`{"partner": {"max_results": -1}}`))
+	assert.ErrorContains(t, err, "negative max_results")
+
+	_, err = LoadAPIKeys(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorContains(t, err, "failed to read API keys")
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Change types recorded in the change feed.
+const (
+	ChangeIncorporated   = "incorporated"
+	ChangeDissolved      = "dissolved"
+	ChangeStatusChanged  = "status_changed"
+	ChangeRenamed        = "renamed"
+	ChangeAddressChanged = "address_changed"
+	ChangeSICChanged     = "sic_changed"
+	ChangeAccountsFiled  = "accounts_filed"
+	ChangeRemoved        = "removed"
+)
+
+// CompanyChange is a single difference between one Companies House import
+// and the next. OldValue and NewValue hold the company name, status,
+// single-line registered address, SIC codes or accounts made-up date,
+// depending on the change type.
+type CompanyChange struct {
+	ID            int64  `json:"id"`
+	CompanyNumber string `json:"company_number"`
+	ChangeType    string `json:"change_type"`
+	OldValue      string `json:"old_value,omitempty"`
+	NewValue      string `json:"new_value,omitempty"`
+	// PostCode is the company's registered postcode after the change (or
+	// before it, for a removed company), and PreviousPostCode the one it
+	// moved from, for an address change.
+	PostCode         string    `json:"post_code,omitempty"`
+	PreviousPostCode string    `json:"previous_post_code,omitempty"`
+	DetectedAt       time.Time `json:"detected_at"`
+}
+
+// Location is a point on the British National Grid.
+type Location struct {
+	Easting  float64 `json:"easting"`
+	Northing float64 `json:"northing"`
+}
+
+// TimelineEvent is a change in a company's timeline, with the company's
+// location after the change and, for an address change, before it: that of
+// its registered postcode, or the postcode's sector or district centroid.
+// Locations are omitted where the company could not be located.
+type TimelineEvent struct {
+	CompanyChange
+	Location         *Location `json:"location,omitempty"`
+	PreviousLocation *Location `json:"previous_location,omitempty"`
+}
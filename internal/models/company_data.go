@@ -44,4 +44,23 @@ type CompanyDataWithLocation struct {
 	CompanyData
 	Easting  int `json:"easting"`
 	Northing int `json:"northing"`
+	// PositionalQuality is the CodePoint Open positional quality indicator:
+	// 10 (within the building of the matched address closest to the postcode
+	// mean) through 60 (estimated to postcode sector level), or 90 (no
+	// coordinates available). 0 means it was not recorded at import time.
+	PositionalQuality int    `json:"positional_quality"`
+	CountryCode       string `json:"country_code,omitempty"`
+	NHSRegionCode     string `json:"nhs_region_code,omitempty"`
+	NHSHACode         string `json:"nhs_ha_code,omitempty"`
+	CountyCode        string `json:"county_code,omitempty"`
+	CountyName        string `json:"county_name,omitempty"`
+	DistrictCode      string `json:"district_code,omitempty"`
+	DistrictName      string `json:"district_name,omitempty"`
+	WardCode          string `json:"ward_code,omitempty"`
+	WardName          string `json:"ward_name,omitempty"`
+	// LocationPrecision is "postcode" when the company is placed at its
+	// registered postcode, or "sector" or "district" when that postcode is
+	// not in CodePoint Open and the company is placed at the centroid of its
+	// postcode sector ("AB10 1") or district ("AB10") instead.
+	LocationPrecision string `json:"location_precision"`
 }
@@ -0,0 +1,23 @@
+package models
+
+// LocationReport summarises how well companies' registered postcodes have
+// been matched to locations.
+type LocationReport struct {
+	// Located counts the companies with a location, by its precision:
+	// postcode, sector or district.
+	Located map[string]int `json:"located"`
+	// Unmatched counts the companies without a location, by reason: missing,
+	// invalid, outside_coverage (Northern Ireland, the Channel Islands and
+	// the Isle of Man) or unknown.
+	Unmatched      map[string]int      `json:"unmatched"`
+	TotalUnmatched int                 `json:"total_unmatched"`
+	TopPostcodes   []UnmatchedPostcode `json:"top_postcodes"`
+}
+
+// UnmatchedPostcode is a registered postcode that could not be located, with
+// the number of companies registered at it.
+type UnmatchedPostcode struct {
+	PostCode  string `json:"post_code"`
+	Reason    string `json:"reason"`
+	Companies int    `json:"companies"`
+}
@@ -0,0 +1,123 @@
+package internal
+
+import "math/bits"
+
+// Locations are indexed by Morton (Z-order) key: the bits of the easting and
+// northing, in whole metres, interleaved so that points close together mostly
+// have keys close together. A bounding box is then covered by a handful of key
+// ranges, each read in order from an ordinary B-tree index.
+
+// mortonBits is enough bits per axis for the National Grid: 2^21 m is over
+// 2,000 km, and the furthest northing in Great Britain is 1,300 km.
+const mortonBits = 21
+
+const mortonMax = 1<<mortonBits - 1
+
+// MortonKey returns the Morton key of a location on the National Grid.
+// Coordinates outside the grid are clamped to its edges.
+func MortonKey(easting int, northing int) int64 {
+	return int64(spreadBits(clampToGrid(easting)) | spreadBits(clampToGrid(northing))<<1)
+}
+
+// spreadBits moves each bit of v to twice its position, leaving a zero bit
+// between each.
+func spreadBits(v int) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return x
+}
+
+func clampToGrid(v int) int {
+	return min(max(v, 0), mortonMax)
+}
+
+// MortonRange is an inclusive range of Morton keys.
+type MortonRange struct {
+	Min int64
+	Max int64
+}
+
+// mortonRefinement is how many times the cells straddling the edge of a
+// bounding box are split, starting from cells the size of the box. The
+// ranges then cover at most 1/2^mortonRefinement of the box's size beyond
+// each edge.
+const mortonRefinement = 3
+
+// MortonRanges returns, in order, up to maxRanges key ranges that together
+// cover every location in the bounding box. They may also cover locations
+// just outside it, which must be filtered out by comparing coordinates.
+func MortonRanges(minEasting int, minNorthing int, maxEasting int, maxNorthing int, maxRanges int) []MortonRange {
+	if minEasting > maxEasting || minNorthing > maxNorthing ||
+		maxEasting < 0 || maxNorthing < 0 || minEasting > mortonMax || minNorthing > mortonMax {
+		return nil
+	}
+	c := mortonCover{
+		minEasting:  clampToGrid(minEasting),
+		minNorthing: clampToGrid(minNorthing),
+		maxEasting:  clampToGrid(maxEasting),
+		maxNorthing: clampToGrid(maxNorthing),
+	}
+	size := max(c.maxEasting-c.minEasting, c.maxNorthing-c.minNorthing) + 1
+	c.minLevel = max(bits.Len(uint(size))-mortonRefinement, 0)
+	c.visit(0, 0, mortonBits)
+
+	return mergeMortonRanges(c.ranges, maxRanges)
+}
+
+type mortonCover struct {
+	minEasting, minNorthing int
+	maxEasting, maxNorthing int
+	minLevel                int
+	ranges                  []MortonRange
+}
+
+// visit covers the part of the bounding box within the square cell of side
+// 2^level whose south-west corner is at (x, y).
+func (c *mortonCover) visit(x int, y int, level int) {
+	last := 1<<level - 1
+	if x > c.maxEasting || x+last < c.minEasting || y > c.maxNorthing || y+last < c.minNorthing {
+		return
+	}
+
+	inside := x >= c.minEasting && x+last <= c.maxEasting && y >= c.minNorthing && y+last <= c.maxNorthing
+	if inside || level <= c.minLevel {
+		first := MortonKey(x, y)
+		c.add(MortonRange{Min: first, Max: first + int64(1)<<(2*level) - 1})
+		return
+	}
+
+	// The quarters of a cell, in key order.
+	half := 1 << (level - 1)
+	c.visit(x, y, level-1)
+	c.visit(x+half, y, level-1)
+	c.visit(x, y+half, level-1)
+	c.visit(x+half, y+half, level-1)
+}
+
+func (c *mortonCover) add(r MortonRange) {
+	if n := len(c.ranges); n > 0 && c.ranges[n-1].Max+1 == r.Min {
+		c.ranges[n-1].Max = r.Max
+		return
+	}
+	c.ranges = append(c.ranges, r)
+}
+
+// mergeMortonRanges joins the ranges separated by the smallest gaps until
+// there are no more than maxRanges.
+func mergeMortonRanges(ranges []MortonRange, maxRanges int) []MortonRange {
+	for len(ranges) > max(maxRanges, 1) {
+		smallest := 1
+		for i := 2; i < len(ranges); i++ {
+			if ranges[i].Min-ranges[i-1].Max < ranges[smallest].Min-ranges[smallest-1].Max {
+				smallest = i
+			}
+		}
+		ranges[smallest-1].Max = ranges[smallest].Max
+		ranges = append(ranges[:smallest], ranges[smallest+1:]...)
+	}
+	return ranges
+}
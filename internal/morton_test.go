@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMortonKey(t *testing.T) {
+	assert.Equal(t, int64(0), MortonKey(0, 0))
+	assert.Equal(t, int64(1), MortonKey(1, 0))
+	assert.Equal(t, int64(2), MortonKey(0, 1))
+	assert.Equal(t, int64(3), MortonKey(1, 1))
+	assert.Equal(t, int64(0b110001), MortonKey(0b101, 0b100))
+	assert.Equal(t, int64(1)<<42-1, MortonKey(mortonMax, mortonMax))
+	assert.Equal(t, MortonKey(0, mortonMax), MortonKey(-5, 3000000), "clamped to the grid")
+}
+
+func TestMortonRangesCoverBoundingBox(t *testing.T) {
+	boxes := [][4]int{
+		{0, 0, 7, 7},
+		{3, 5, 12, 9},
+		{1, 1, 1, 1},
+		{530123, 180456, 530623, 180956},
+		{400000, 800000, 402000, 801000},
+	}
+	for _, box := range boxes {
+		ranges := MortonRanges(box[0], box[1], box[2], box[3], 16)
+		require.NotEmpty(t, ranges)
+		require.LessOrEqual(t, len(ranges), 16)
+		for i := 1; i < len(ranges); i++ {
+			assert.Greater(t, ranges[i].Min, ranges[i-1].Max+1, "ranges should be ordered and disjoint")
+		}
+
+		covered := func(key int64) bool {
+			for _, r := range ranges {
+				if key >= r.Min && key <= r.Max {
+					return true
+				}
+			}
+			return false
+		}
+		// Every location in the box, and on a margin around it.
+		width, height := box[2]-box[0], box[3]-box[1]
+		stepE, stepN := max(width/50, 1), max(height/50, 1)
+		for e := box[0] - width; e <= box[2]+width; e += stepE {
+			for n := box[1] - height; n <= box[3]+height; n += stepN {
+				inside := e >= box[0] && e <= box[2] && n >= box[1] && n <= box[3]
+				if inside {
+					assert.True(t, covered(MortonKey(e, n)), "%d,%d in %v", e, n, box)
+				}
+			}
+		}
+		for _, corner := range [][2]int{{box[0], box[1]}, {box[2], box[3]}, {box[0], box[3]}, {box[2], box[1]}} {
+			assert.True(t, covered(MortonKey(corner[0], corner[1])), "corner %v of %v", corner, box)
+		}
+	}
+}
+
+func TestMortonRangesAreTight(t *testing.T) {
+	// An aligned box is a single cell.
+	assert.Equal(t, []MortonRange{{Min: 0, Max: 63}}, MortonRanges(0, 0, 7, 7, 16))
+
+	var covered int64
+	for _, r := range MortonRanges(530123, 180456, 530623, 180956, 16) {
+		covered += r.Max - r.Min + 1
+	}
+	assert.Less(t, covered, int64(4*501*501), "the ranges should not cover much more than the box")
+}
+
+func TestMortonRangesLimit(t *testing.T) {
+	require.Greater(t, len(MortonRanges(530123, 180456, 530623, 180956, 16)), 2)
+
+	ranges := MortonRanges(530123, 180456, 530623, 180956, 2)
+	require.Len(t, ranges, 2)
+	assert.LessOrEqual(t, ranges[0].Min, MortonKey(530123, 180456))
+	assert.GreaterOrEqual(t, ranges[1].Max, MortonKey(530623, 180956))
+}
+
+func TestMortonRangesOutsideGrid(t *testing.T) {
+	assert.Empty(t, MortonRanges(-10, -10, -1, -1, 16))
+	assert.Empty(t, MortonRanges(5, 5, 4, 4, 16))
+	assert.NotEmpty(t, MortonRanges(-10, -10, 10, 10, 16))
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// postcodePattern matches a UK postcode once spaces have been removed: an
+// outward code of area letters, district digit and optional letter or digit,
+// followed by an inward code of a sector digit and two unit letters.
+var postcodePattern = regexp.MustCompile(`^[A-Z]{1,2}[0-9][A-Z0-9]?[0-9][A-Z]{2}$`)
+
+// IsValidPostcode reports whether the postcode is well formed, ignoring case
+// and spacing. It doesn't check that the postcode exists.
+func IsValidPostcode(postcode string) bool {
+	return postcodePattern.MatchString(compactPostcode(postcode))
+}
+
+// NormalisePostcode returns the postcode in upper case with a single space
+// before the inward code, e.g. "ab101ab" and "AB10  1AB" become "AB10 1AB".
+// CodePoint pads outward codes to four characters ("B1  1AA") while
+// Companies House mostly, but not always, uses a single space. Anything that
+// is not a well-formed postcode is only trimmed.
+func NormalisePostcode(postcode string) string {
+	compact := compactPostcode(postcode)
+	if !postcodePattern.MatchString(compact) {
+		return strings.TrimSpace(postcode)
+	}
+	return compact[:len(compact)-3] + " " + compact[len(compact)-3:]
+}
+
+func compactPostcode(postcode string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(postcode), ""))
+}
+
+// Reasons a company's registered postcode could not be located.
+const (
+	UnmatchedMissing         = "missing"
+	UnmatchedInvalid         = "invalid"
+	UnmatchedOutsideCoverage = "outside_coverage"
+	UnmatchedUnknown         = "unknown"
+)
+
+// outsideCoverageAreas are the postcode areas of Northern Ireland, the
+// Channel Islands and the Isle of Man, which CodePoint Open, covering Great
+// Britain only, does not include. Northern Ireland is covered by ONSPD, if
+// that has been imported.
+var outsideCoverageAreas = map[string]bool{"BT": true, "GY": true, "JE": true, "IM": true}
+
+// UnmatchedReason explains why a postcode matched neither a CodePoint
+// postcode nor the centroid of its sector or district: it is blank, not a
+// well-formed postcode, outside CodePoint's coverage, or otherwise unknown
+// (terminated, or a typo that is still well formed).
+func UnmatchedReason(postcode string) string {
+	compact := compactPostcode(postcode)
+	switch {
+	case compact == "":
+		return UnmatchedMissing
+	case !postcodePattern.MatchString(compact):
+		return UnmatchedInvalid
+	case outsideCoverageAreas[compact[:2]]:
+		return UnmatchedOutsideCoverage
+	default:
+		return UnmatchedUnknown
+	}
+}
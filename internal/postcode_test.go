@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidPostcode(t *testing.T) {
+	for _, postcode := range []string{"AB10 1AB", "E1 6AN", "EC1A 1BB", "W1A 0AX", "m1 1ae", "AB101AB"} {
+		assert.True(t, IsValidPostcode(postcode), postcode)
+	}
+	for _, postcode := range []string{"", "NOT A POSTCODE", "AB10", "1AB 1AB", "AB10 1A"} {
+		assert.False(t, IsValidPostcode(postcode), postcode)
+	}
+}
+
+func TestNormalisePostcode(t *testing.T) {
+	cases := map[string]string{
+		"AB10 1AB":   "AB10 1AB",
+		"AB101AB":    "AB10 1AB",
+		"ab10 1ab":   "AB10 1AB",
+		" AB10  1AB": "AB10 1AB",
+		"B1  1AA":    "B1 1AA",
+		"EC1A1BB":    "EC1A 1BB",
+		"bt1 1aa":    "BT1 1AA",
+		"":           "",
+		"  n/a ":     "n/a",
+		"AB10 1A":    "AB10 1A",
+	}
+	for postcode, expected := range cases {
+		assert.Equal(t, expected, NormalisePostcode(postcode), postcode)
+	}
+}
+
+func TestUnmatchedReason(t *testing.T) {
+	cases := map[string]string{
+		"":         UnmatchedMissing,
+		"  ":       UnmatchedMissing,
+		"n/a":      UnmatchedInvalid,
+		"75008":    UnmatchedInvalid,
+		"BT1 1AA":  UnmatchedOutsideCoverage,
+		"je2 3ab":  UnmatchedOutsideCoverage,
+		"IM1 1AA":  UnmatchedOutsideCoverage,
+		"ZZ99 9ZZ": UnmatchedUnknown,
+		"B1 1AA":   UnmatchedUnknown,
+	}
+	for postcode, expected := range cases {
+		assert.Equal(t, expected, UnmatchedReason(postcode), postcode)
+	}
+}
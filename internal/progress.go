@@ -0,0 +1,260 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/mattn/go-isatty"
+)
+
+// ProgressMode selects how progress is reported.
+type ProgressMode string
+
+const (
+	// ProgressAuto draws a progress bar when stderr is a terminal, and logs
+	// otherwise.
+	ProgressAuto ProgressMode = "auto"
+	// ProgressBar redraws a single-line progress bar on stderr.
+	ProgressBar ProgressMode = "bar"
+	// ProgressLog writes a structured log line periodically, for CI.
+	ProgressLog ProgressMode = "log"
+	// ProgressOff disables progress reporting.
+	ProgressOff ProgressMode = "off"
+)
+
+var (
+	progressMode     = ProgressAuto
+	barInterval      = 250 * time.Millisecond
+	progressInterval = 10 * time.Second
+)
+
+// SetProgressMode sets how progress is reported for subsequent tasks.
+func SetProgressMode(mode string) error {
+	switch ProgressMode(mode) {
+	case ProgressAuto, ProgressBar, ProgressLog, ProgressOff:
+		progressMode = ProgressMode(mode)
+		return nil
+	default:
+		return fmt.Errorf("invalid progress mode %q: must be one of auto, bar, log or off", mode)
+	}
+}
+
+// Progress tracks a long-running task measured in bytes, such as a download
+// or the parsing of an import file, along with the number of rows processed
+// where that applies. The total is the expected number of bytes, or zero if
+// unknown, in which case no percentage or ETA is given. It is safe for
+// concurrent use.
+type Progress struct {
+	name    string
+	total   int64
+	bytes   atomic.Int64
+	rows    atomic.Int64
+	started time.Time
+	now     func() time.Time
+
+	report   func(ProgressSnapshot, bool)
+	stop     chan struct{}
+	stopped  chan struct{}
+	doneOnce sync.Once
+}
+
+// ProgressSnapshot is the state of a task at a point in time. Percent and ETA
+// are negative when the total size is unknown.
+type ProgressSnapshot struct {
+	Name           string
+	Bytes          int64
+	TotalBytes     int64
+	Rows           int64
+	Elapsed        time.Duration
+	BytesPerSecond float64
+	RowsPerSecond  float64
+	Percent        float64
+	ETA            time.Duration
+}
+
+// StartProgress begins reporting progress for the named task until Done is
+// called.
+func StartProgress(name string, total int64) *Progress {
+	p := newProgress(name, total, time.Now)
+
+	mode := progressMode
+	if mode == ProgressAuto {
+		mode = ProgressLog
+		if isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd()) {
+			mode = ProgressBar
+		}
+	}
+
+	var interval time.Duration
+	switch mode {
+	case ProgressBar:
+		p.report = barReporter(os.Stderr)
+		interval = barInterval
+	case ProgressLog:
+		p.report = logReporter
+		interval = progressInterval
+	default:
+		return p
+	}
+
+	p.stop = make(chan struct{})
+	p.stopped = make(chan struct{})
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.report(p.Snapshot(), false)
+			}
+		}
+	}()
+	return p
+}
+
+func newProgress(name string, total int64, now func() time.Time) *Progress {
+	return &Progress{
+		name:    name,
+		total:   max(total, 0),
+		started: now(),
+		now:     now,
+	}
+}
+
+// AddBytes records that n more bytes have been processed.
+func (p *Progress) AddBytes(n int64) {
+	p.bytes.Add(n)
+}
+
+// AddRows records that n more rows have been processed.
+func (p *Progress) AddRows(n int64) {
+	p.rows.Add(n)
+}
+
+// Reader returns a reader that records the bytes read through it.
+func (p *Progress) Reader(r io.Reader) io.Reader {
+	return &progressReader{reader: r, progress: p}
+}
+
+// Done stops the periodic reports and reports the final state.
+func (p *Progress) Done() {
+	p.doneOnce.Do(func() {
+		if p.stop == nil {
+			return
+		}
+		close(p.stop)
+		<-p.stopped
+		p.report(p.Snapshot(), true)
+	})
+}
+
+func (p *Progress) Snapshot() ProgressSnapshot {
+	snapshot := ProgressSnapshot{
+		Name:       p.name,
+		Bytes:      p.bytes.Load(),
+		TotalBytes: p.total,
+		Rows:       p.rows.Load(),
+		Elapsed:    p.now().Sub(p.started),
+		Percent:    -1,
+		ETA:        -1,
+	}
+
+	if seconds := snapshot.Elapsed.Seconds(); seconds > 0 {
+		snapshot.BytesPerSecond = float64(snapshot.Bytes) / seconds
+		snapshot.RowsPerSecond = float64(snapshot.Rows) / seconds
+	}
+	if snapshot.TotalBytes > 0 {
+		snapshot.Percent = min(100, 100*float64(snapshot.Bytes)/float64(snapshot.TotalBytes))
+		if snapshot.BytesPerSecond > 0 {
+			remaining := max(0, snapshot.TotalBytes-snapshot.Bytes)
+			snapshot.ETA = time.Duration(float64(remaining) / snapshot.BytesPerSecond * float64(time.Second))
+		}
+	}
+	return snapshot
+}
+
+type progressReader struct {
+	reader   io.Reader
+	progress *Progress
+}
+
+func (r *progressReader) Read(buf []byte) (int, error) {
+	n, err := r.reader.Read(buf)
+	r.progress.AddBytes(int64(n))
+	return n, err
+}
+
+func logReporter(snapshot ProgressSnapshot, done bool) {
+	attrs := []any{
+		"task", snapshot.Name,
+		"bytes", snapshot.Bytes,
+		"bytesPerSecond", int64(snapshot.BytesPerSecond),
+		"elapsed", snapshot.Elapsed.Round(time.Second).String(),
+	}
+	if snapshot.TotalBytes > 0 {
+		attrs = append(attrs,
+			"totalBytes", snapshot.TotalBytes,
+			"percent", fmt.Sprintf("%.1f", snapshot.Percent),
+		)
+	}
+	if snapshot.ETA >= 0 && !done {
+		attrs = append(attrs, "eta", snapshot.ETA.Round(time.Second).String())
+	}
+	if snapshot.Rows > 0 {
+		attrs = append(attrs,
+			"rows", snapshot.Rows,
+			"rowsPerSecond", int64(snapshot.RowsPerSecond),
+		)
+	}
+
+	msg := "Progress"
+	if done {
+		msg = "Progress complete"
+	}
+	slog.Info(msg, attrs...)
+}
+
+const barWidth = 30
+
+// barReporter redraws a single line on w, ending it once the task is done.
+func barReporter(w io.Writer) func(ProgressSnapshot, bool) {
+	return func(snapshot ProgressSnapshot, done bool) {
+		line := "\r\033[K" + formatBar(snapshot)
+		if done {
+			line += "\n"
+		}
+		_, _ = io.WriteString(w, line)
+	}
+}
+
+func formatBar(snapshot ProgressSnapshot) string {
+	var sb strings.Builder
+	sb.WriteString(snapshot.Name)
+
+	if snapshot.Percent >= 0 {
+		filled := int(snapshot.Percent / 100 * barWidth)
+		fmt.Fprintf(&sb, " [%s%s] %5.1f%%", strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled), snapshot.Percent)
+		fmt.Fprintf(&sb, " %s/%s", humanize.Bytes(uint64(snapshot.Bytes)), humanize.Bytes(uint64(snapshot.TotalBytes)))
+	} else {
+		fmt.Fprintf(&sb, " %s", humanize.Bytes(uint64(snapshot.Bytes)))
+	}
+	fmt.Fprintf(&sb, " %s/s", humanize.Bytes(uint64(snapshot.BytesPerSecond)))
+
+	if snapshot.Rows > 0 {
+		fmt.Fprintf(&sb, " %s rows (%s/s)", humanize.Comma(snapshot.Rows), humanize.Comma(int64(snapshot.RowsPerSecond)))
+	}
+	if snapshot.ETA >= 0 {
+		fmt.Fprintf(&sb, " ETA %s", snapshot.ETA.Round(time.Second))
+	}
+	return sb.String()
+}
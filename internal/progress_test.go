@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock returns a clock that starts at a fixed time and can be advanced.
+func fakeClock() (func() time.Time, func(time.Duration)) {
+	now := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time { return now }, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestProgressSnapshot(t *testing.T) {
+	now, advance := fakeClock()
+	p := newProgress("import", 1000, now)
+
+	p.AddBytes(250)
+	p.AddRows(50)
+	advance(5 * time.Second)
+
+	snapshot := p.Snapshot()
+	assert.Equal(t, int64(250), snapshot.Bytes)
+	assert.Equal(t, int64(1000), snapshot.TotalBytes)
+	assert.Equal(t, int64(50), snapshot.Rows)
+	assert.Equal(t, 5*time.Second, snapshot.Elapsed)
+	assert.Equal(t, 50.0, snapshot.BytesPerSecond)
+	assert.Equal(t, 10.0, snapshot.RowsPerSecond)
+	assert.Equal(t, 25.0, snapshot.Percent)
+	assert.Equal(t, 15*time.Second, snapshot.ETA)
+}
+
+func TestProgressSnapshotUnknownTotal(t *testing.T) {
+	now, advance := fakeClock()
+	p := newProgress("download", -1, now)
+
+	p.AddBytes(100)
+	advance(time.Second)
+
+	snapshot := p.Snapshot()
+	assert.Equal(t, int64(0), snapshot.TotalBytes)
+	assert.Equal(t, -1.0, snapshot.Percent)
+	assert.Equal(t, time.Duration(-1), snapshot.ETA)
+}
+
+func TestProgressReaderCountsBytes(t *testing.T) {
+	now, _ := fakeClock()
+	p := newProgress("download", 11, now)
+
+	data, err := io.ReadAll(p.Reader(strings.NewReader("hello world")))
+
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+	assert.Equal(t, int64(11), p.Snapshot().Bytes)
+}
+
+func TestFormatBar(t *testing.T) {
+	bar := formatBar(ProgressSnapshot{
+		Name:           "import",
+		Bytes:          500_000,
+		TotalBytes:     1_000_000,
+		Rows:           12_345,
+		BytesPerSecond: 100_000,
+		RowsPerSecond:  2_469,
+		Percent:        50,
+		ETA:            5 * time.Second,
+	})
+
+	assert.Equal(t, "import [===============               ]  50.0% 500 kB/1.0 MB 100 kB/s 12,345 rows (2,469/s) ETA 5s", bar)
+}
+
+func TestFormatBarUnknownTotal(t *testing.T) {
+	bar := formatBar(ProgressSnapshot{Name: "download", Bytes: 2_000_000, BytesPerSecond: 1_000_000, Percent: -1, ETA: -1})
+
+	assert.Equal(t, "download 2.0 MB 1.0 MB/s", bar)
+}
+
+func TestLogReporter(t *testing.T) {
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(original)
+
+	logReporter(ProgressSnapshot{
+		Name:           "import",
+		Bytes:          250,
+		TotalBytes:     1000,
+		Rows:           50,
+		Elapsed:        5 * time.Second,
+		BytesPerSecond: 50,
+		RowsPerSecond:  10,
+		Percent:        25,
+		ETA:            15 * time.Second,
+	}, false)
+
+	line := buf.String()
+	assert.Contains(t, line, `"msg":"Progress"`)
+	assert.Contains(t, line, `"task":"import"`)
+	assert.Contains(t, line, `"percent":"25.0"`)
+	assert.Contains(t, line, `"eta":"15s"`)
+	assert.Contains(t, line, `"rows":50`)
+	assert.Contains(t, line, `"rowsPerSecond":10`)
+}
+
+func TestStartProgressLogMode(t *testing.T) {
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(original)
+
+	require.NoError(t, SetProgressMode("log"))
+	defer func() {
+		require.NoError(t, SetProgressMode("auto"))
+	}()
+
+	p := StartProgress("import", 10)
+	p.AddBytes(10)
+	p.Done()
+	p.Done()
+
+	assert.Equal(t, 1, strings.Count(buf.String(), `"msg":"Progress complete"`))
+}
+
+func TestSetProgressModeInvalid(t *testing.T) {
+	assert.EqualError(t, SetProgressMode("fancy"), `invalid progress mode "fancy": must be one of auto, bar, log or off`)
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// WatchForReload calls reload whenever the process receives SIGHUP, or when
+// the file at path has been replaced (e.g. by a blue/green import promoting a
+// new database). Polling is disabled when interval is zero. It blocks until
+// the context is cancelled.
+func WatchForReload(ctx context.Context, path string, interval time.Duration, reload func() error) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	current, err := os.Stat(path)
+	if err != nil {
+		slog.Warn("unable to stat database file", "path", path, "error", err)
+	}
+
+	doReload := func(reason string) {
+		slog.Info("Reloading database", "path", path, "reason", reason)
+		if err := reload(); err != nil {
+			slog.Error("failed to reload database", "path", path, "error", err)
+			return
+		}
+		if current, err = os.Stat(path); err != nil {
+			slog.Warn("unable to stat database file", "path", path, "error", err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-hup:
+			doReload("SIGHUP")
+
+		case <-tick:
+			latest, err := os.Stat(path)
+			if err != nil {
+				// Most likely mid-promotion; try again on the next tick.
+				continue
+			}
+			if current == nil || !os.SameFile(current, latest) {
+				doReload("file replaced")
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package repositories
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/map-services/company-data-api/internal/models"
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache stores serialised search results by key. Implementations are shared
+// by the repositories opened on each version of the data, and must be safe
+// for concurrent use.
+type Cache interface {
+	// Get returns the value stored under key, and whether there was one.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte) error
+}
+
+// MemoryCache is a bounded, least recently used Cache held in memory, for a
+// single server.
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int // in bytes
+	size     int
+	entries  map[string]*list.Element
+	order    *list.List // most recently used at the front
+}
+
+type cacheEntry struct {
+	key   string
+	value []byte
+}
+
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *MemoryCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).value, true, nil
+}
+
+// Set caches a value, evicting the least recently used values to make room.
+// Values too big for the cache are not cached.
+func (c *MemoryCache) Set(_ context.Context, key string, value []byte) error {
+	cost := len(key) + len(value)
+	if cost > c.capacity {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.removeLocked(element)
+	}
+	for c.size+cost > c.capacity {
+		c.removeLocked(c.order.Back())
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value})
+	c.size += cost
+	cachedBytes.Set(float64(c.size))
+	return nil
+}
+
+func (c *MemoryCache) removeLocked(element *list.Element) {
+	entry := c.order.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= len(entry.key) + len(entry.value)
+	cachedBytes.Set(float64(c.size))
+}
+
+// CachingRepository serves repeated bounding box and area searches from a
+// Cache, and runs concurrent identical searches only once. Results are cached
+// as JSON against the version of the data, so a repository opened on a newly
+// promoted import never serves results cached from an earlier one; those are
+// left to be evicted. A failing cache is logged and treated as a miss.
+type CachingRepository struct {
+	SearchRepository
+	cache   Cache
+	version string
+	group   flightGroup
+}
+
+// flightGroup runs one search at a time for each key, sharing its result
+// with the requests that join it while it runs. It is implemented by
+// *singleflight.Group.
+type flightGroup interface {
+	DoChan(key string, fn func() (any, error)) <-chan singleflight.Result
+}
+
+func NewCachingRepository(repo SearchRepository, cache Cache, version string) *CachingRepository {
+	return &CachingRepository{SearchRepository: repo, cache: cache, version: version, group: &singleflight.Group{}}
+}
+
+func (r *CachingRepository) Find(ctx context.Context, bbox []float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	// Companies are located to the metre, so a box with fractional edges
+	// finds the same companies as one shrunk to whole metres.
+	key := fmt.Sprintf("find|%.0f,%.0f,%.0f,%.0f|%d",
+		math.Ceil(bbox[LEFT]), math.Ceil(bbox[BOTTOM]),
+		math.Floor(bbox[RIGHT]), math.Floor(bbox[TOP]),
+		limit,
+	)
+	return r.cached(ctx, "find", key, rowProcessor, func(ctx context.Context, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+		return r.SearchRepository.Find(ctx, bbox, limit, rowProcessor)
+	})
+}
+
+func (r *CachingRepository) FindByArea(ctx context.Context, areaType string, code string, after string, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	key := fmt.Sprintf("find_by_area|%q|%q|%q|%d", areaType, code, after, limit)
+	return r.cached(ctx, "find_by_area", key, rowProcessor, func(ctx context.Context, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+		return r.SearchRepository.FindByArea(ctx, areaType, code, after, limit, rowProcessor)
+	})
+}
+
+func (r *CachingRepository) cached(
+	ctx context.Context,
+	query string,
+	key string,
+	rowProcessor func(companyData *models.CompanyDataWithLocation),
+	find func(ctx context.Context, rowProcessor func(companyData *models.CompanyDataWithLocation)) error,
+) error {
+	key = r.version + "|" + key
+
+	companies, ok := r.lookup(ctx, key)
+	if ok {
+		cacheRequests.WithLabelValues(query, "hit").Inc()
+	} else {
+		ran := false
+		result := r.group.DoChan(key, func() (any, error) {
+			ran = true
+			// Shared by every request waiting on the same search, so it isn't
+			// cancelled when any one of them goes away. The query timeout
+			// still applies.
+			var companies []models.CompanyDataWithLocation
+			ctx := context.WithoutCancel(ctx)
+			err := find(ctx, func(companyData *models.CompanyDataWithLocation) {
+				companies = append(companies, *companyData)
+			})
+			if err != nil {
+				return nil, err
+			}
+			r.store(ctx, key, companies)
+			return companies, nil
+		})
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case res := <-result:
+			if res.Err != nil {
+				return res.Err
+			}
+			companies = res.Val.([]models.CompanyDataWithLocation)
+		}
+		if ran {
+			cacheRequests.WithLabelValues(query, "miss").Inc()
+		} else {
+			cacheRequests.WithLabelValues(query, "coalesced").Inc()
+		}
+	}
+
+	return processCompanies(ctx, companies, 0, rowProcessor)
+}
+
+func (r *CachingRepository) lookup(ctx context.Context, key string) ([]models.CompanyDataWithLocation, bool) {
+	value, ok, err := r.cache.Get(ctx, key)
+	if err != nil {
+		cacheErrors.WithLabelValues("get").Inc()
+		slog.Warn("error reading from the search cache", "key", key, "error", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	var companies []models.CompanyDataWithLocation
+	if err := json.Unmarshal(value, &companies); err != nil {
+		cacheErrors.WithLabelValues("get").Inc()
+		slog.Warn("error decoding cached search results", "key", key, "error", err)
+		return nil, false
+	}
+	return companies, true
+}
+
+func (r *CachingRepository) store(ctx context.Context, key string, companies []models.CompanyDataWithLocation) {
+	value, err := json.Marshal(companies)
+	if err == nil {
+		err = r.cache.Set(ctx, key, value)
+	}
+	if err != nil {
+		cacheErrors.WithLabelValues("set").Inc()
+		slog.Warn("error writing to the search cache", "key", key, "error", err)
+	}
+}
+
+// DatasetVersion identifies the version of the data in the database, by when
+// each dataset was last imported. Databases built by earlier versions, which
+// don't record their imports, are given a version unique to this call, so
+// their results are only cached until they are next opened.
+func DatasetVersion(db *sql.DB) (string, error) {
+	var recorded bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE name = 'dataset_imports')").Scan(&recorded)
+	if err != nil {
+		return "", fmt.Errorf("failed to check for dataset imports: %w", err)
+	}
+
+	var version sql.NullString
+	if recorded {
+		err = db.QueryRow(`SELECT group_concat(dataset || '@' || imported_at, ',')
+			FROM (SELECT dataset, imported_at FROM dataset_imports ORDER BY dataset)`).Scan(&version)
+		if err != nil {
+			return "", fmt.Errorf("failed to determine dataset version: %w", err)
+		}
+	}
+	if !version.Valid {
+		return fmt.Sprintf("unrecorded@%d", time.Now().UnixNano()), nil
+	}
+	return version.String, nil
+}
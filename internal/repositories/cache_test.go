@@ -0,0 +1,295 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/singleflight"
+)
+
+// countingRepository finds as many companies as its limit allows, up to
+// three, counting the searches that reach it. Searches block while release
+// is set and not yet closed.
+type countingRepository struct {
+	stubRepository
+	searches atomic.Int32
+	release  chan struct{}
+	err      error
+}
+
+func (s *countingRepository) Find(ctx context.Context, bbox []float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	s.searches.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	if s.err != nil {
+		return s.err
+	}
+	for i := range 3 {
+		if i == limit && limit > 0 {
+			break
+		}
+		rowProcessor(&models.CompanyDataWithLocation{CompanyData: models.CompanyData{CompanyNumber: fmt.Sprintf("0000000%d", i)}})
+	}
+	return nil
+}
+
+func (s *countingRepository) FindByArea(ctx context.Context, areaType string, code string, after string, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	return s.Find(ctx, nil, limit, rowProcessor)
+}
+
+// joinCountingGroup counts the requests that have started or joined a search.
+type joinCountingGroup struct {
+	flightGroup
+	joined atomic.Int32
+}
+
+func (g *joinCountingGroup) DoChan(key string, fn func() (any, error)) <-chan singleflight.Result {
+	result := g.flightGroup.DoChan(key, fn)
+	g.joined.Add(1)
+	return result
+}
+
+func cacheRequestCount(query string, result string) float64 {
+	return testutil.ToFloat64(cacheRequests.WithLabelValues(query, result))
+}
+
+func cacheErrorCount(operation string) float64 {
+	return testutil.ToFloat64(cacheErrors.WithLabelValues(operation))
+}
+
+func findNumbers(t *testing.T, repo SearchRepository, bbox []float64, limit int) []string {
+	t.Helper()
+	var numbers []string
+	require.NoError(t, repo.Find(context.Background(), bbox, limit, func(cd *models.CompanyDataWithLocation) {
+		numbers = append(numbers, cd.CompanyNumber)
+	}))
+	return numbers
+}
+
+func TestCachingRepositoryFind(t *testing.T) {
+	inner := &countingRepository{}
+	repo := NewCachingRepository(inner, NewMemoryCache(1<<20), "v1")
+	bbox := []float64{600000, 141000, 602000, 143000}
+	hits, misses := cacheRequestCount("find", "hit"), cacheRequestCount("find", "miss")
+
+	assert.Equal(t, []string{"00000000", "00000001", "00000002"}, findNumbers(t, repo, bbox, 0))
+	assert.Equal(t, []string{"00000000", "00000001", "00000002"}, findNumbers(t, repo, bbox, 0))
+	assert.Equal(t, int32(1), inner.searches.Load())
+
+	// Fractional edges that take in no more whole metres are the same search.
+	assert.Len(t, findNumbers(t, repo, []float64{599999.5, 140999.1, 602000.9, 143000.2}, 0), 3)
+	assert.Equal(t, int32(1), inner.searches.Load())
+
+	assert.Equal(t, []string{"00000000"}, findNumbers(t, repo, bbox, 1))
+	assert.Len(t, findNumbers(t, repo, []float64{600000, 141000, 602001, 143000}, 0), 3)
+	assert.Equal(t, int32(3), inner.searches.Load(), "different limits and boxes are different searches")
+
+	assert.Equal(t, hits+2, cacheRequestCount("find", "hit"))
+	assert.Equal(t, misses+3, cacheRequestCount("find", "miss"))
+
+	// A repository opened on a new version of the data shares the cache, but
+	// not the results cached from the old one.
+	newer := NewCachingRepository(inner, repo.cache, "v2")
+	assert.Len(t, findNumbers(t, newer, bbox, 0), 3)
+	assert.Equal(t, int32(4), inner.searches.Load())
+}
+
+func TestCachingRepositoryFindByArea(t *testing.T) {
+	inner := &countingRepository{}
+	repo := NewCachingRepository(inner, NewMemoryCache(1<<20), "v1")
+
+	find := func(code string, after string) {
+		require.NoError(t, repo.FindByArea(context.Background(), "district", code, after, 10, func(*models.CompanyDataWithLocation) {}))
+	}
+	find("E07000105", "")
+	find("E07000105", "")
+	assert.Equal(t, int32(1), inner.searches.Load())
+
+	// Values containing the key separator can't collide with other searches.
+	find("a|b", "")
+	find("a", "b|")
+	assert.Equal(t, int32(3), inner.searches.Load())
+}
+
+func TestCachingRepositoryCoalescesConcurrentSearches(t *testing.T) {
+	inner := &countingRepository{release: make(chan struct{})}
+	repo := NewCachingRepository(inner, NewMemoryCache(1<<20), "v1")
+	group := &joinCountingGroup{flightGroup: repo.group}
+	repo.group = group
+	bbox := []float64{600000, 141000, 602000, 143000}
+	misses, coalesced := cacheRequestCount("find", "miss"), cacheRequestCount("find", "coalesced")
+
+	var wg sync.WaitGroup
+	results := make([][]string, 5)
+	for i := range results {
+		wg.Go(func() {
+			results[i] = findNumbers(t, repo, bbox, 0)
+		})
+	}
+	// The search is held until every request has started or joined it.
+	require.Eventually(t, func() bool {
+		return group.joined.Load() == int32(len(results))
+	}, time.Second, time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), inner.searches.Load())
+	for _, numbers := range results {
+		assert.Len(t, numbers, 3)
+	}
+	assert.Equal(t, misses+1, cacheRequestCount("find", "miss"))
+	assert.Equal(t, coalesced+4, cacheRequestCount("find", "coalesced"))
+}
+
+func TestCachingRepositoryDoesNotCacheErrors(t *testing.T) {
+	inner := &countingRepository{err: errors.New("boom")}
+	repo := NewCachingRepository(inner, NewMemoryCache(1<<20), "v1")
+	bbox := []float64{600000, 141000, 602000, 143000}
+
+	for range 2 {
+		err := repo.Find(context.Background(), bbox, 0, func(*models.CompanyDataWithLocation) {})
+		assert.EqualError(t, err, "boom")
+	}
+	assert.Equal(t, int32(2), inner.searches.Load())
+}
+
+func TestCachingRepositoryStopsWaitingWhenCancelled(t *testing.T) {
+	inner := &countingRepository{release: make(chan struct{})}
+	repo := NewCachingRepository(inner, NewMemoryCache(1<<20), "v1")
+	bbox := []float64{600000, 141000, 602000, 143000}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := repo.Find(ctx, bbox, 0, func(*models.CompanyDataWithLocation) {})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// The search carries on for others, and its results are cached.
+	close(inner.release)
+	require.Eventually(t, func() bool {
+		_, ok, err := repo.cache.Get(context.Background(), "v1|find|600000,141000,602000,143000|0")
+		return err == nil && ok
+	}, time.Second, time.Millisecond)
+	assert.Len(t, findNumbers(t, repo, bbox, 0), 3)
+	assert.Equal(t, int32(1), inner.searches.Load())
+}
+
+// failingCache can't be read from or written to.
+type failingCache struct{}
+
+func (failingCache) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (failingCache) Set(context.Context, string, []byte) error {
+	return errors.New("connection refused")
+}
+
+func TestCachingRepositoryWithFailingCache(t *testing.T) {
+	inner := &countingRepository{}
+	repo := NewCachingRepository(inner, failingCache{}, "v1")
+	bbox := []float64{600000, 141000, 602000, 143000}
+	getErrors := cacheErrorCount("get")
+
+	assert.Len(t, findNumbers(t, repo, bbox, 0), 3)
+	assert.Len(t, findNumbers(t, repo, bbox, 0), 3)
+	assert.Equal(t, int32(2), inner.searches.Load())
+	assert.Equal(t, getErrors+2, cacheErrorCount("get"))
+}
+
+func TestCachingRepositoryIgnoresUndecodableEntries(t *testing.T) {
+	inner := &countingRepository{}
+	cache := NewMemoryCache(1 << 20)
+	require.NoError(t, cache.Set(context.Background(), "v1|find|600000,141000,602000,143000|0", []byte("not json")))
+	repo := NewCachingRepository(inner, cache, "v1")
+
+	assert.Len(t, findNumbers(t, repo, []float64{600000, 141000, 602000, 143000}, 0), 3)
+	assert.Equal(t, int32(1), inner.searches.Load())
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	value := func(n int) []byte {
+		return make([]byte, n-1) // one-character keys
+	}
+	get := func(cache *MemoryCache, key string) bool {
+		_, ok, err := cache.Get(ctx, key)
+		require.NoError(t, err)
+		return ok
+	}
+	cache := NewMemoryCache(50)
+
+	require.NoError(t, cache.Set(ctx, "a", value(20)))
+	require.NoError(t, cache.Set(ctx, "b", value(20)))
+	require.True(t, get(cache, "a"))
+
+	// Room is made by evicting b, which was used less recently than a.
+	require.NoError(t, cache.Set(ctx, "c", value(10)))
+	require.NoError(t, cache.Set(ctx, "d", value(10)))
+	assert.False(t, get(cache, "b"))
+	for _, key := range []string{"a", "c", "d"} {
+		assert.True(t, get(cache, key), key)
+	}
+	assert.Equal(t, 40, cache.size)
+
+	// Replacing a value doesn't count it twice.
+	require.NoError(t, cache.Set(ctx, "a", value(20)))
+	assert.Equal(t, 40, cache.size)
+
+	// Values bigger than the whole cache are not cached.
+	require.NoError(t, cache.Set(ctx, "e", value(60)))
+	assert.False(t, get(cache, "e"))
+	assert.Equal(t, 40, cache.size)
+}
+
+func TestDatasetVersion(t *testing.T) {
+	db := connectTestDB(t)
+
+	// Nothing has been imported yet.
+	first, err := DatasetVersion(db)
+	require.NoError(t, err)
+	second, err := DatasetVersion(db)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	record := func(dataset string, importedAt time.Time) {
+		_, err := db.Exec(internal.RecordDatasetImportSQL, dataset, importedAt)
+		require.NoError(t, err)
+	}
+	record("companies-house", time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC))
+	record("code-point", time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC))
+	version, err := DatasetVersion(db)
+	require.NoError(t, err)
+	again, err := DatasetVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, version, again)
+	assert.Contains(t, version, "code-point@2025-08-01")
+
+	record("companies-house", time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC))
+	newer, err := DatasetVersion(db)
+	require.NoError(t, err)
+	assert.NotEqual(t, version, newer)
+}
+
+func TestDatasetVersionWithoutImportsTable(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, db.Close())
+	}()
+
+	version, err := DatasetVersion(db)
+	require.NoError(t, err)
+	assert.Contains(t, version, "unrecorded@")
+}
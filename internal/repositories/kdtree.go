@@ -0,0 +1,186 @@
+package repositories
+
+import (
+	"cmp"
+	"container/heap"
+	"math"
+	"math/rand/v2"
+)
+
+// kdNode is a point in a kdTree: a company's location and its index in the
+// companies the tree was built over.
+type kdNode struct {
+	easting  int
+	northing int
+	index    int
+}
+
+// coordinate is the node's easting on axis 0, and its northing on axis 1.
+func (n kdNode) coordinate(axis int) int {
+	if axis == 0 {
+		return n.easting
+	}
+	return n.northing
+}
+
+// kdTree is a static two-dimensional tree over company locations, held in a
+// single slice. The node in the middle of each range splits the rest of it:
+// nodes before it have coordinates on the range's axis no greater than the
+// split, and those after it no less. Ranges split by easting at even depths,
+// and by northing at odd ones.
+type kdTree []kdNode
+
+func newKDTree(nodes []kdNode) kdTree {
+	tree := kdTree(nodes)
+	tree.build(0, len(tree), 0)
+	return tree
+}
+
+func (t kdTree) build(lo int, hi int, axis int) {
+	if hi-lo <= 1 {
+		return
+	}
+	mid := lo + (hi-lo)/2
+	t.selectNth(lo, hi, mid, axis)
+	t.build(lo, mid, 1-axis)
+	t.build(mid+1, hi, 1-axis)
+}
+
+// selectNth reorders the range so that the node at n is the one that would
+// be there were the range sorted on the axis, with none greater before it
+// and none less after it.
+func (t kdTree) selectNth(lo int, hi int, n int, axis int) {
+	for hi-lo > 1 {
+		pivot := t[lo+rand.IntN(hi-lo)].coordinate(axis)
+		// Partition into nodes less than, equal to and greater than the
+		// pivot, so that runs of equal coordinates can't degrade it.
+		lt, i, gt := lo, lo, hi
+		for i < gt {
+			switch c := t[i].coordinate(axis); {
+			case c < pivot:
+				t[lt], t[i] = t[i], t[lt]
+				lt++
+				i++
+			case c > pivot:
+				gt--
+				t[gt], t[i] = t[i], t[gt]
+			default:
+				i++
+			}
+		}
+		switch {
+		case n < lt:
+			hi = lt
+		case n >= gt:
+			lo = gt
+		default:
+			return
+		}
+	}
+}
+
+// inBox calls fn with the index of each node within the box, edges
+// included.
+func (t kdTree) inBox(minEasting int, minNorthing int, maxEasting int, maxNorthing int, fn func(index int)) {
+	t.visitBox(0, len(t), 0, [4]int{minEasting, minNorthing, maxEasting, maxNorthing}, fn)
+}
+
+func (t kdTree) visitBox(lo int, hi int, axis int, box [4]int, fn func(index int)) {
+	if lo >= hi {
+		return
+	}
+	mid := lo + (hi-lo)/2
+	node := t[mid]
+	if node.easting >= box[LEFT] && node.easting <= box[RIGHT] &&
+		node.northing >= box[BOTTOM] && node.northing <= box[TOP] {
+		fn(node.index)
+	}
+	split := node.coordinate(axis)
+	if box[axis] <= split { // the box's minimum on the axis
+		t.visitBox(lo, mid, 1-axis, box, fn)
+	}
+	if box[axis+2] >= split { // and its maximum
+		t.visitBox(mid+1, hi, 1-axis, box, fn)
+	}
+}
+
+// neighbour is a candidate found by a nearest neighbour search.
+type neighbour struct {
+	index    int
+	distance float64
+}
+
+// neighbours is a max-heap of the nearest neighbours found so far, the
+// furthest on top. Of two as far away, the one ordered later by compare is
+// treated as the further.
+type neighbours struct {
+	items   []neighbour
+	compare func(a, b int) int
+}
+
+func (h *neighbours) Len() int { return len(h.items) }
+func (h *neighbours) Less(i, j int) bool {
+	if c := cmp.Compare(h.items[i].distance, h.items[j].distance); c != 0 {
+		return c > 0
+	}
+	return h.compare(h.items[i].index, h.items[j].index) > 0
+}
+func (h *neighbours) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *neighbours) Push(x any)    { h.items = append(h.items, x.(neighbour)) }
+func (h *neighbours) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// nearest returns the indexes of the k nodes nearest to a point, and no
+// further than maxDistance from it, in no particular order. Ties in distance
+// go to the nodes ordered first by compare.
+func (t kdTree) nearest(easting float64, northing float64, k int, maxDistance float64, compare func(a, b int) int) []int {
+	if k <= 0 {
+		return nil
+	}
+	found := &neighbours{compare: compare}
+	t.visitNearest(0, len(t), 0, [2]float64{easting, northing}, k, maxDistance, found)
+
+	indexes := make([]int, len(found.items))
+	for i, item := range found.items {
+		indexes[i] = item.index
+	}
+	return indexes
+}
+
+func (t kdTree) visitNearest(lo int, hi int, axis int, point [2]float64, k int, maxDistance float64, found *neighbours) {
+	if lo >= hi {
+		return
+	}
+	mid := lo + (hi-lo)/2
+	node := t[mid]
+
+	if d := math.Hypot(float64(node.easting)-point[0], float64(node.northing)-point[1]); d <= maxDistance {
+		candidate := neighbour{index: node.index, distance: d}
+		if found.Len() < k {
+			heap.Push(found, candidate)
+		} else if furthest := found.items[0]; d < furthest.distance ||
+			d == furthest.distance && found.compare(candidate.index, furthest.index) < 0 {
+			found.items[0] = candidate
+			heap.Fix(found, 0)
+		}
+	}
+
+	// Search the side of the split the point is on first, then the other
+	// side if it could hold anything nearer than the furthest found.
+	offset := point[axis] - float64(node.coordinate(axis))
+	near, far := [2]int{lo, mid}, [2]int{mid + 1, hi}
+	if offset > 0 {
+		near, far = far, near
+	}
+	t.visitNearest(near[0], near[1], 1-axis, point, k, maxDistance, found)
+	reach := maxDistance
+	if found.Len() == k {
+		reach = min(reach, found.items[0].distance)
+	}
+	if math.Abs(offset) <= reach {
+		t.visitNearest(far[0], far[1], 1-axis, point, k, maxDistance, found)
+	}
+}
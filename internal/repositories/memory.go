@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/models"
+)
+
+// MemoryRepository serves bounding box, radius and nearest neighbour
+// searches from every located company, loaded into memory and indexed by a
+// KD-tree when it is opened. Other queries go to the database. It suits
+// regional deployments small enough to hold in memory, and is a reference
+// for the SQLite searches, whose results it matches.
+type MemoryRepository struct {
+	SearchRepository
+	// companies are ordered by Morton key, then company number, as bounding
+	// box searches return them.
+	companies []models.CompanyDataWithLocation
+	tree      kdTree
+}
+
+// NewMemoryRepository loads the companies from the database, which must
+// have their locations, as added by an import.
+func NewMemoryRepository(db *sql.DB, opts ...Option) (SearchRepository, error) {
+	repo, err := NewSqliteDbRepository(db, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !repo.(*SqliteDbRepository).findByLocation {
+		return nil, errors.New("database has no company locations to load; run an import to add them")
+	}
+
+	start := time.Now()
+	companies, err := loadCompanies(db)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]kdNode, len(companies))
+	for i, companyData := range companies {
+		nodes[i] = kdNode{easting: companyData.Easting, northing: companyData.Northing, index: i}
+	}
+	slog.Info("Loaded companies into memory", "companies", len(companies), "duration", time.Since(start))
+
+	return &MemoryRepository{SearchRepository: repo, companies: companies, tree: newKDTree(nodes)}, nil
+}
+
+func loadCompanies(db *sql.DB) ([]models.CompanyDataWithLocation, error) {
+	rows, err := db.Query(internal.LoadCompaniesSQL)
+	if err != nil {
+		return nil, fmt.Errorf("error loading companies: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("error closing rows", "error", err)
+		}
+	}()
+
+	var companies []models.CompanyDataWithLocation
+	_, err = processRows(context.Background(), rows, 0, func(companyData *models.CompanyDataWithLocation) {
+		companies = append(companies, *companyData)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading companies: %w", err)
+	}
+	return companies, nil
+}
+
+func (repo *MemoryRepository) Find(ctx context.Context, bbox []float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	// Companies are located to the metre, so those within the box are those
+	// within it shrunk to whole metres.
+	var indexes []int
+	repo.tree.inBox(
+		int(math.Ceil(bbox[LEFT])), int(math.Ceil(bbox[BOTTOM])),
+		int(math.Floor(bbox[RIGHT])), int(math.Floor(bbox[TOP])),
+		func(index int) {
+			indexes = append(indexes, index)
+		},
+	)
+	slices.Sort(indexes)
+	return repo.processIndexes(ctx, indexes, limit, rowProcessor)
+}
+
+func (repo *MemoryRepository) FindWithinRadius(ctx context.Context, easting float64, northing float64, radius float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	var indexes []int
+	repo.tree.inBox(
+		int(math.Ceil(easting-radius)), int(math.Ceil(northing-radius)),
+		int(math.Floor(easting+radius)), int(math.Floor(northing+radius)),
+		func(index int) {
+			if distance(&repo.companies[index], easting, northing) <= radius {
+				indexes = append(indexes, index)
+			}
+		},
+	)
+	slices.SortFunc(indexes, repo.compareByDistance(easting, northing))
+	return repo.processIndexes(ctx, indexes, limit, rowProcessor)
+}
+
+func (repo *MemoryRepository) FindNearest(ctx context.Context, easting float64, northing float64, k int, maxDistance float64, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	indexes := repo.tree.nearest(easting, northing, k, maxDistance, repo.compareNumbers)
+	slices.SortFunc(indexes, repo.compareByDistance(easting, northing))
+	return repo.processIndexes(ctx, indexes, 0, rowProcessor)
+}
+
+func (repo *MemoryRepository) compareNumbers(a, b int) int {
+	return strings.Compare(repo.companies[a].CompanyNumber, repo.companies[b].CompanyNumber)
+}
+
+func (repo *MemoryRepository) compareByDistance(easting float64, northing float64) func(a, b int) int {
+	return func(a, b int) int {
+		return compareDistances(&repo.companies[a], &repo.companies[b], easting, northing)
+	}
+}
+
+// processIndexes passes up to limit of the companies at the indexes (all of
+// them if limit is zero) to the row processor, stopping if the context is
+// cancelled. Each gets a copy, so the loaded companies can't be changed.
+func (repo *MemoryRepository) processIndexes(ctx context.Context, indexes []int, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	if limit > 0 && len(indexes) > limit {
+		indexes = indexes[:limit]
+	}
+	for _, index := range indexes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		companyData := repo.companies[index]
+		rowProcessor(&companyData)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRandomDatabase has companies at postcodes scattered over a 4km square,
+// on a coarse enough grid that many are the same distance from a point, and
+// with up to three companies at each.
+func newRandomDatabase(t *testing.T) (SearchRepository, SearchRepository) {
+	t.Helper()
+	db := newRandomSqliteDatabase(t)
+	sqlite, err := NewSqliteDbRepository(db)
+	require.NoError(t, err)
+	memory, err := NewMemoryRepository(db)
+	require.NoError(t, err)
+	return sqlite, memory
+}
+
+// newRandomSqliteDatabase is newRandomDatabase's database, in which the
+// postcodes are also in a few areas of each type, some unnamed and some with
+// no code.
+func newRandomSqliteDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+	db := connectTestDB(t)
+	random := rand.New(rand.NewPCG(1, 2))
+	areaCode := func(prefix string, areas int) string {
+		if n := random.IntN(areas + 1); n < areas {
+			return fmt.Sprintf("%s%06d", prefix, n)
+		}
+		return ""
+	}
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	for _, area := range []struct{ code, name, areaType string }{
+		{"E10000000", "First County", "CTY"},
+		{"E07000001", "Second District", "DC"},
+		{"E05000002", "Third Ward", "DIW"},
+	} {
+		_, err := tx.Exec(internal.InsertCodePointAreaSQL, area.code, area.name, area.areaType)
+		require.NoError(t, err)
+	}
+	company := 0
+	for i := range 400 {
+		postCode := fmt.Sprintf("TN%d %dAA", 23+i/100, i%100)
+		easting, northing := 600000+random.IntN(80)*50, 140000+random.IntN(80)*50
+		_, err := tx.Exec(internal.InsertCodePointSQL, postCode, 10, easting, northing, "E92000001",
+			areaCode("E40", 2), "", areaCode("E10", 2), areaCode("E07", 4), areaCode("E05", 10),
+			internal.MortonKey(easting, northing))
+		require.NoError(t, err)
+		for range 1 + random.IntN(3) {
+			company++
+			insertCompany(t, tx, fmt.Sprintf("%08d", company), fmt.Sprintf("COMPANY %d", company), postCode)
+		}
+	}
+	require.NoError(t, tx.Commit())
+	require.NoError(t, internal.RebuildCodePointIndex(db))
+	locateCompanies(t, db)
+	return db
+}
+
+// collectMortonKeys collects the Morton keys of the companies' locations.
+func collectMortonKeys(keys *[]int64) func(cd *models.CompanyDataWithLocation) {
+	return func(cd *models.CompanyDataWithLocation) {
+		*keys = append(*keys, internal.MortonKey(cd.Easting, cd.Northing))
+	}
+}
+
+func collectCompanies(companies *[]models.CompanyDataWithLocation) func(cd *models.CompanyDataWithLocation) {
+	return func(cd *models.CompanyDataWithLocation) {
+		*companies = append(*companies, *cd)
+	}
+}
+
+func TestMemoryRepositoryMatchesSqlite(t *testing.T) {
+	sqlite, memory := newRandomDatabase(t)
+	ctx := context.Background()
+	random := rand.New(rand.NewPCG(3, 4))
+
+	for i := range 200 {
+		// Some points and boxes reach beyond the companies. Half are on the
+		// grid, with companies on their edges and many ties in distance.
+		easting, northing := 599500+random.Float64()*5000, 139500+random.Float64()*5000
+		size, radius, maxDistance := random.Float64()*2000, random.Float64()*1000, random.Float64()*3000
+		if i%2 == 0 {
+			easting, northing = math.Round(easting/50)*50, math.Round(northing/50)*50
+			size, radius, maxDistance = math.Round(size/50)*50, math.Round(radius/50)*50, math.Round(maxDistance/50)*50
+		}
+		bbox := []float64{easting, northing, easting + size, northing + size}
+		limit := random.IntN(3) * 50
+
+		var expected, actual []models.CompanyDataWithLocation
+		require.NoError(t, sqlite.Find(ctx, bbox, 0, collectCompanies(&expected)))
+		require.NoError(t, memory.Find(ctx, bbox, 0, collectCompanies(&actual)))
+		// Companies at the same Morton key may come in any order.
+		assert.ElementsMatch(t, expected, actual, "find %v", bbox)
+
+		var expectedKeys, actualKeys []int64
+		require.NoError(t, sqlite.Find(ctx, bbox, limit, collectMortonKeys(&expectedKeys)))
+		require.NoError(t, memory.Find(ctx, bbox, limit, collectMortonKeys(&actualKeys)))
+		assert.Equal(t, expectedKeys, actualKeys, "find %v, limit %d", bbox, limit)
+
+		expected, actual = nil, nil
+		require.NoError(t, sqlite.FindWithinRadius(ctx, easting, northing, radius, limit, collectCompanies(&expected)))
+		require.NoError(t, memory.FindWithinRadius(ctx, easting, northing, radius, limit, collectCompanies(&actual)))
+		assert.Equal(t, expected, actual, "radius %v from %v,%v, limit %d", radius, easting, northing, limit)
+
+		k := 1 + random.IntN(30)
+		expected, actual = nil, nil
+		require.NoError(t, sqlite.FindNearest(ctx, easting, northing, k, maxDistance, collectCompanies(&expected)))
+		require.NoError(t, memory.FindNearest(ctx, easting, northing, k, maxDistance, collectCompanies(&actual)))
+		assert.Equal(t, expected, actual, "nearest %d within %v of %v,%v", k, maxDistance, easting, northing)
+	}
+}
+
+func TestMemoryRepositoryNeedsCompanyLocations(t *testing.T) {
+	db := connectTestDB(t)
+	for _, statement := range []string{
+		"DROP INDEX idx_company_data_location",
+		"ALTER TABLE company_data DROP COLUMN morton_key",
+	} {
+		_, err := db.Exec(statement)
+		require.NoError(t, err)
+	}
+
+	_, err := NewMemoryRepository(db)
+	assert.ErrorContains(t, err, "no company locations")
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// cancelledQueries is served by the /metrics endpoint, which gathers from the
+// default registry.
+var cancelledQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "company_data_cancelled_queries_total",
+	Help: "Database queries abandoned before completing, because the client went away (canceled) or the query timeout was reached (deadline_exceeded).",
+}, []string{"query", "reason"})
+
+// observeCancellation counts a query that failed because its context was
+// cancelled, and makes sure the error it returns matches the context's
+// error, whatever the driver reported.
+func observeCancellation(ctx context.Context, query string, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+
+	reason := "canceled"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = "deadline_exceeded"
+	}
+	cancelledQueries.WithLabelValues(query, reason).Inc()
+
+	if !errors.Is(err, ctx.Err()) {
+		err = fmt.Errorf("%w: %w", ctx.Err(), err)
+	}
+	return err
+}
+
+var cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "company_data_cache_requests_total",
+	Help: "Searches looked up in the result cache: found (hit), run against the database (miss), or waiting on an identical search already running (coalesced).",
+}, []string{"query", "result"})
+
+var cacheErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "company_data_cache_errors_total",
+	Help: "Failed reads (get) and writes (set) of the result cache. Failed reads are treated as misses.",
+}, []string{"operation"})
+
+var cachedBytes = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "company_data_cache_bytes",
+	Help: "Size of the search results held in the in-memory result cache.",
+})
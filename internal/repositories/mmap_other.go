@@ -0,0 +1,20 @@
+//go:build !unix
+
+package repositories
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the first size bytes of a file into memory, where memory
+// mapping isn't supported.
+func mapFile(file *os.File, size int) ([]byte, func() error, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, nil, err
+	}
+	return data, func() error {
+		return nil
+	}, nil
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/map-services/company-data-api/internal/models"
+)
+
+// Radius and nearest neighbour searches are answered by the SQLite and
+// serving snapshot repositories with bounding box searches, keeping the
+// companies inside the circle. Every backend orders their results the same
+// way, so that they can be compared.
+
+// nearestSearchRadius is the radius of the first circle searched for the
+// companies nearest to a point. It is doubled until enough are found.
+const nearestSearchRadius = 250.0
+
+// distance is the distance in metres of a company from a point.
+func distance(companyData *models.CompanyDataWithLocation, easting float64, northing float64) float64 {
+	return math.Hypot(float64(companyData.Easting)-easting, float64(companyData.Northing)-northing)
+}
+
+// compareDistances orders companies nearest first, breaking ties by company
+// number.
+func compareDistances(a *models.CompanyDataWithLocation, b *models.CompanyDataWithLocation, easting float64, northing float64) int {
+	if c := cmp.Compare(distance(a, easting, northing), distance(b, easting, northing)); c != 0 {
+		return c
+	}
+	return strings.Compare(a.CompanyNumber, b.CompanyNumber)
+}
+
+func sortByDistance(companies []models.CompanyDataWithLocation, easting float64, northing float64) {
+	slices.SortFunc(companies, func(a, b models.CompanyDataWithLocation) int {
+		return compareDistances(&a, &b, easting, northing)
+	})
+}
+
+// processCompanies passes up to limit of the companies (all of them if limit
+// is zero) to the row processor, stopping if the context is cancelled.
+func processCompanies(ctx context.Context, companies []models.CompanyDataWithLocation, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	if limit > 0 && len(companies) > limit {
+		companies = companies[:limit]
+	}
+	for _, companyData := range companies {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rowProcessor(&companyData)
+	}
+	return nil
+}
+
+func (repo *SqliteDbRepository) FindWithinRadius(ctx context.Context, easting float64, northing float64, radius float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	return findWithinRadius(ctx, repo.Find, easting, northing, radius, limit, rowProcessor)
+}
+
+func (repo *SqliteDbRepository) FindNearest(ctx context.Context, easting float64, northing float64, k int, maxDistance float64, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	return findNearest(ctx, repo.Find, easting, northing, k, maxDistance, rowProcessor)
+}
+
+// boxFinder is a bounding box search, such as a repository's Find.
+type boxFinder func(ctx context.Context, bbox []float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error
+
+// findWithinRadius answers a radius search with a bounding box search.
+func findWithinRadius(ctx context.Context, find boxFinder, easting float64, northing float64, radius float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	companies, err := findInCircle(ctx, find, easting, northing, radius)
+	if err != nil {
+		return err
+	}
+	sortByDistance(companies, easting, northing)
+	return processCompanies(ctx, companies, limit, rowProcessor)
+}
+
+// findNearest answers a nearest neighbour search with bounding box searches.
+func findNearest(ctx context.Context, find boxFinder, easting float64, northing float64, k int, maxDistance float64, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	// Any company outside the circle is further away than all of those in
+	// it, so once the circle holds k companies, the nearest k are among them.
+	radius := min(nearestSearchRadius, maxDistance)
+	for {
+		companies, err := findInCircle(ctx, find, easting, northing, radius)
+		if err != nil {
+			return err
+		}
+		if len(companies) >= k || radius >= maxDistance {
+			sortByDistance(companies, easting, northing)
+			return processCompanies(ctx, companies, k, rowProcessor)
+		}
+		radius = min(radius*2, maxDistance)
+	}
+}
+
+// findInCircle returns the companies within radius metres of a point.
+func findInCircle(ctx context.Context, find boxFinder, easting float64, northing float64, radius float64) ([]models.CompanyDataWithLocation, error) {
+	var companies []models.CompanyDataWithLocation
+	bbox := []float64{easting - radius, northing - radius, easting + radius, northing + radius}
+	err := find(ctx, bbox, 0, func(companyData *models.CompanyDataWithLocation) {
+		if distance(companyData, easting, northing) <= radius {
+			companies = append(companies, *companyData)
+		}
+	})
+	return companies, err
+}
@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newProximityTestRepository has two companies at 601000,142000, and one
+// each 500m, 1000m and 3000m from there.
+func newProximityTestRepository(t *testing.T) SearchRepository {
+	t.Helper()
+	repo, err := NewSqliteDbRepository(newProximityTestDatabase(t))
+	require.NoError(t, err)
+	return repo
+}
+
+func newProximityTestDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+	db := connectTestDB(t)
+
+	insertCodePoint(t, db, "TN23 1AA", 601000, 142000)
+	insertCodePoint(t, db, "TN23 1AB", 601300, 142400)
+	insertCodePoint(t, db, "TN23 1AD", 602000, 142000)
+	insertCodePoint(t, db, "TN24 1AA", 604000, 142000)
+	insertCompany(t, db, "00000002", "SECOND LIMITED", "TN23 1AA")
+	insertCompany(t, db, "00000001", "FIRST LIMITED", "TN23 1AA")
+	insertCompany(t, db, "00000003", "NEAR LIMITED", "TN23 1AB")
+	insertCompany(t, db, "00000004", "EDGE LIMITED", "TN23 1AD")
+	insertCompany(t, db, "00000005", "FAR LIMITED", "TN24 1AA")
+	locateCompanies(t, db)
+	return db
+}
+
+func collectNumbers(numbers *[]string) func(cd *models.CompanyDataWithLocation) {
+	return func(cd *models.CompanyDataWithLocation) {
+		*numbers = append(*numbers, cd.CompanyNumber)
+	}
+}
+
+func TestSqliteDbRepositoryFindWithinRadius(t *testing.T) {
+	repo := newProximityTestRepository(t)
+
+	for _, test := range []struct {
+		radius   float64
+		limit    int
+		expected []string
+	}{
+		// Nearest first, ties by company number, edge included.
+		{1000, 0, []string{"00000001", "00000002", "00000003", "00000004"}},
+		{1000, 3, []string{"00000001", "00000002", "00000003"}},
+		{499, 0, []string{"00000001", "00000002"}},
+		{5000, 0, []string{"00000001", "00000002", "00000003", "00000004", "00000005"}},
+	} {
+		var numbers []string
+		require.NoError(t, repo.FindWithinRadius(context.Background(), 601000, 142000, test.radius, test.limit, collectNumbers(&numbers)))
+		assert.Equal(t, test.expected, numbers, "radius %v, limit %d", test.radius, test.limit)
+	}
+}
+
+func TestSqliteDbRepositoryFindNearest(t *testing.T) {
+	repo := newProximityTestRepository(t)
+
+	for _, test := range []struct {
+		k           int
+		maxDistance float64
+		expected    []string
+	}{
+		{1, 10000, []string{"00000001"}},
+		{3, 10000, []string{"00000001", "00000002", "00000003"}},
+		// Searching further afield until enough are found.
+		{5, 10000, []string{"00000001", "00000002", "00000003", "00000004", "00000005"}},
+		{10, 10000, []string{"00000001", "00000002", "00000003", "00000004", "00000005"}},
+		{5, 2000, []string{"00000001", "00000002", "00000003", "00000004"}},
+		{5, 100, []string{"00000001", "00000002"}},
+	} {
+		var numbers []string
+		require.NoError(t, repo.FindNearest(context.Background(), 601000, 142000, test.k, test.maxDistance, collectNumbers(&numbers)))
+		assert.Equal(t, test.expected, numbers, "k %d, max distance %v", test.k, test.maxDistance)
+	}
+
+	// From a point between companies.
+	var numbers []string
+	require.NoError(t, repo.FindNearest(context.Background(), 603500, 142000, 2, 10000, collectNumbers(&numbers)))
+	assert.Equal(t, []string{"00000005", "00000004"}, numbers)
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces the keys of cached search results, so the Redis
+// server can be shared with other applications.
+const redisKeyPrefix = "company-data:search:"
+
+// RedisCache is a Cache on a Redis-protocol server (Redis, Valkey, KeyDB and
+// so on), shared by every API server using it. Entries expire after the TTL,
+// which bounds how long results cached from an earlier version of the data
+// are kept; the server's maxmemory policy should evict the least recently
+// used keys before then if memory runs short.
+type RedisCache struct {
+	client redis.UniversalClient
+	ttl    time.Duration
+}
+
+func NewRedisCache(client redis.UniversalClient, ttl time.Duration) *RedisCache {
+	return &RedisCache{client: client, ttl: ttl}
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte) error {
+	return c.client.Set(ctx, redisKeyPrefix+key, value, c.ttl).Err()
+}
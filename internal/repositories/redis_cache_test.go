@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is an in-process server speaking just enough of the Redis
+// protocol (RESP2) for RedisCache: GET, SET with an expiry, and PING. Other
+// commands, including the HELLO handshake, get an error, as from an older
+// server.
+type fakeRedis struct {
+	mu     sync.Mutex
+	values map[string]string
+	ttls   map[string]time.Duration
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	server := &fakeRedis{values: map[string]string{}, ttls: map[string]time.Duration{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server, listener.Addr().String()
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, s.execute(args)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeRedis) execute(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		value, ok := s.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		s.values[args[1]] = args[2]
+		if len(args) == 5 && strings.EqualFold(args[3], "EX") {
+			seconds, _ := strconv.Atoi(args[4])
+			s.ttls[args[1]] = time.Duration(seconds) * time.Second
+		}
+		return "+OK\r\n"
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedisCache(t *testing.T) {
+	server, addr := startFakeRedis(t)
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer func() {
+		assert.NoError(t, client.Close())
+	}()
+	cache := NewRedisCache(client, time.Hour)
+	ctx := context.Background()
+
+	_, ok, err := cache.Get(ctx, "v1|find")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Set(ctx, "v1|find", []byte(`[{"company_number":"00000001"}]`)))
+	value, ok, err := cache.Get(ctx, "v1|find")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, `[{"company_number":"00000001"}]`, string(value))
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, time.Hour, server.ttls[redisKeyPrefix+"v1|find"])
+}
+
+func TestCachingRepositorySharesRedisCache(t *testing.T) {
+	_, addr := startFakeRedis(t)
+	bbox := []float64{600000, 141000, 602000, 143000}
+
+	// Two servers, each with its own repository and client.
+	inner := &countingRepository{}
+	for range 2 {
+		client := redis.NewClient(&redis.Options{Addr: addr})
+		repo := NewCachingRepository(inner, NewRedisCache(client, time.Hour), "v1")
+		assert.Equal(t, []string{"00000000", "00000001", "00000002"}, findNumbers(t, repo, bbox, 0))
+		assert.NoError(t, client.Close())
+	}
+	assert.Equal(t, int32(1), inner.searches.Load())
+}
+
+func TestRedisCacheUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	defer func() {
+		assert.NoError(t, client.Close())
+	}()
+	inner := &countingRepository{}
+	repo := NewCachingRepository(inner, NewRedisCache(client, time.Hour), "v1")
+	setErrors := cacheErrorCount("set")
+
+	// Searches still succeed, straight from the database.
+	assert.Len(t, findNumbers(t, repo, []float64{600000, 141000, 602000, 143000}, 0), 3)
+	assert.Equal(t, int32(1), inner.searches.Load())
+	assert.Equal(t, setErrors+1, cacheErrorCount("set"))
+}
@@ -0,0 +1,205 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/map-services/company-data-api/internal/models"
+)
+
+var ErrDatabaseUnavailable = errors.New("database is unavailable")
+
+// Database is what a repository serves: an SQLite database, or a serving
+// snapshot. It is closed when it is replaced.
+type Database interface {
+	Ping() error
+	Close() error
+}
+
+// Opener opens the database and the repository that serves it.
+type Opener func() (Database, SearchRepository, error)
+
+// ReloadableRepository is a SearchRepository that can swap the underlying
+// database for a freshly promoted one. Queries in flight finish against the
+// old database, as do queries that arrive while the new one is being opened.
+type ReloadableRepository struct {
+	reloading sync.Mutex // held for the whole of a reload
+	mu        sync.RWMutex
+	open      Opener
+	db        Database
+	repo      SearchRepository
+	snapshots *Snapshots
+}
+
+func NewReloadableRepository(open Opener) (*ReloadableRepository, error) {
+	db, repo, err := open()
+	if err != nil {
+		return nil, err
+	}
+	return &ReloadableRepository{open: open, db: db, repo: repo}, nil
+}
+
+func (r *ReloadableRepository) Find(ctx context.Context, bbox []float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.repo == nil {
+		return ErrDatabaseUnavailable
+	}
+	return r.repo.Find(ctx, bbox, limit, rowProcessor)
+}
+
+func (r *ReloadableRepository) FindWithinRadius(ctx context.Context, easting float64, northing float64, radius float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.repo == nil {
+		return ErrDatabaseUnavailable
+	}
+	return r.repo.FindWithinRadius(ctx, easting, northing, radius, limit, rowProcessor)
+}
+
+func (r *ReloadableRepository) FindNearest(ctx context.Context, easting float64, northing float64, k int, maxDistance float64, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.repo == nil {
+		return ErrDatabaseUnavailable
+	}
+	return r.repo.FindNearest(ctx, easting, northing, k, maxDistance, rowProcessor)
+}
+
+func (r *ReloadableRepository) FindByArea(ctx context.Context, areaType string, code string, after string, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.repo == nil {
+		return ErrDatabaseUnavailable
+	}
+	return r.repo.FindByArea(ctx, areaType, code, after, limit, rowProcessor)
+}
+
+func (r *ReloadableRepository) FindChanges(ctx context.Context, since time.Time, bbox []float64, after int64, limit int, rowProcessor func(change *models.CompanyChange)) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.repo == nil {
+		return ErrDatabaseUnavailable
+	}
+	return r.repo.FindChanges(ctx, since, bbox, after, limit, rowProcessor)
+}
+
+func (r *ReloadableRepository) FindTimeline(ctx context.Context, companyNumber string, rowProcessor func(event *models.TimelineEvent)) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.repo == nil {
+		return ErrDatabaseUnavailable
+	}
+	return r.repo.FindTimeline(ctx, companyNumber, rowProcessor)
+}
+
+func (r *ReloadableRepository) LocationReport(ctx context.Context) (*models.LocationReport, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.repo == nil {
+		return nil, ErrDatabaseUnavailable
+	}
+	return r.repo.LocationReport(ctx)
+}
+
+func (r *ReloadableRepository) LastUpdated() *time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.repo == nil {
+		return nil
+	}
+	return r.repo.LastUpdated()
+}
+
+// SetSnapshots enables searches as of an earlier date, against the given
+// snapshots. They are independent of the live database, so are not affected
+// by a reload.
+func (r *ReloadableRepository) SetSnapshots(snapshots *Snapshots) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.snapshots = snapshots
+}
+
+func (r *ReloadableRepository) AsOf(date time.Time) (SearchRepository, time.Time, error) {
+	r.mu.RLock()
+	snapshots := r.snapshots
+	r.mu.RUnlock()
+
+	if snapshots == nil {
+		return nil, time.Time{}, fmt.Errorf("%w: snapshots are not enabled", ErrNoSnapshot)
+	}
+	return snapshots.AsOf(date)
+}
+
+// Reload opens the database again and swaps it in, then closes the old one.
+// Queries carry on against the old database while the new one is opened,
+// and if it can't be, the old one is kept.
+func (r *ReloadableRepository) Reload() error {
+	r.reloading.Lock()
+	defer r.reloading.Unlock()
+
+	// The old database's WAL, which a replacement file at the same path
+	// would otherwise share, was emptied and removed when the replacement
+	// was promoted (see internal.PromoteStaging).
+	db, repo, err := r.open()
+	if err != nil {
+		return fmt.Errorf("failed to reopen database: %w", err)
+	}
+
+	r.mu.Lock()
+	old := r.db
+	r.db, r.repo = db, repo
+	r.mu.Unlock()
+
+	if old != nil {
+		if err := old.Close(); err != nil {
+			slog.Error("error closing database", "error", err)
+		}
+	}
+	slog.Info("Database reloaded")
+	return nil
+}
+
+func (r *ReloadableRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.closeLocked()
+	if r.snapshots != nil {
+		err = errors.Join(err, r.snapshots.Close())
+	}
+	return err
+}
+
+func (r *ReloadableRepository) closeLocked() error {
+	if r.db == nil {
+		return nil
+	}
+	err := r.db.Close()
+	r.db, r.repo = nil, nil
+	return err
+}
+
+// Pass and Name allow the repository to be used as a healthcheck, always
+// checking whichever database is currently being served.
+func (r *ReloadableRepository) Pass() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.db != nil && r.db.Ping() == nil
+}
+
+func (r *ReloadableRepository) Name() string {
+	return "sqlite3"
+}
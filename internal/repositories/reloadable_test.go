@@ -0,0 +1,190 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubRepository struct {
+	name string
+}
+
+func (s *stubRepository) Find(ctx context.Context, bbox []float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	rowProcessor(&models.CompanyDataWithLocation{CompanyData: models.CompanyData{CompanyName: s.name}})
+	return nil
+}
+
+func (s *stubRepository) FindByArea(ctx context.Context, areaType string, code string, after string, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	return s.Find(ctx, nil, limit, rowProcessor)
+}
+
+func (s *stubRepository) FindWithinRadius(ctx context.Context, easting float64, northing float64, radius float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	return s.Find(ctx, nil, limit, rowProcessor)
+}
+
+func (s *stubRepository) FindNearest(ctx context.Context, easting float64, northing float64, k int, maxDistance float64, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	return s.Find(ctx, nil, k, rowProcessor)
+}
+
+func (s *stubRepository) FindChanges(ctx context.Context, since time.Time, bbox []float64, after int64, limit int, rowProcessor func(change *models.CompanyChange)) error {
+	return nil
+}
+
+func (s *stubRepository) FindTimeline(ctx context.Context, companyNumber string, rowProcessor func(event *models.TimelineEvent)) error {
+	return nil
+}
+
+func (s *stubRepository) LocationReport(ctx context.Context) (*models.LocationReport, error) {
+	return nil, nil
+}
+
+func (s *stubRepository) LastUpdated() *time.Time {
+	return nil
+}
+
+func TestReloadableRepositorySwapsDatabase(t *testing.T) {
+	generation := 0
+	open := func() (Database, SearchRepository, error) {
+		generation++
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		mock.ExpectClose()
+		return db, &stubRepository{name: string(rune('A' + generation - 1))}, nil
+	}
+
+	repo, err := NewReloadableRepository(open)
+	require.NoError(t, err)
+
+	var names []string
+	collect := func(cd *models.CompanyDataWithLocation) { names = append(names, cd.CompanyName) }
+
+	require.NoError(t, repo.Find(context.Background(), nil, 0, collect))
+	require.NoError(t, repo.Reload())
+	require.NoError(t, repo.Find(context.Background(), nil, 0, collect))
+	assert.Equal(t, []string{"A", "B"}, names)
+	assert.NoError(t, repo.Close())
+}
+
+func TestReloadableRepositoryKeepsServingAfterFailedReload(t *testing.T) {
+	fail := false
+	generation := 0
+	open := func() (Database, SearchRepository, error) {
+		if fail {
+			return nil, nil, errors.New("boom")
+		}
+		generation++
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		mock.ExpectClose()
+		return db, &stubRepository{name: string(rune('A' + generation - 1))}, nil
+	}
+
+	repo, err := NewReloadableRepository(open)
+	require.NoError(t, err)
+
+	var names []string
+	collect := func(cd *models.CompanyDataWithLocation) { names = append(names, cd.CompanyName) }
+
+	fail = true
+	assert.Error(t, repo.Reload())
+	require.NoError(t, repo.Find(context.Background(), nil, 0, collect))
+	assert.True(t, repo.Pass())
+
+	fail = false
+	require.NoError(t, repo.Reload())
+	require.NoError(t, repo.Find(context.Background(), nil, 0, collect))
+	assert.Equal(t, []string{"A", "B"}, names)
+	assert.NoError(t, repo.Close())
+}
+
+func TestReloadableRepositoryServesWhileReopening(t *testing.T) {
+	opening, release := make(chan struct{}), make(chan struct{})
+	generation := 0
+	open := func() (Database, SearchRepository, error) {
+		generation++
+		if generation > 1 {
+			close(opening)
+			<-release
+		}
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		mock.ExpectClose()
+		return db, &stubRepository{name: string(rune('A' + generation - 1))}, nil
+	}
+
+	repo, err := NewReloadableRepository(open)
+	require.NoError(t, err)
+	reloaded := make(chan error)
+	go func() {
+		reloaded <- repo.Reload()
+	}()
+
+	var names []string
+	collect := func(cd *models.CompanyDataWithLocation) { names = append(names, cd.CompanyName) }
+	<-opening
+	require.NoError(t, repo.Find(context.Background(), nil, 0, collect))
+	close(release)
+	require.NoError(t, <-reloaded)
+	require.NoError(t, repo.Find(context.Background(), nil, 0, collect))
+	assert.Equal(t, []string{"A", "B"}, names)
+	assert.NoError(t, repo.Close())
+}
+
+// A database replaced by a promoted one is reopened while the old one is
+// still open, so must not pick up pages from the old one's WAL.
+func TestReloadableRepositoryReopensReplacedDatabase(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "companies_data.db")
+	open := func() (Database, SearchRepository, error) {
+		db, err := internal.Connect(dbPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		repo, err := NewSqliteDbRepository(db)
+		return db, repo, err
+	}
+	populate := func(db *sql.DB, prefix string) {
+		insertCodePoint(t, db, "TN23 1AA", 601000, 142000)
+		for i := range 200 {
+			insertCompany(t, db, fmt.Sprintf("%s%06d", prefix, i), prefix+" LIMITED", "TN23 1AA")
+		}
+		locateCompanies(t, db)
+	}
+
+	repo, err := NewReloadableRepository(open)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, repo.Close())
+	}()
+	// Written while being served, as a direct import would be, so the old
+	// database's WAL holds pages.
+	populate(repo.db.(*sql.DB), "OL")
+
+	staging, err := internal.Connect(filepath.Join(dir, "staging.db"))
+	require.NoError(t, err)
+	populate(staging, "NE")
+	require.NoError(t, staging.Close())
+	require.NoError(t, internal.PromoteStaging(filepath.Join(dir, "staging.db"), dbPath))
+
+	require.NoError(t, repo.Reload())
+	names := map[string]int{}
+	require.NoError(t, repo.Find(context.Background(), []float64{600000, 140000, 610000, 150000}, 0, func(cd *models.CompanyDataWithLocation) {
+		names[cd.CompanyName]++
+	}))
+	assert.Equal(t, map[string]int{"NE LIMITED": 200}, names)
+
+	var check string
+	require.NoError(t, repo.db.(*sql.DB).QueryRow("PRAGMA integrity_check").Scan(&check))
+	assert.Equal(t, "ok", check)
+}
@@ -1,9 +1,12 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -19,23 +22,109 @@ const (
 	TOP
 )
 
+// AreaColumns maps the administrative area types that can be searched on to
+// the code_point columns holding their codes.
+var AreaColumns = map[string]string{
+	"region":   "nhs_region_code",
+	"county":   "county_code",
+	"district": "district_code",
+	"ward":     "ward_code",
+}
+
+// SearchRepository runs the queries behind the API. Each query stops, and
+// returns the context's error, as soon as its context is cancelled.
 type SearchRepository interface {
-	Find(bbox []float64, processRow func(cd *models.CompanyDataWithLocation)) error
+	// Find returns up to limit companies registered within the bounding box,
+	// or all of them if limit is zero.
+	Find(ctx context.Context, bbox []float64, limit int, processRow func(cd *models.CompanyDataWithLocation)) error
+	// FindWithinRadius returns up to limit companies (all of them if limit is
+	// zero) registered within radius metres of a point, nearest first.
+	FindWithinRadius(ctx context.Context, easting float64, northing float64, radius float64, limit int, processRow func(cd *models.CompanyDataWithLocation)) error
+	// FindNearest returns the k companies registered nearest to a point,
+	// nearest first, looking no further than maxDistance metres away.
+	FindNearest(ctx context.Context, easting float64, northing float64, k int, maxDistance float64, processRow func(cd *models.CompanyDataWithLocation)) error
+	// FindByArea returns up to limit companies within the given administrative
+	// area, ordered by company number and starting after the given company
+	// number (empty for the first page).
+	FindByArea(ctx context.Context, areaType string, code string, after string, limit int, processRow func(cd *models.CompanyDataWithLocation)) error
+	// FindChanges returns up to limit changes detected at or after since,
+	// ordered by ID and starting after the given ID. If a bounding box is
+	// given, only changes to companies registered within it, before or after
+	// the change, are returned.
+	FindChanges(ctx context.Context, since time.Time, bbox []float64, after int64, limit int, processRow func(change *models.CompanyChange)) error
+	// FindTimeline returns every change recorded for a company, oldest first.
+	FindTimeline(ctx context.Context, companyNumber string, processRow func(event *models.TimelineEvent)) error
+	// LocationReport counts the companies located at their postcode or its
+	// sector or district centroid, and those that could not be located.
+	LocationReport(ctx context.Context) (*models.LocationReport, error)
 	LastUpdated() *time.Time
 }
 
 type SqliteDbRepository struct {
-	findStmt    *sql.Stmt
-	lastUpdated atomic.Value
+	db       *sql.DB
+	findStmt *sql.Stmt
+	// findByLocation is set when findStmt reads the locations copied onto
+	// company_data, by Morton key range.
+	findByLocation  bool
+	findByAreaStmts map[string]*sql.Stmt
+	changesStmt     *sql.Stmt
+	timelineStmt    *sql.Stmt
+	queryTimeout    time.Duration
+	lastUpdated     atomic.Value
+}
+
+// Option configures a SqliteDbRepository.
+type Option func(*SqliteDbRepository)
+
+// WithQueryTimeout abandons any query still running after the timeout, so
+// that an expensive search can't hold a connection indefinitely. Zero, the
+// default, means no timeout.
+func WithQueryTimeout(timeout time.Duration) Option {
+	return func(repo *SqliteDbRepository) {
+		repo.queryTimeout = timeout
+	}
 }
 
-func NewSqliteDbRepository(db *sql.DB) (SearchRepository, error) {
-	findStmt, err := prepareStatement(db)
+func NewSqliteDbRepository(db *sql.DB, opts ...Option) (SearchRepository, error) {
+	searchSQL, findByLocation, err := chooseSearchSQL(db)
+	if err != nil {
+		return nil, err
+	}
+
+	findStmt, err := prepareStatement(db, searchSQL)
 	if err != nil {
 		return nil, fmt.Errorf("error preparing statement: %w", err)
 	}
 
-	repo := SqliteDbRepository{findStmt: findStmt}
+	findByAreaStmts := make(map[string]*sql.Stmt, len(AreaColumns))
+	for areaType, column := range AreaColumns {
+		query := strings.Replace(internal.SearchByAreaSQL, "{{area_column}}", column, 1)
+		if findByAreaStmts[areaType], err = prepareStatement(db, query); err != nil {
+			return nil, fmt.Errorf("error preparing %s statement: %w", areaType, err)
+		}
+	}
+
+	changesStmt, err := prepareStatement(db, internal.ChangesSQL)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing changes statement: %w", err)
+	}
+
+	timelineStmt, err := prepareStatement(db, internal.TimelineSQL)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing timeline statement: %w", err)
+	}
+
+	repo := SqliteDbRepository{
+		db:              db,
+		findStmt:        findStmt,
+		findByLocation:  findByLocation,
+		findByAreaStmts: findByAreaStmts,
+		changesStmt:     changesStmt,
+		timelineStmt:    timelineStmt,
+	}
+	for _, opt := range opts {
+		opt(&repo)
+	}
 
 	go func() {
 		lastUpdated, err := getLastUpdated(db)
@@ -49,23 +138,143 @@ func NewSqliteDbRepository(db *sql.DB) (SearchRepository, error) {
 	return &repo, nil
 }
 
-func prepareStatement(db *sql.DB) (*sql.Stmt, error) {
-	stmt, err := db.Prepare(internal.SearchSQL)
+// chooseSearchSQL picks the bounding box search for the database. Databases
+// built by earlier versions are served read-only, without migrations, so may
+// not have company locations or the code_point spatial index.
+func chooseSearchSQL(db *sql.DB) (string, bool, error) {
+	var located, indexed bool
+	err := db.QueryRow(`SELECT
+		EXISTS (SELECT 1 FROM pragma_table_info('company_data') WHERE name = 'morton_key'),
+		EXISTS (SELECT 1 FROM sqlite_master WHERE name = 'code_point_rtree')`).Scan(&located, &indexed)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to check for spatial indexes: %w", err)
+	}
+
+	switch {
+	case located:
+		return internal.SearchSQL, true, nil
+	case indexed:
+		slog.Warn("database has no company locations, so bounding box searches will be slower; run an import to add them")
+		return internal.SearchByCodePointSQL, false, nil
+	default:
+		slog.Warn("database has no spatial index, so bounding box searches will be slower; run an import to build it")
+		return internal.SearchWithoutIndexSQL, false, nil
+	}
+}
+
+func prepareStatement(db *sql.DB, query string) (*sql.Stmt, error) {
+	stmt, err := db.Prepare(query)
 	if err != nil {
 		return nil, fmt.Errorf("error preparing SQL statement: %w", err)
 	}
 	return stmt, nil
 }
 
-func (repo *SqliteDbRepository) Find(bbox []float64, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+// maxMortonRanges caps the number of queries a bounding box search is split
+// into. More ranges fit the box more tightly, but each costs an index seek.
+const maxMortonRanges = 16
+
+// queryContext applies the query timeout, if there is one.
+func (repo *SqliteDbRepository) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *SqliteDbRepository) Find(ctx context.Context, bbox []float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	ctx, cancel := repo.queryContext(ctx)
+	defer cancel()
+	return observeCancellation(ctx, "find", repo.find(ctx, bbox, limit, rowProcessor))
+}
+
+func (repo *SqliteDbRepository) find(ctx context.Context, bbox []float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	if !repo.findByLocation {
+		// In bbox: [LEFT, BOTTOM, RIGHT, TOP]
+		_, err := findRows(ctx, repo.findStmt, limit, rowProcessor,
+			bbox[LEFT],   // = min easting
+			bbox[RIGHT],  // = max easting
+			bbox[BOTTOM], // = min northing
+			bbox[TOP],    // = max northing
+		)
+		return err
+	}
 
-	// In bbox: [LEFT, BOTTOM, RIGHT, TOP]
-	rows, err := repo.findStmt.Query(
-		bbox[LEFT],   // = min easting
-		bbox[RIGHT],  // = max easting
-		bbox[BOTTOM], // = min northing
-		bbox[TOP],    // = max northing
+	ranges := internal.MortonRanges(
+		int(math.Floor(bbox[LEFT])), int(math.Floor(bbox[BOTTOM])),
+		int(math.Ceil(bbox[RIGHT])), int(math.Ceil(bbox[TOP])),
+		maxMortonRanges,
 	)
+	// With a limit, the companies returned are those first in Morton key
+	// order, so are clustered towards the bottom left of the box rather than
+	// spread across it.
+	found := 0
+	for _, keys := range ranges {
+		remaining := 0
+		if limit > 0 {
+			remaining = limit - found
+		}
+		n, err := findRows(ctx, repo.findStmt, remaining, rowProcessor,
+			keys.Min, keys.Max,
+			bbox[LEFT], bbox[RIGHT],
+			bbox[BOTTOM], bbox[TOP],
+		)
+		if err != nil {
+			return err
+		}
+		found += n
+		if limit > 0 && found >= limit {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (repo *SqliteDbRepository) FindByArea(ctx context.Context, areaType string, code string, after string, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	stmt, ok := repo.findByAreaStmts[areaType]
+	if !ok {
+		return fmt.Errorf("unsupported area type: %q", areaType)
+	}
+
+	ctx, cancel := repo.queryContext(ctx)
+	defer cancel()
+	_, err := findRows(ctx, stmt, 0, rowProcessor, code, after, limit)
+	return observeCancellation(ctx, "find_by_area", err)
+}
+
+// findRows runs one of the search queries and passes each row to the row
+// processor, stopping after limit rows if limit is positive. It returns the
+// number of rows processed.
+func findRows(ctx context.Context, stmt *sql.Stmt, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation), args ...any) (int, error) {
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error querying database: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("error closing rows", "error", err)
+		}
+	}()
+
+	return processRows(ctx, rows, limit, rowProcessor)
+}
+
+func (repo *SqliteDbRepository) FindChanges(ctx context.Context, since time.Time, bbox []float64, after int64, limit int, rowProcessor func(change *models.CompanyChange)) error {
+	ctx, cancel := repo.queryContext(ctx)
+	defer cancel()
+	return observeCancellation(ctx, "find_changes", repo.findChanges(ctx, since, bbox, after, limit, rowProcessor))
+}
+
+func (repo *SqliteDbRepository) findChanges(ctx context.Context, since time.Time, bbox []float64, after int64, limit int, rowProcessor func(change *models.CompanyChange)) error {
+	hasBBox := 0
+	var minEasting, maxEasting, minNorthing, maxNorthing float64
+	if bbox != nil {
+		hasBBox = 1
+		minEasting, maxEasting = bbox[LEFT], bbox[RIGHT]
+		minNorthing, maxNorthing = bbox[BOTTOM], bbox[TOP]
+	}
+
+	rows, err := repo.changesStmt.QueryContext(ctx, since.UTC(), after, hasBBox, minEasting, maxEasting, minNorthing, maxNorthing, limit)
 	if err != nil {
 		return fmt.Errorf("error querying database: %w", err)
 	}
@@ -75,9 +284,105 @@ func (repo *SqliteDbRepository) Find(bbox []float64, rowProcessor func(companyDa
 		}
 	}()
 
-	var cd models.CompanyDataWithLocation
+	var change models.CompanyChange
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := rows.Scan(
+			&change.ID,
+			&change.CompanyNumber,
+			&change.ChangeType,
+			&change.OldValue,
+			&change.NewValue,
+			&change.PostCode,
+			&change.PreviousPostCode,
+			&change.DetectedAt,
+		); err != nil {
+			return fmt.Errorf("error scanning row: %w", err)
+		}
+
+		rowProcessor(&change)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *SqliteDbRepository) FindTimeline(ctx context.Context, companyNumber string, rowProcessor func(event *models.TimelineEvent)) error {
+	ctx, cancel := repo.queryContext(ctx)
+	defer cancel()
+	return observeCancellation(ctx, "find_timeline", repo.findTimeline(ctx, companyNumber, rowProcessor))
+}
+
+func (repo *SqliteDbRepository) findTimeline(ctx context.Context, companyNumber string, rowProcessor func(event *models.TimelineEvent)) error {
+	rows, err := repo.timelineStmt.QueryContext(ctx, companyNumber)
+	if err != nil {
+		return fmt.Errorf("error querying database: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("error closing rows", "error", err)
+		}
+	}()
 
+	var event models.TimelineEvent
+	var easting, northing, previousEasting, previousNorthing sql.NullFloat64
 	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := rows.Scan(
+			&event.ID,
+			&event.CompanyNumber,
+			&event.ChangeType,
+			&event.OldValue,
+			&event.NewValue,
+			&event.PostCode,
+			&event.PreviousPostCode,
+			&event.DetectedAt,
+			&easting,
+			&northing,
+			&previousEasting,
+			&previousNorthing,
+		); err != nil {
+			return fmt.Errorf("error scanning row: %w", err)
+		}
+		event.Location = toLocation(easting, northing)
+		event.PreviousLocation = toLocation(previousEasting, previousNorthing)
+
+		rowProcessor(&event)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return nil
+}
+
+// toLocation returns nil for a postcode that was not found in code_point.
+func toLocation(easting sql.NullFloat64, northing sql.NullFloat64) *models.Location {
+	if !easting.Valid || !northing.Valid {
+		return nil
+	}
+	return &models.Location{Easting: easting.Float64, Northing: northing.Float64}
+}
+
+// processRows scans each row returned by one of the search queries, which
+// all share the same column list, and passes it to the row processor, up to
+// limit rows if limit is positive. Closing the rows then stops the query.
+func processRows(ctx context.Context, rows *sql.Rows, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) (int, error) {
+	var cd models.CompanyDataWithLocation
+
+	count := 0
+	for (limit <= 0 || count < limit) && rows.Next() {
+		// Checked on every row, as the row processor would otherwise keep
+		// receiving rows already read until the driver notices.
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
 		if err := rows.Scan(
 			&cd.CompanyName,
 			&cd.CompanyNumber,
@@ -116,21 +421,99 @@ func (repo *SqliteDbRepository) Find(bbox []float64, rowProcessor func(companyDa
 			&cd.ConfStmtLastMadeUpDate,
 			&cd.Easting,
 			&cd.Northing,
+			&cd.PositionalQuality,
+			&cd.CountryCode,
+			&cd.NHSRegionCode,
+			&cd.NHSHACode,
+			&cd.CountyCode,
+			&cd.CountyName,
+			&cd.DistrictCode,
+			&cd.DistrictName,
+			&cd.WardCode,
+			&cd.WardName,
+			&cd.LocationPrecision,
 		); err != nil {
-			return fmt.Errorf("error scanning row: %w", err)
+			return count, fmt.Errorf("error scanning row: %w", err)
 		}
 
 		rowProcessor(&cd)
+		count++
 	}
-	if err = rows.Err(); err != nil {
-		return fmt.Errorf("error during rows iteration: %w", err)
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("error during rows iteration: %w", err)
 	}
 
-	return nil
+	return count, nil
+}
+
+// maxUnmatchedPostcodes caps the unmatched postcodes listed in the location
+// report.
+const maxUnmatchedPostcodes = 20
+
+// LocationReport is not prepared up front as it is only run on request, and
+// scans the whole of company_data.
+func (repo *SqliteDbRepository) LocationReport(ctx context.Context) (*models.LocationReport, error) {
+	ctx, cancel := repo.queryContext(ctx)
+	defer cancel()
+	report, err := repo.locationReport(ctx)
+	return report, observeCancellation(ctx, "location_report", err)
+}
+
+func (repo *SqliteDbRepository) locationReport(ctx context.Context) (*models.LocationReport, error) {
+	report := models.LocationReport{
+		Located:      make(map[string]int),
+		Unmatched:    make(map[string]int),
+		TopPostcodes: make([]models.UnmatchedPostcode, 0, maxUnmatchedPostcodes),
+	}
+
+	rows, err := repo.db.QueryContext(ctx, `SELECT location_precision, COUNT(*) FROM company_data
+		WHERE morton_key IS NOT NULL GROUP BY location_precision`)
+	if err != nil {
+		return nil, fmt.Errorf("error counting located companies: %w", err)
+	}
+	for rows.Next() {
+		var precision string
+		var companies int
+		if err := rows.Scan(&precision, &companies); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		report.Located[precision] = companies
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return nil, fmt.Errorf("error counting located companies: %w", err)
+	}
+
+	// Ordered by the number of companies, so the postcodes most worth fixing
+	// come first.
+	rows, err = repo.db.QueryContext(ctx, `SELECT COALESCE(reg_address_post_code, ''), COUNT(*) FROM company_data
+		WHERE morton_key IS NULL GROUP BY 1 ORDER BY 2 DESC, 1`)
+	if err != nil {
+		return nil, fmt.Errorf("error counting unmatched companies: %w", err)
+	}
+	for rows.Next() {
+		var postcode models.UnmatchedPostcode
+		if err := rows.Scan(&postcode.PostCode, &postcode.Companies); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		postcode.Reason = internal.UnmatchedReason(postcode.PostCode)
+		report.Unmatched[postcode.Reason] += postcode.Companies
+		report.TotalUnmatched += postcode.Companies
+		if len(report.TopPostcodes) < maxUnmatchedPostcodes {
+			report.TopPostcodes = append(report.TopPostcodes, postcode)
+		}
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return nil, fmt.Errorf("error counting unmatched companies: %w", err)
+	}
+
+	return &report, nil
 }
 
 func (repo *SqliteDbRepository) LastUpdated() *time.Time {
-	return repo.lastUpdated.Load().(*time.Time)
+	lastUpdated, _ := repo.lastUpdated.Load().(*time.Time)
+	return lastUpdated
 }
 
 func getLastUpdated(db *sql.DB) (*time.Time, error) {
@@ -0,0 +1,517 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/models"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func connectTestDB(t testing.TB) *sql.DB {
+	t.Helper()
+	db, err := internal.Connect(filepath.Join(t.TempDir(), "companies_data.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+	return db
+}
+
+func insertCodePoint(t testing.TB, db *sql.DB, postCode string, easting int, northing int) {
+	t.Helper()
+	_, err := db.Exec(internal.InsertCodePointSQL,
+		postCode, 10, easting, northing, "E92000001", "", "E18000007", "E10000016", "E07000105", "E05009546",
+		internal.MortonKey(easting, northing))
+	require.NoError(t, err)
+	// As the CodePoint import does once all postcodes are written.
+	require.NoError(t, internal.RebuildCodePointIndex(db))
+	require.NoError(t, internal.RebuildPostcodeCentroids(db))
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func insertCompany(t testing.TB, db execer, companyNumber string, companyName string, postCode string) {
+	t.Helper()
+	_, err := db.Exec(`
+		INSERT INTO company_data (
+			company_name, company_number, reg_address_care_of, reg_address_po_box,
+			reg_address_address_line_1, reg_address_address_line_2, reg_address_post_town,
+			reg_address_county, reg_address_country, reg_address_post_code,
+			company_category, company_status, country_of_origin, incorporation_date,
+			accounts_account_ref_day, accounts_account_ref_month, accounts_account_category,
+			mortgages_num_charges, mortgages_num_outstanding, mortgages_num_part_satisfied, mortgages_num_satisfied,
+			sic_code_1, sic_code_2, sic_code_3, sic_code_4,
+			limited_partnerships_num_gen_partners, limited_partnerships_num_lim_partners, uri
+		) VALUES (?, ?, '', '', '1 High Street', '', 'Town', '', '', ?,
+			'Private Limited Company', 'Active', 'United Kingdom', '2024-01-01 00:00:00+00:00',
+			31, 12, 'MICRO ENTITY', 0, 0, 0, 0, '62020', '', '', '', 0, 0, 'http://example.com')`,
+		companyName, companyNumber, postCode)
+	require.NoError(t, err)
+}
+
+// locateCompanies copies postcode locations onto the companies, as the
+// imports do once all records are written.
+func locateCompanies(t testing.TB, db *sql.DB) {
+	t.Helper()
+	require.NoError(t, internal.LocateCompanies(db))
+}
+
+func TestSqliteDbRepositoryFind(t *testing.T) {
+	db := connectTestDB(t)
+
+	insertCodePoint(t, db, "TN23 1AA", 601000, 142000)
+	insertCodePoint(t, db, "TN23 9ZZ", 620000, 160000)
+	_, err := db.Exec(internal.InsertCodePointAreaSQL, "E07000105", "Ashford", "DC")
+	require.NoError(t, err)
+	insertCompany(t, db, "00000001", "INSIDE LIMITED", "TN23 1AA")
+	insertCompany(t, db, "00000002", "OUTSIDE LIMITED", "TN23 9ZZ")
+	insertCompany(t, db, "00000003", "UNMATCHED LIMITED", "ZZ99 9ZZ")
+	insertCompany(t, db, "00000004", "SECTOR LIMITED", "TN23 1ZZ")
+	locateCompanies(t, db)
+
+	repo, err := NewSqliteDbRepository(db)
+	require.NoError(t, err)
+
+	results := make(map[string]models.CompanyDataWithLocation)
+	err = repo.Find(context.Background(), []float64{600000, 141000, 602000, 143000}, 0, func(cd *models.CompanyDataWithLocation) {
+		results[cd.CompanyName] = *cd
+	})
+	require.NoError(t, err)
+
+	require.Len(t, results, 2)
+	inside := results["INSIDE LIMITED"]
+	assert.Equal(t, 601000, inside.Easting)
+	assert.Equal(t, 142000, inside.Northing)
+	assert.Equal(t, 10, inside.PositionalQuality)
+	assert.Equal(t, "E07000105", inside.DistrictCode)
+	assert.Equal(t, "Ashford", inside.DistrictName)
+	assert.Equal(t, "", inside.WardName)
+	assert.Equal(t, "postcode", inside.LocationPrecision)
+
+	// TN23 1ZZ is not in CodePoint, so is placed at the centroid of TN23 1.
+	sector := results["SECTOR LIMITED"]
+	assert.Equal(t, 601000, sector.Easting)
+	assert.Equal(t, 142000, sector.Northing)
+	assert.Equal(t, 0, sector.PositionalQuality)
+	assert.Equal(t, "", sector.DistrictCode)
+	assert.Equal(t, "sector", sector.LocationPrecision)
+}
+
+func TestSqliteDbRepositoryFindInEarlierDatabases(t *testing.T) {
+	// As in databases built before company locations, and then the code_point
+	// spatial index, were introduced.
+	earlier := map[string][]string{
+		"without company locations": {
+			"DROP INDEX idx_company_data_location",
+			"ALTER TABLE company_data DROP COLUMN morton_key",
+			"ALTER TABLE company_data DROP COLUMN easting",
+			"ALTER TABLE company_data DROP COLUMN northing",
+			"ALTER TABLE company_data DROP COLUMN location_precision",
+		},
+		"without spatial indexes": {
+			"DROP INDEX idx_company_data_location",
+			"ALTER TABLE company_data DROP COLUMN morton_key",
+			"ALTER TABLE company_data DROP COLUMN easting",
+			"ALTER TABLE company_data DROP COLUMN northing",
+			"ALTER TABLE company_data DROP COLUMN location_precision",
+			"DROP TABLE code_point_rtree",
+		},
+	}
+	for name, statements := range earlier {
+		t.Run(name, func(t *testing.T) {
+			db := connectTestDB(t)
+
+			insertCodePoint(t, db, "TN23 1AA", 601000, 142000)
+			insertCodePoint(t, db, "TN23 9ZZ", 620000, 160000)
+			insertCompany(t, db, "00000001", "INSIDE LIMITED", "TN23 1AA")
+			insertCompany(t, db, "00000002", "OUTSIDE LIMITED", "TN23 9ZZ")
+			for _, statement := range statements {
+				_, err := db.Exec(statement)
+				require.NoError(t, err)
+			}
+
+			repo, err := NewSqliteDbRepository(db)
+			require.NoError(t, err)
+
+			var results []models.CompanyDataWithLocation
+			err = repo.Find(context.Background(), []float64{600000, 141000, 602000, 143000}, 0, func(cd *models.CompanyDataWithLocation) {
+				results = append(results, *cd)
+			})
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Equal(t, "INSIDE LIMITED", results[0].CompanyName)
+			assert.Equal(t, 601000, results[0].Easting)
+			assert.Equal(t, "postcode", results[0].LocationPrecision)
+		})
+	}
+}
+
+func TestSqliteDbRepositoryFindByArea(t *testing.T) {
+	db := connectTestDB(t)
+
+	insertCodePoint(t, db, "TN23 1AA", 601000, 142000)
+	insertCodePoint(t, db, "TN23 9ZZ", 620000, 160000)
+	insertCompany(t, db, "00000003", "THIRD LIMITED", "TN23 9ZZ")
+	insertCompany(t, db, "00000001", "FIRST LIMITED", "TN23 1AA")
+	insertCompany(t, db, "00000002", "SECOND LIMITED", "TN23 1AA")
+	_, err := db.Exec("UPDATE code_point SET district_code = 'E07000041' WHERE post_code = 'TN23 9ZZ'")
+	require.NoError(t, err)
+
+	repo, err := NewSqliteDbRepository(db)
+	require.NoError(t, err)
+
+	find := func(areaType string, code string, after string, limit int) []string {
+		var numbers []string
+		err := repo.FindByArea(context.Background(), areaType, code, after, limit, func(cd *models.CompanyDataWithLocation) {
+			numbers = append(numbers, cd.CompanyNumber)
+		})
+		require.NoError(t, err)
+		return numbers
+	}
+
+	assert.Equal(t, []string{"00000001", "00000002"}, find("district", "E07000105", "", 10))
+	assert.Equal(t, []string{"00000001"}, find("district", "E07000105", "", 1))
+	assert.Equal(t, []string{"00000002"}, find("district", "E07000105", "00000001", 1))
+	assert.Equal(t, []string{"00000003"}, find("district", "E07000041", "", 10))
+	assert.Equal(t, []string{"00000001", "00000002", "00000003"}, find("ward", "E05009546", "", 10))
+
+	err = repo.FindByArea(context.Background(), "parish", "E04000001", "", 10, func(*models.CompanyDataWithLocation) {})
+	assert.Error(t, err)
+}
+
+func TestSqliteDbRepositoryFindChanges(t *testing.T) {
+	db := connectTestDB(t)
+
+	insertCodePoint(t, db, "TN23 1AA", 601000, 142000)
+	insertCodePoint(t, db, "TN23 9ZZ", 620000, 160000)
+	august := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	september := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	// TN23 1ZZ is not in CodePoint, so 00000005 is located at the centroid
+	// of the TN23 1 sector.
+	insertCompany(t, db, "00000002", "OLD LIMITED", "TN23 9ZZ")
+	insertCompany(t, db, "00000003", "MOVING LIMITED", "TN23 1AA")
+	insertCompany(t, db, "00000004", "REMOVED LIMITED", "TN23 1AA")
+	insertCompany(t, db, "00000005", "CENTROID LIMITED", "TN23 1ZZ")
+	locateCompanies(t, db)
+
+	// As the imports do: changes are recorded before the companies are
+	// overwritten, then given the companies' new locations.
+	record := func(changes ...models.CompanyChange) {
+		for _, change := range changes {
+			_, err := db.Exec(internal.InsertCompanyChangeSQL, change.CompanyNumber, change.ChangeType, change.OldValue, change.NewValue,
+				change.PostCode, change.PreviousPostCode, change.DetectedAt)
+			require.NoError(t, err)
+		}
+	}
+	overwrite := func(companyNumber string, companyName string, postCode string) {
+		_, err := db.Exec("DELETE FROM company_data WHERE company_number = ?", companyNumber)
+		require.NoError(t, err)
+		insertCompany(t, db, companyNumber, companyName, postCode)
+	}
+	finish := func() {
+		locateCompanies(t, db)
+		require.NoError(t, internal.LocateCompanyChanges(db))
+	}
+
+	record(models.CompanyChange{CompanyNumber: "00000001", ChangeType: models.ChangeIncorporated, NewValue: "FIRST LIMITED", PostCode: "TN23 1AA", DetectedAt: august})
+	overwrite("00000001", "FIRST LIMITED", "TN23 1AA")
+	finish()
+
+	record(
+		models.CompanyChange{CompanyNumber: "00000002", ChangeType: models.ChangeRenamed, OldValue: "OLD LIMITED", NewValue: "NEW LIMITED", PostCode: "TN23 9ZZ", DetectedAt: september},
+		models.CompanyChange{CompanyNumber: "00000003", ChangeType: models.ChangeAddressChanged, PostCode: "TN23 9ZZ", PreviousPostCode: "TN23 1AA", DetectedAt: september},
+		models.CompanyChange{CompanyNumber: "00000005", ChangeType: models.ChangeRenamed, OldValue: "CENTROID LIMITED", NewValue: "SECTOR LIMITED", PostCode: "TN23 1ZZ", DetectedAt: september},
+	)
+	_, err := db.Exec("CREATE TEMP TABLE seen (key TEXT PRIMARY KEY)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO seen (key) VALUES ('00000001'), ('00000002'), ('00000003'), ('00000005')")
+	require.NoError(t, err)
+	_, err = db.Exec(strings.Replace(internal.InsertRemovedCompanyChangesSQL, "{{seen_table}}", "seen", 1), september)
+	require.NoError(t, err)
+	overwrite("00000002", "NEW LIMITED", "TN23 9ZZ")
+	overwrite("00000003", "MOVING LIMITED", "TN23 9ZZ")
+	overwrite("00000005", "SECTOR LIMITED", "TN23 1ZZ")
+	_, err = db.Exec("DELETE FROM company_data WHERE company_number = '00000004'")
+	require.NoError(t, err)
+	finish()
+
+	repo, err := NewSqliteDbRepository(db)
+	require.NoError(t, err)
+
+	find := func(since time.Time, bbox []float64, after int64, limit int) []models.CompanyChange {
+		var changes []models.CompanyChange
+		err := repo.FindChanges(context.Background(), since, bbox, after, limit, func(change *models.CompanyChange) {
+			changes = append(changes, *change)
+		})
+		require.NoError(t, err)
+		return changes
+	}
+	numbers := func(changes []models.CompanyChange) []string {
+		var numbers []string
+		for _, change := range changes {
+			numbers = append(numbers, change.CompanyNumber)
+		}
+		return numbers
+	}
+
+	all := find(time.Time{}, nil, 0, 10)
+	assert.Equal(t, []string{"00000001", "00000002", "00000003", "00000005", "00000004"}, numbers(all))
+	assert.Equal(t, "OLD LIMITED", all[1].OldValue)
+	assert.Equal(t, "NEW LIMITED", all[1].NewValue)
+	assert.True(t, september.Equal(all[1].DetectedAt))
+
+	assert.Equal(t, []string{"00000002", "00000003", "00000005", "00000004"}, numbers(find(september, nil, 0, 10)))
+	assert.Equal(t, []string{"00000002"}, numbers(find(september, nil, 0, 1)))
+	assert.Equal(t, []string{"00000003", "00000005", "00000004"}, numbers(find(september, nil, all[1].ID, 10)))
+
+	// The address change matches on either its old or new location, and the
+	// company located at its sector centroid matches on that.
+	assert.Equal(t, []string{"00000001", "00000003", "00000005", "00000004"}, numbers(find(time.Time{}, []float64{600000, 141000, 602000, 143000}, 0, 10)))
+	assert.Equal(t, []string{"00000002", "00000003"}, numbers(find(time.Time{}, []float64{619000, 159000, 621000, 161000}, 0, 10)))
+	assert.Empty(t, find(time.Time{}, []float64{0, 0, 1000, 1000}, 0, 10))
+}
+
+func TestSqliteDbRepositoryFindTimeline(t *testing.T) {
+	db := connectTestDB(t)
+
+	insertCodePoint(t, db, "TN23 1AA", 601000, 142000)
+	insertCodePoint(t, db, "TN23 9ZZ", 620000, 160000)
+	august := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	september := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	for _, change := range []models.CompanyChange{
+		{CompanyNumber: "00000001", ChangeType: models.ChangeIncorporated, NewValue: "FIRST LIMITED", PostCode: "TN23 1AA", DetectedAt: august},
+		{CompanyNumber: "00000002", ChangeType: models.ChangeRenamed, OldValue: "OLD LIMITED", NewValue: "NEW LIMITED", PostCode: "TN23 9ZZ", DetectedAt: august},
+		{CompanyNumber: "00000001", ChangeType: models.ChangeAddressChanged, PostCode: "TN23 9ZZ", PreviousPostCode: "TN23 1AA", DetectedAt: september},
+		{CompanyNumber: "00000001", ChangeType: models.ChangeAccountsFiled, OldValue: "2024-03-31", NewValue: "2025-03-31", PostCode: "ZZ99 9ZZ", DetectedAt: september},
+	} {
+		_, err := db.Exec(internal.InsertCompanyChangeSQL, change.CompanyNumber, change.ChangeType, change.OldValue, change.NewValue,
+			change.PostCode, change.PreviousPostCode, change.DetectedAt)
+		require.NoError(t, err)
+	}
+
+	repo, err := NewSqliteDbRepository(db)
+	require.NoError(t, err)
+
+	var events []models.TimelineEvent
+	err = repo.FindTimeline(context.Background(), "00000001", func(event *models.TimelineEvent) {
+		events = append(events, *event)
+	})
+	require.NoError(t, err)
+
+	require.Len(t, events, 3)
+	assert.Equal(t, models.ChangeIncorporated, events[0].ChangeType)
+	assert.Equal(t, &models.Location{Easting: 601000, Northing: 142000}, events[0].Location)
+	assert.Nil(t, events[0].PreviousLocation)
+
+	assert.Equal(t, models.ChangeAddressChanged, events[1].ChangeType)
+	assert.True(t, september.Equal(events[1].DetectedAt))
+	assert.Equal(t, &models.Location{Easting: 620000, Northing: 160000}, events[1].Location)
+	assert.Equal(t, &models.Location{Easting: 601000, Northing: 142000}, events[1].PreviousLocation)
+
+	assert.Equal(t, "2025-03-31", events[2].NewValue)
+	assert.Nil(t, events[2].Location, "the postcode is not in code_point")
+
+	events = nil
+	require.NoError(t, repo.FindTimeline(context.Background(), "99999999", func(event *models.TimelineEvent) {
+		events = append(events, *event)
+	}))
+	assert.Empty(t, events)
+}
+
+func cancelledQueryCount(query string, reason string) float64 {
+	return testutil.ToFloat64(cancelledQueries.WithLabelValues(query, reason))
+}
+
+func TestSqliteDbRepositoryFindStopsWhenCancelled(t *testing.T) {
+	db := connectTestDB(t)
+
+	insertCodePoint(t, db, "TN23 1AA", 601000, 142000)
+	for i := range 3 {
+		insertCompany(t, db, fmt.Sprintf("0000000%d", i), "INSIDE LIMITED", "TN23 1AA")
+	}
+	locateCompanies(t, db)
+	bbox := []float64{600000, 141000, 602000, 143000}
+
+	t.Run("by the client", func(t *testing.T) {
+		repo, err := NewSqliteDbRepository(db)
+		require.NoError(t, err)
+		before := cancelledQueryCount("find", "canceled")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		rows := 0
+		err = repo.Find(ctx, bbox, 0, func(*models.CompanyDataWithLocation) {
+			rows++
+			cancel()
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, rows)
+		assert.Equal(t, before+1, cancelledQueryCount("find", "canceled"))
+	})
+
+	t.Run("by the query timeout", func(t *testing.T) {
+		// Long enough for the first row to arrive on a loaded machine, and
+		// then well past by the time that row has been processed.
+		const timeout = 250 * time.Millisecond
+		repo, err := NewSqliteDbRepository(db, WithQueryTimeout(timeout))
+		require.NoError(t, err)
+		before := cancelledQueryCount("find", "deadline_exceeded")
+
+		rows := 0
+		err = repo.Find(context.Background(), bbox, 0, func(*models.CompanyDataWithLocation) {
+			rows++
+			time.Sleep(2 * timeout)
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 1, rows)
+		assert.Equal(t, before+1, cancelledQueryCount("find", "deadline_exceeded"))
+
+		// Each query gets the full timeout.
+		rows = 0
+		require.NoError(t, repo.Find(context.Background(), bbox, 0, func(*models.CompanyDataWithLocation) {
+			rows++
+		}))
+		assert.Equal(t, 3, rows)
+	})
+}
+
+func TestSqliteDbRepositoryFindLimit(t *testing.T) {
+	db := connectTestDB(t)
+
+	// Two postcodes far enough apart to fall in different Morton key ranges.
+	insertCodePoint(t, db, "TN23 1AA", 600100, 141100)
+	insertCodePoint(t, db, "TN23 9ZZ", 601900, 142900)
+	for i, postcode := range []string{"TN23 1AA", "TN23 1AA", "TN23 9ZZ", "TN23 9ZZ"} {
+		insertCompany(t, db, fmt.Sprintf("0000000%d", i), "INSIDE LIMITED", postcode)
+	}
+	locateCompanies(t, db)
+	bbox := []float64{600000, 141000, 602000, 143000}
+
+	repo, err := NewSqliteDbRepository(db)
+	require.NoError(t, err)
+	before := cancelledQueryCount("find", "canceled")
+
+	for _, limit := range []int{1, 2, 3, 4, 5} {
+		rows := 0
+		require.NoError(t, repo.Find(context.Background(), bbox, limit, func(*models.CompanyDataWithLocation) {
+			rows++
+		}))
+		assert.Equal(t, min(limit, 4), rows, "limit %d", limit)
+	}
+	assert.Equal(t, before, cancelledQueryCount("find", "canceled"), "stopping at the limit is not a cancellation")
+}
+
+func TestSqliteDbRepositoryLocationReport(t *testing.T) {
+	db := connectTestDB(t)
+
+	insertCodePoint(t, db, "TN23 1AA", 601000, 142000)
+	insertCompany(t, db, "00000001", "POSTCODE LIMITED", "TN23 1AA")
+	insertCompany(t, db, "00000002", "SECTOR LIMITED", "TN23 1ZZ")
+	insertCompany(t, db, "00000003", "DISTRICT LIMITED", "TN23 9ZZ")
+	insertCompany(t, db, "00000004", "BELFAST LIMITED", "BT1 1AA")
+	insertCompany(t, db, "00000005", "BELFAST TWO LIMITED", "BT1 1AA")
+	insertCompany(t, db, "00000006", "PARIS LIMITED", "75008")
+	insertCompany(t, db, "00000007", "NOWHERE LIMITED", "")
+	insertCompany(t, db, "00000008", "TYPO LIMITED", "ZZ99 9ZZ")
+	locateCompanies(t, db)
+
+	repo, err := NewSqliteDbRepository(db)
+	require.NoError(t, err)
+
+	report, err := repo.LocationReport(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int{"postcode": 1, "sector": 1, "district": 1}, report.Located)
+	assert.Equal(t, map[string]int{
+		internal.UnmatchedOutsideCoverage: 2,
+		internal.UnmatchedInvalid:         1,
+		internal.UnmatchedMissing:         1,
+		internal.UnmatchedUnknown:         1,
+	}, report.Unmatched)
+	assert.Equal(t, 5, report.TotalUnmatched)
+	require.Len(t, report.TopPostcodes, 4)
+	assert.Equal(t, models.UnmatchedPostcode{PostCode: "BT1 1AA", Reason: internal.UnmatchedOutsideCoverage, Companies: 2}, report.TopPostcodes[0])
+}
+
+// BenchmarkSqliteDbRepositoryFind compares bounding box searches by company
+// location and through the code_point spatial index with range scans of the
+// (easting, northing) index of code_point. The data
+// is a dense 5km square of postcodes, each with a company, inside a band of
+// sparser postcodes running the length of the country at the same eastings:
+// a range scan can only use the easting range, so reads the whole band.
+func BenchmarkSqliteDbRepositoryFind(b *testing.B) {
+	db := connectTestDB(b)
+
+	tx, err := db.Begin()
+	require.NoError(b, err)
+	for easting := 530000; easting < 535000; easting += 25 {
+		for northing := 0; northing < 1200000; northing += 1000 {
+			_, err := tx.Exec(internal.InsertCodePointSQL,
+				fmt.Sprintf("ZZ %d %d", easting, northing), 10, easting, northing, "E92000001", "", "", "", "", "",
+				internal.MortonKey(easting, northing))
+			require.NoError(b, err)
+		}
+		for northing := 180000; northing < 185000; northing += 25 {
+			postCode := fmt.Sprintf("EC %d %d", easting, northing)
+			_, err := tx.Exec(internal.InsertCodePointSQL, postCode, 10, easting, northing, "E92000001", "", "", "", "", "",
+				internal.MortonKey(easting, northing))
+			require.NoError(b, err)
+			insertCompany(b, tx, postCode, "COMPANY "+postCode, postCode)
+		}
+	}
+	require.NoError(b, tx.Commit())
+	require.NoError(b, internal.RebuildCodePointIndex(db))
+	locateCompanies(b, db)
+	_, err = db.Exec("ANALYZE")
+	require.NoError(b, err)
+
+	located, err := NewSqliteDbRepository(db)
+	require.NoError(b, err)
+	codePointStmt, err := prepareStatement(db, internal.SearchByCodePointSQL)
+	require.NoError(b, err)
+	rangeScanStmt, err := prepareStatement(db, internal.SearchWithoutIndexSQL)
+	require.NoError(b, err)
+
+	boxes := []struct {
+		name string
+		bbox []float64
+	}{
+		{"500m", []float64{532250, 182250, 532750, 182750}},
+		{"2km", []float64{531500, 181500, 533500, 183500}},
+		{"empty", []float64{532250, 500000, 532750, 500500}},
+	}
+	for _, query := range []struct {
+		name string
+		repo SearchRepository
+	}{
+		{"company-location", located},
+		{"code-point-rtree", &SqliteDbRepository{findStmt: codePointStmt}},
+		{"range-scan", &SqliteDbRepository{findStmt: rangeScanStmt}},
+	} {
+		for _, box := range boxes {
+			b.Run(query.name+"/"+box.name, func(b *testing.B) {
+				for b.Loop() {
+					rows := 0
+					err := query.repo.Find(context.Background(), box.bbox, 0, func(*models.CompanyDataWithLocation) {
+						rows++
+					})
+					require.NoError(b, err)
+				}
+			})
+		}
+	}
+}
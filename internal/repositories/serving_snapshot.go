@@ -0,0 +1,506 @@
+package repositories
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/models"
+)
+
+// A serving snapshot is a read-only copy of the located companies, written
+// by ExportServingSnapshot and memory-mapped by OpenServingSnapshot, for API
+// servers that don't need the whole database. The file starts with
+//
+//	magic          8 bytes, servingSnapshotMagic
+//	header offset  uint64
+//	header length  uint64
+//
+// followed by sections, each starting on an 8-byte boundary, and finally a
+// JSON header saying where each section is. All integers are little-endian.
+//
+// The companies are stored a column at a time, ordered by Morton key and then
+// company number, as bounding box searches return them. String columns are
+// dictionary-encoded: a uint32 per company indexing the column's distinct
+// values, which are stored as uint32 offsets into their concatenated bytes.
+// Integers are int32s, and dates int64 Unix times (nullTime if there is no
+// date). The Morton keys are an int64 column of their own.
+//
+// Each area type has an index of the companies with a code for that area: a
+// uint32 row number for each, ordered by code and then company number.
+
+const servingSnapshotMagic = "CDSNAP01"
+
+// servingSnapshotPreamble is the size of the magic and header location.
+const servingSnapshotPreamble = 24
+
+// nullTime marks a missing date.
+const nullTime = math.MinInt64
+
+// mortonKeyColumn is the column of Morton keys.
+const mortonKeyColumn = "morton_key"
+
+type servingSnapshotHeader struct {
+	Rows           int                               `json:"rows"`
+	DatasetVersion string                            `json:"dataset_version"`
+	LastUpdated    *time.Time                        `json:"last_updated,omitempty"`
+	LocationReport *models.LocationReport            `json:"location_report"`
+	Columns        map[string]servingSnapshotColumn  `json:"columns"`
+	AreaIndexes    map[string]servingSnapshotSection `json:"area_indexes"`
+}
+
+type servingSnapshotColumn struct {
+	Values servingSnapshotSection `json:"values"`
+	// Dictionary offsets and strings, for string columns.
+	Offsets *servingSnapshotSection `json:"offsets,omitempty"`
+	Strings *servingSnapshotSection `json:"strings,omitempty"`
+}
+
+type servingSnapshotSection struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// servingColumn is a column of a serving snapshot, named after the database
+// column, and the company field it holds: a *string, *int or **time.Time.
+type servingColumn struct {
+	name  string
+	field func(cd *models.CompanyDataWithLocation) any
+}
+
+var servingColumns = []servingColumn{
+	{"company_name", func(cd *models.CompanyDataWithLocation) any { return &cd.CompanyName }},
+	{"company_number", func(cd *models.CompanyDataWithLocation) any { return &cd.CompanyNumber }},
+	{"reg_address_care_of", func(cd *models.CompanyDataWithLocation) any { return &cd.RegAddressCareOf }},
+	{"reg_address_po_box", func(cd *models.CompanyDataWithLocation) any { return &cd.RegAddressPOBox }},
+	{"reg_address_address_line_1", func(cd *models.CompanyDataWithLocation) any { return &cd.RegAddressAddressLine1 }},
+	{"reg_address_address_line_2", func(cd *models.CompanyDataWithLocation) any { return &cd.RegAddressAddressLine2 }},
+	{"reg_address_post_town", func(cd *models.CompanyDataWithLocation) any { return &cd.RegAddressPostTown }},
+	{"reg_address_county", func(cd *models.CompanyDataWithLocation) any { return &cd.RegAddressCounty }},
+	{"reg_address_country", func(cd *models.CompanyDataWithLocation) any { return &cd.RegAddressCountry }},
+	{"reg_address_post_code", func(cd *models.CompanyDataWithLocation) any { return &cd.RegAddressPostCode }},
+	{"company_category", func(cd *models.CompanyDataWithLocation) any { return &cd.CompanyCategory }},
+	{"company_status", func(cd *models.CompanyDataWithLocation) any { return &cd.CompanyStatus }},
+	{"country_of_origin", func(cd *models.CompanyDataWithLocation) any { return &cd.CountryOfOrigin }},
+	{"dissolution_date", func(cd *models.CompanyDataWithLocation) any { return &cd.DissolutionDate }},
+	{"incorporation_date", func(cd *models.CompanyDataWithLocation) any { return &cd.IncorporationDate }},
+	{"accounts_account_ref_day", func(cd *models.CompanyDataWithLocation) any { return &cd.AccountsAccountRefDay }},
+	{"accounts_account_ref_month", func(cd *models.CompanyDataWithLocation) any { return &cd.AccountsAccountRefMonth }},
+	{"accounts_next_due_date", func(cd *models.CompanyDataWithLocation) any { return &cd.AccountsNextDueDate }},
+	{"accounts_last_made_up_date", func(cd *models.CompanyDataWithLocation) any { return &cd.AccountsLastMadeUpDate }},
+	{"accounts_account_category", func(cd *models.CompanyDataWithLocation) any { return &cd.AccountsAccountCategory }},
+	{"returns_next_due_date", func(cd *models.CompanyDataWithLocation) any { return &cd.ReturnsNextDueDate }},
+	{"returns_last_made_up_date", func(cd *models.CompanyDataWithLocation) any { return &cd.ReturnsLastMadeUpDate }},
+	{"mortgages_num_charges", func(cd *models.CompanyDataWithLocation) any { return &cd.MortgagesNumCharges }},
+	{"mortgages_num_outstanding", func(cd *models.CompanyDataWithLocation) any { return &cd.MortgagesNumOutstanding }},
+	{"mortgages_num_part_satisfied", func(cd *models.CompanyDataWithLocation) any { return &cd.MortgagesNumPartSatisfied }},
+	{"mortgages_num_satisfied", func(cd *models.CompanyDataWithLocation) any { return &cd.MortgagesNumSatisfied }},
+	{"sic_code_1", func(cd *models.CompanyDataWithLocation) any { return &cd.SICCode1 }},
+	{"sic_code_2", func(cd *models.CompanyDataWithLocation) any { return &cd.SICCode2 }},
+	{"sic_code_3", func(cd *models.CompanyDataWithLocation) any { return &cd.SICCode3 }},
+	{"sic_code_4", func(cd *models.CompanyDataWithLocation) any { return &cd.SICCode4 }},
+	{"limited_partnerships_num_gen_partners", func(cd *models.CompanyDataWithLocation) any { return &cd.LimitedPartnershipsNumGenPartners }},
+	{"limited_partnerships_num_lim_partners", func(cd *models.CompanyDataWithLocation) any { return &cd.LimitedPartnershipsNumLimPartners }},
+	{"uri", func(cd *models.CompanyDataWithLocation) any { return &cd.URI }},
+	{"conf_stmt_next_due_date", func(cd *models.CompanyDataWithLocation) any { return &cd.ConfStmtNextDueDate }},
+	{"conf_stmt_last_made_up_date", func(cd *models.CompanyDataWithLocation) any { return &cd.ConfStmtLastMadeUpDate }},
+	{"easting", func(cd *models.CompanyDataWithLocation) any { return &cd.Easting }},
+	{"northing", func(cd *models.CompanyDataWithLocation) any { return &cd.Northing }},
+	{"positional_quality", func(cd *models.CompanyDataWithLocation) any { return &cd.PositionalQuality }},
+	{"country_code", func(cd *models.CompanyDataWithLocation) any { return &cd.CountryCode }},
+	{"nhs_region_code", func(cd *models.CompanyDataWithLocation) any { return &cd.NHSRegionCode }},
+	{"nhs_ha_code", func(cd *models.CompanyDataWithLocation) any { return &cd.NHSHACode }},
+	{"county_code", func(cd *models.CompanyDataWithLocation) any { return &cd.CountyCode }},
+	{"county_name", func(cd *models.CompanyDataWithLocation) any { return &cd.CountyName }},
+	{"district_code", func(cd *models.CompanyDataWithLocation) any { return &cd.DistrictCode }},
+	{"district_name", func(cd *models.CompanyDataWithLocation) any { return &cd.DistrictName }},
+	{"ward_code", func(cd *models.CompanyDataWithLocation) any { return &cd.WardCode }},
+	{"ward_name", func(cd *models.CompanyDataWithLocation) any { return &cd.WardName }},
+	{"location_precision", func(cd *models.CompanyDataWithLocation) any { return &cd.LocationPrecision }},
+}
+
+// valueWidth is the size of a value in the column's values section.
+func (column servingColumn) valueWidth() int {
+	var cd models.CompanyDataWithLocation
+	if _, ok := column.field(&cd).(**time.Time); ok {
+		return 8
+	}
+	return 4
+}
+
+// servingColumnIndex returns the position of the named column.
+func servingColumnIndex(name string) int {
+	for i, column := range servingColumns {
+		if column.name == name {
+			return i
+		}
+	}
+	panic("no serving snapshot column " + name)
+}
+
+var (
+	eastingColumn       = servingColumnIndex("easting")
+	northingColumn      = servingColumnIndex("northing")
+	companyNumberColumn = servingColumnIndex("company_number")
+)
+
+// ServingSnapshot serves searches from a memory-mapped serving snapshot.
+// Bounding box, radius, nearest and area searches, the location report and
+// the last updated date are all answered from the file; changes and company
+// timelines aren't in it, so those queries fail with errors.ErrUnsupported.
+type ServingSnapshot struct {
+	// mu is held while the mapped file is read, so that it isn't unmapped
+	// under a search still running for a request that has gone away.
+	mu     sync.RWMutex
+	data   []byte // nil once closed
+	unmap  func() error
+	header servingSnapshotHeader
+
+	mortonKeys  []byte
+	columns     []snapshotColumn // as servingColumns
+	areaIndexes map[string][]byte
+}
+
+// snapshotColumn is a column's sections in the mapped file.
+type snapshotColumn struct {
+	values  []byte
+	offsets []byte
+	strings []byte
+}
+
+// OpenServingSnapshot maps the serving snapshot at path into memory. The
+// file is read through once, to check that every row number, dictionary entry
+// and string it refers to is within it, so that a corrupt file fails to open
+// rather than failing searches.
+func OpenServingSnapshot(path string) (*ServingSnapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open serving snapshot: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open serving snapshot: %w", err)
+	}
+	if info.Size() < servingSnapshotPreamble {
+		return nil, fmt.Errorf("%s is not a serving snapshot", path)
+	}
+	data, unmap, err := mapFile(file, int(info.Size()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to map serving snapshot: %w", err)
+	}
+
+	snapshot := &ServingSnapshot{data: data, unmap: unmap}
+	if err := snapshot.readHeader(); err != nil {
+		_ = unmap()
+		return nil, fmt.Errorf("invalid serving snapshot %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+func (s *ServingSnapshot) readHeader() error {
+	if string(s.data[:8]) != servingSnapshotMagic {
+		return errors.New("not a serving snapshot, or one written by an incompatible version")
+	}
+	header, err := s.section(servingSnapshotSection{
+		Offset: int64(binary.LittleEndian.Uint64(s.data[8:])),
+		Length: int64(binary.LittleEndian.Uint64(s.data[16:])),
+	})
+	if err != nil {
+		return fmt.Errorf("header: %w", err)
+	}
+	if err := json.Unmarshal(header, &s.header); err != nil {
+		return fmt.Errorf("header: %w", err)
+	}
+	rows := s.header.Rows
+	if rows < 0 {
+		return fmt.Errorf("header: %d rows", rows)
+	}
+
+	if s.mortonKeys, err = s.columnValues(mortonKeyColumn, 8); err != nil {
+		return err
+	}
+	s.columns = make([]snapshotColumn, len(servingColumns))
+	for i, column := range servingColumns {
+		if s.columns[i].values, err = s.columnValues(column.name, column.valueWidth()); err != nil {
+			return err
+		}
+		var cd models.CompanyDataWithLocation
+		if _, ok := column.field(&cd).(*string); !ok {
+			continue
+		}
+		sections := s.header.Columns[column.name]
+		if sections.Offsets == nil || sections.Strings == nil {
+			return fmt.Errorf("column %s has no dictionary", column.name)
+		}
+		if s.columns[i].offsets, err = s.section(*sections.Offsets); err != nil {
+			return fmt.Errorf("column %s: %w", column.name, err)
+		}
+		if s.columns[i].strings, err = s.section(*sections.Strings); err != nil {
+			return fmt.Errorf("column %s: %w", column.name, err)
+		}
+		if err := checkDictionary(s.columns[i]); err != nil {
+			return fmt.Errorf("column %s has an invalid dictionary: %w", column.name, err)
+		}
+	}
+
+	s.areaIndexes = make(map[string][]byte, len(AreaColumns))
+	for areaType := range AreaColumns {
+		index, err := s.section(s.header.AreaIndexes[areaType])
+		if err != nil || len(index)%4 != 0 || len(index)/4 > rows {
+			return fmt.Errorf("invalid %s index", areaType)
+		}
+		for i := 0; i < len(index); i += 4 {
+			if row := binary.LittleEndian.Uint32(index[i:]); int64(row) >= int64(rows) {
+				return fmt.Errorf("invalid %s index: entry %d is row %d of %d", areaType, i/4, row, rows)
+			}
+		}
+		s.areaIndexes[areaType] = index
+	}
+	return nil
+}
+
+// checkDictionary checks that a string column's dictionary offsets run in
+// order to the end of its strings, and that each of its values is an entry in
+// the dictionary.
+func checkDictionary(column snapshotColumn) error {
+	offsets := column.offsets
+	if len(offsets) < 4 || len(offsets)%4 != 0 {
+		return fmt.Errorf("%d bytes of offsets", len(offsets))
+	}
+	var previous uint32
+	for i := 0; i < len(offsets); i += 4 {
+		offset := binary.LittleEndian.Uint32(offsets[i:])
+		if offset < previous {
+			return fmt.Errorf("offset %d is before the one preceding it", i/4)
+		}
+		previous = offset
+	}
+	if int64(previous) != int64(len(column.strings)) {
+		return fmt.Errorf("offsets end at %d, not at the end of %d bytes of strings", previous, len(column.strings))
+	}
+
+	entries := uint32(len(offsets)/4 - 1)
+	for i := 0; i < len(column.values); i += 4 {
+		if id := binary.LittleEndian.Uint32(column.values[i:]); id >= entries {
+			return fmt.Errorf("row %d refers to entry %d of %d", i/4, id, entries)
+		}
+	}
+	return nil
+}
+
+// section returns a section of the mapped file, checking that it lies within
+// the file.
+func (s *ServingSnapshot) section(section servingSnapshotSection) ([]byte, error) {
+	if section.Offset < servingSnapshotPreamble || section.Length < 0 || section.Offset > int64(len(s.data)) ||
+		section.Length > int64(len(s.data))-section.Offset {
+		return nil, fmt.Errorf("section at %d of %d bytes is outside the file", section.Offset, section.Length)
+	}
+	return s.data[section.Offset : section.Offset+section.Length], nil
+}
+
+func (s *ServingSnapshot) columnValues(name string, width int) ([]byte, error) {
+	sections, ok := s.header.Columns[name]
+	if !ok {
+		return nil, fmt.Errorf("column %s is missing", name)
+	}
+	values, err := s.section(sections.Values)
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %w", name, err)
+	}
+	if len(values) != s.header.Rows*width {
+		return nil, fmt.Errorf("column %s has %d bytes, not %d", name, len(values), s.header.Rows*width)
+	}
+	return values, nil
+}
+
+// DatasetVersion is the version of the data the snapshot was exported from,
+// as given by DatasetVersion.
+func (s *ServingSnapshot) DatasetVersion() string {
+	return s.header.DatasetVersion
+}
+
+// Rows is the number of companies in the snapshot.
+func (s *ServingSnapshot) Rows() int {
+	return s.header.Rows
+}
+
+func (s *ServingSnapshot) Ping() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.data == nil {
+		return ErrDatabaseUnavailable
+	}
+	return nil
+}
+
+// Close unmaps the file, once any searches still reading it have finished.
+func (s *ServingSnapshot) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data == nil {
+		return nil
+	}
+	err := s.unmap()
+	s.data = nil
+	return err
+}
+
+// acquire holds the file mapped until release is called.
+func (s *ServingSnapshot) acquire() (release func(), err error) {
+	s.mu.RLock()
+	if s.data == nil {
+		s.mu.RUnlock()
+		return nil, ErrDatabaseUnavailable
+	}
+	return s.mu.RUnlock, nil
+}
+
+func (s *ServingSnapshot) mortonKey(row int) int64 {
+	return int64(binary.LittleEndian.Uint64(s.mortonKeys[row*8:]))
+}
+
+func (s *ServingSnapshot) int(column int, row int) int {
+	return int(int32(binary.LittleEndian.Uint32(s.columns[column].values[row*4:])))
+}
+
+// bytes returns a string value as it is in the mapped file.
+func (s *ServingSnapshot) bytes(column int, row int) []byte {
+	c := &s.columns[column]
+	id := int(binary.LittleEndian.Uint32(c.values[row*4:]))
+	start := binary.LittleEndian.Uint32(c.offsets[id*4:])
+	end := binary.LittleEndian.Uint32(c.offsets[id*4+4:])
+	return c.strings[start:end]
+}
+
+// company decodes a row, copying its strings out of the mapped file.
+func (s *ServingSnapshot) company(row int) models.CompanyDataWithLocation {
+	var cd models.CompanyDataWithLocation
+	for i, column := range servingColumns {
+		switch field := column.field(&cd).(type) {
+		case *string:
+			*field = string(s.bytes(i, row))
+		case *int:
+			*field = s.int(i, row)
+		case **time.Time:
+			if unix := int64(binary.LittleEndian.Uint64(s.columns[i].values[row*8:])); unix != nullTime {
+				date := time.Unix(unix, 0).UTC()
+				*field = &date
+			}
+		}
+	}
+	return cd
+}
+
+func (s *ServingSnapshot) Find(ctx context.Context, bbox []float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	release, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ranges := internal.MortonRanges(
+		int(math.Floor(bbox[LEFT])), int(math.Floor(bbox[BOTTOM])),
+		int(math.Ceil(bbox[RIGHT])), int(math.Ceil(bbox[TOP])),
+		maxMortonRanges,
+	)
+	found := 0
+	for _, keys := range ranges {
+		row := sort.Search(s.header.Rows, func(row int) bool {
+			return s.mortonKey(row) >= keys.Min
+		})
+		for ; row < s.header.Rows && s.mortonKey(row) <= keys.Max; row++ {
+			easting, northing := float64(s.int(eastingColumn, row)), float64(s.int(northingColumn, row))
+			if easting < bbox[LEFT] || easting > bbox[RIGHT] || northing < bbox[BOTTOM] || northing > bbox[TOP] {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			companyData := s.company(row)
+			rowProcessor(&companyData)
+			found++
+			if limit > 0 && found >= limit {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ServingSnapshot) FindWithinRadius(ctx context.Context, easting float64, northing float64, radius float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	return findWithinRadius(ctx, s.Find, easting, northing, radius, limit, rowProcessor)
+}
+
+func (s *ServingSnapshot) FindNearest(ctx context.Context, easting float64, northing float64, k int, maxDistance float64, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	return findNearest(ctx, s.Find, easting, northing, k, maxDistance, rowProcessor)
+}
+
+func (s *ServingSnapshot) FindByArea(ctx context.Context, areaType string, code string, after string, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	index, ok := s.areaIndexes[areaType]
+	if !ok {
+		return fmt.Errorf("unsupported area type: %q", areaType)
+	}
+	column := servingColumnIndex(AreaColumns[areaType])
+
+	release, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	indexRow := func(i int) int {
+		return int(binary.LittleEndian.Uint32(index[i*4:]))
+	}
+	entries := len(index) / 4
+	// The first company in the area numbered after the cursor.
+	i := sort.Search(entries, func(i int) bool {
+		row := indexRow(i)
+		if rowCode := string(s.bytes(column, row)); rowCode != code {
+			return rowCode > code
+		}
+		return string(s.bytes(companyNumberColumn, row)) > after
+	})
+	for count := 0; i < entries && count < limit; i, count = i+1, count+1 {
+		row := indexRow(i)
+		if string(s.bytes(column, row)) != code {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		companyData := s.company(row)
+		rowProcessor(&companyData)
+	}
+	return nil
+}
+
+func (s *ServingSnapshot) FindChanges(ctx context.Context, since time.Time, bbox []float64, after int64, limit int, rowProcessor func(change *models.CompanyChange)) error {
+	return fmt.Errorf("changes are not included in serving snapshots: %w", errors.ErrUnsupported)
+}
+
+func (s *ServingSnapshot) FindTimeline(ctx context.Context, companyNumber string, rowProcessor func(event *models.TimelineEvent)) error {
+	return fmt.Errorf("company timelines are not included in serving snapshots: %w", errors.ErrUnsupported)
+}
+
+// LocationReport returns the report on the database the snapshot was
+// exported from, which includes the companies left out for having no
+// location.
+func (s *ServingSnapshot) LocationReport(ctx context.Context) (*models.LocationReport, error) {
+	return s.header.LocationReport, nil
+}
+
+func (s *ServingSnapshot) LastUpdated() *time.Time {
+	return s.header.LastUpdated
+}
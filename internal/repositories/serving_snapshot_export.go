@@ -0,0 +1,275 @@
+package repositories
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/models"
+)
+
+// ExportServingSnapshot writes the located companies in the database to a
+// serving snapshot at path, replacing any file there atomically so that a
+// server watching it reloads only once it is complete. It returns the number
+// of companies written. The snapshot is built in memory, so this needs
+// memory roughly the size of the file.
+func ExportServingSnapshot(db *sql.DB, path string) (int, error) {
+	_, findByLocation, err := chooseSearchSQL(db)
+	if err != nil {
+		return 0, err
+	}
+	if !findByLocation {
+		return 0, errors.New("database has no company locations to export; run an import to add them")
+	}
+
+	header := servingSnapshotHeader{
+		Columns:     make(map[string]servingSnapshotColumn, len(servingColumns)+1),
+		AreaIndexes: make(map[string]servingSnapshotSection, len(AreaColumns)),
+	}
+	if header.DatasetVersion, err = DatasetVersion(db); err != nil {
+		return 0, err
+	}
+	if header.LastUpdated, err = getLastUpdated(db); err != nil {
+		return 0, err
+	}
+	if header.LocationReport, err = (&SqliteDbRepository{db: db}).locationReport(context.Background()); err != nil {
+		return 0, err
+	}
+
+	builder, err := buildServingSnapshot(db)
+	if err != nil {
+		return 0, err
+	}
+	header.Rows = builder.rows
+	if err := writeServingSnapshot(path, builder, &header); err != nil {
+		return 0, err
+	}
+	return builder.rows, nil
+}
+
+// servingSnapshotBuilder holds a serving snapshot's columns as they are
+// built.
+type servingSnapshotBuilder struct {
+	rows       int
+	mortonKeys []byte
+	columns    []*columnBuilder // as servingColumns
+}
+
+type columnBuilder struct {
+	values []byte
+	// The dictionary, for string columns: each distinct value, in the order
+	// first seen, and its position.
+	entries []string
+	ids     map[string]uint32
+}
+
+func (c *columnBuilder) addString(value string) {
+	id, ok := c.ids[value]
+	if !ok {
+		id = uint32(len(c.entries))
+		c.ids[value] = id
+		c.entries = append(c.entries, value)
+	}
+	c.values = binary.LittleEndian.AppendUint32(c.values, id)
+}
+
+func (c *columnBuilder) string(row int) string {
+	return c.entries[binary.LittleEndian.Uint32(c.values[row*4:])]
+}
+
+func buildServingSnapshot(db *sql.DB) (*servingSnapshotBuilder, error) {
+	builder := &servingSnapshotBuilder{columns: make([]*columnBuilder, len(servingColumns))}
+	for i := range builder.columns {
+		builder.columns[i] = &columnBuilder{ids: make(map[string]uint32)}
+	}
+
+	rows, err := db.Query(internal.LoadCompaniesSQL)
+	if err != nil {
+		return nil, fmt.Errorf("error loading companies: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("error closing rows", "error", err)
+		}
+	}()
+
+	progress := internal.StartProgress("export serving snapshot", 0)
+	defer progress.Done()
+
+	lastKey := int64(-1)
+	var outOfOrder error
+	_, err = processRows(context.Background(), rows, 0, func(cd *models.CompanyDataWithLocation) {
+		// Searches rely on the companies being in Morton key order, as the
+		// query returns them.
+		key := internal.MortonKey(cd.Easting, cd.Northing)
+		if key < lastKey && outOfOrder == nil {
+			outOfOrder = fmt.Errorf("company %s is out of Morton key order; run an import to relocate the companies", cd.CompanyNumber)
+		}
+		lastKey = key
+		builder.mortonKeys = binary.LittleEndian.AppendUint64(builder.mortonKeys, uint64(key))
+
+		for i, column := range servingColumns {
+			values := builder.columns[i]
+			switch field := column.field(cd).(type) {
+			case *string:
+				values.addString(*field)
+			case *int:
+				values.values = binary.LittleEndian.AppendUint32(values.values, uint32(int32(*field)))
+			case **time.Time:
+				unix := int64(nullTime)
+				if *field != nil {
+					unix = (*field).Unix()
+				}
+				values.values = binary.LittleEndian.AppendUint64(values.values, uint64(unix))
+			}
+		}
+		builder.rows++
+		progress.AddRows(1)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading companies: %w", err)
+	}
+	if outOfOrder != nil {
+		return nil, outOfOrder
+	}
+	return builder, nil
+}
+
+// areaIndex lists the rows with a code for the area type, ordered by code
+// and then company number.
+func (b *servingSnapshotBuilder) areaIndex(areaType string) []byte {
+	codes := b.columns[servingColumnIndex(AreaColumns[areaType])]
+	numbers := b.columns[companyNumberColumn]
+
+	var rows []int
+	for row := range b.rows {
+		if codes.string(row) != "" {
+			rows = append(rows, row)
+		}
+	}
+	slices.SortFunc(rows, func(a, b int) int {
+		return cmp.Or(
+			cmp.Compare(codes.string(a), codes.string(b)),
+			cmp.Compare(numbers.string(a), numbers.string(b)),
+		)
+	})
+
+	index := make([]byte, 0, len(rows)*4)
+	for _, row := range rows {
+		index = binary.LittleEndian.AppendUint32(index, uint32(row))
+	}
+	return index
+}
+
+// writeServingSnapshot writes the snapshot to a temporary file next to path,
+// filling in the header's sections, then moves it into place.
+func writeServingSnapshot(path string, builder *servingSnapshotBuilder, header *servingSnapshotHeader) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create serving snapshot: %w", err)
+	}
+	defer func() {
+		// Harmless once the file has been moved into place.
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+
+	w := &sectionWriter{w: bufio.NewWriterSize(file, 1<<20)}
+	// The header's location is filled in once it has been written.
+	w.write(make([]byte, servingSnapshotPreamble))
+
+	header.Columns[mortonKeyColumn] = servingSnapshotColumn{Values: w.section(builder.mortonKeys)}
+	for i, column := range servingColumns {
+		values := builder.columns[i]
+		sections := servingSnapshotColumn{Values: w.section(values.values)}
+		var cd models.CompanyDataWithLocation
+		if _, ok := column.field(&cd).(*string); ok {
+			offsets := make([]byte, 0, (len(values.entries)+1)*4)
+			var strings []byte
+			for _, entry := range values.entries {
+				offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(strings)))
+				strings = append(strings, entry...)
+			}
+			if len(strings) > 1<<32-1 {
+				return fmt.Errorf("column %s is too big for a serving snapshot", column.name)
+			}
+			offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(strings)))
+			offsetsSection, stringsSection := w.section(offsets), w.section(strings)
+			sections.Offsets, sections.Strings = &offsetsSection, &stringsSection
+		}
+		header.Columns[column.name] = sections
+	}
+	for areaType := range AreaColumns {
+		header.AreaIndexes[areaType] = w.section(builder.areaIndex(areaType))
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return fmt.Errorf("failed to encode serving snapshot header: %w", err)
+	}
+	headerSection := w.section(headerJSON)
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+	if w.err != nil {
+		return fmt.Errorf("failed to write serving snapshot: %w", w.err)
+	}
+
+	preamble := []byte(servingSnapshotMagic)
+	preamble = binary.LittleEndian.AppendUint64(preamble, uint64(headerSection.Offset))
+	preamble = binary.LittleEndian.AppendUint64(preamble, uint64(headerSection.Length))
+	if _, err := file.WriteAt(preamble, 0); err != nil {
+		return fmt.Errorf("failed to write serving snapshot: %w", err)
+	}
+	// Readable by servers running as other users, like a database file.
+	if err := file.Chmod(0o644); err != nil {
+		return fmt.Errorf("failed to write serving snapshot: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to write serving snapshot: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write serving snapshot: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to move serving snapshot into place: %w", err)
+	}
+	return nil
+}
+
+// sectionWriter writes sections, each starting on an 8-byte boundary,
+// keeping the first error.
+type sectionWriter struct {
+	w      *bufio.Writer
+	offset int64
+	err    error
+}
+
+func (w *sectionWriter) write(data []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(data)
+	w.offset += int64(n)
+	w.err = err
+}
+
+func (w *sectionWriter) section(data []byte) servingSnapshotSection {
+	if padding := (8 - w.offset%8) % 8; padding > 0 {
+		w.write(make([]byte, padding))
+	}
+	section := servingSnapshotSection{Offset: w.offset, Length: int64(len(data))}
+	w.write(data)
+	return section
+}
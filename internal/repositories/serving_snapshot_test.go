@@ -0,0 +1,212 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exportTestSnapshot exports the database to a serving snapshot and opens it.
+func exportTestSnapshot(t *testing.T, db *sql.DB) *ServingSnapshot {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "companies_data.snap")
+	_, err := ExportServingSnapshot(db, path)
+	require.NoError(t, err)
+	snapshot, err := OpenServingSnapshot(path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, snapshot.Close())
+	})
+	return snapshot
+}
+
+func TestServingSnapshotMatchesSqlite(t *testing.T) {
+	db := newRandomSqliteDatabase(t)
+	_, err := db.Exec(internal.RecordDatasetImportSQL, "companies-house", time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	sqlite, err := NewSqliteDbRepository(db)
+	require.NoError(t, err)
+	snapshot := exportTestSnapshot(t, db)
+	ctx := context.Background()
+	random := rand.New(rand.NewPCG(5, 6))
+
+	version, err := DatasetVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, version, snapshot.DatasetVersion())
+	lastUpdated, err := getLastUpdated(db)
+	require.NoError(t, err)
+	require.NotNil(t, snapshot.LastUpdated())
+	assert.True(t, lastUpdated.Equal(*snapshot.LastUpdated()))
+	expectedReport, err := sqlite.LocationReport(ctx)
+	require.NoError(t, err)
+	actualReport, err := snapshot.LocationReport(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, expectedReport, actualReport)
+
+	var all []models.CompanyDataWithLocation
+	require.NoError(t, snapshot.Find(ctx, []float64{0, 0, 700000, 1300000}, 0, collectCompanies(&all)))
+	assert.Len(t, all, snapshot.Rows())
+
+	for i := range 100 {
+		// As TestMemoryRepositoryMatchesSqlite.
+		easting, northing := 599500+random.Float64()*5000, 139500+random.Float64()*5000
+		size, radius, maxDistance := random.Float64()*2000, random.Float64()*1000, random.Float64()*3000
+		if i%2 == 0 {
+			easting, northing = math.Round(easting/50)*50, math.Round(northing/50)*50
+			size, radius, maxDistance = math.Round(size/50)*50, math.Round(radius/50)*50, math.Round(maxDistance/50)*50
+		}
+		bbox := []float64{easting, northing, easting + size, northing + size}
+		limit := random.IntN(3) * 50
+
+		var expected, actual []models.CompanyDataWithLocation
+		require.NoError(t, sqlite.Find(ctx, bbox, 0, collectCompanies(&expected)))
+		require.NoError(t, snapshot.Find(ctx, bbox, 0, collectCompanies(&actual)))
+		assert.ElementsMatch(t, expected, actual, "find %v", bbox)
+
+		var expectedKeys, actualKeys []int64
+		require.NoError(t, sqlite.Find(ctx, bbox, limit, collectMortonKeys(&expectedKeys)))
+		require.NoError(t, snapshot.Find(ctx, bbox, limit, collectMortonKeys(&actualKeys)))
+		assert.Equal(t, expectedKeys, actualKeys, "find %v, limit %d", bbox, limit)
+
+		expected, actual = nil, nil
+		require.NoError(t, sqlite.FindWithinRadius(ctx, easting, northing, radius, limit, collectCompanies(&expected)))
+		require.NoError(t, snapshot.FindWithinRadius(ctx, easting, northing, radius, limit, collectCompanies(&actual)))
+		assert.Equal(t, expected, actual, "radius %v from %v,%v, limit %d", radius, easting, northing, limit)
+
+		k := 1 + random.IntN(30)
+		expected, actual = nil, nil
+		require.NoError(t, sqlite.FindNearest(ctx, easting, northing, k, maxDistance, collectCompanies(&expected)))
+		require.NoError(t, snapshot.FindNearest(ctx, easting, northing, k, maxDistance, collectCompanies(&actual)))
+		assert.Equal(t, expected, actual, "nearest %d within %v of %v,%v", k, maxDistance, easting, northing)
+	}
+
+	for areaType, column := range AreaColumns {
+		codes := map[string]bool{"E99999999": true}
+		for _, cd := range all {
+			if code := *servingColumns[servingColumnIndex(column)].field(&cd).(*string); code != "" {
+				codes[code] = true
+			}
+		}
+		for code := range codes {
+			// Paging through the area, as the API does.
+			after := ""
+			for {
+				var expected, actual []models.CompanyDataWithLocation
+				require.NoError(t, sqlite.FindByArea(ctx, areaType, code, after, 17, collectCompanies(&expected)))
+				require.NoError(t, snapshot.FindByArea(ctx, areaType, code, after, 17, collectCompanies(&actual)))
+				require.Equal(t, expected, actual, "%s %s after %q", areaType, code, after)
+				if len(expected) == 0 {
+					break
+				}
+				after = expected[len(expected)-1].CompanyNumber
+			}
+		}
+	}
+	assert.ErrorContains(t, snapshot.FindByArea(ctx, "parish", "E04000001", "", 10, func(*models.CompanyDataWithLocation) {}), "unsupported area type")
+}
+
+func TestServingSnapshotUnsupportedQueries(t *testing.T) {
+	snapshot := exportTestSnapshot(t, newProximityTestDatabase(t))
+	ctx := context.Background()
+
+	err := snapshot.FindChanges(ctx, time.Time{}, nil, 0, 10, func(*models.CompanyChange) {})
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+	err = snapshot.FindTimeline(ctx, "00000001", func(*models.TimelineEvent) {})
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestServingSnapshotClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "companies_data.snap")
+	rows, err := ExportServingSnapshot(newProximityTestDatabase(t), path)
+	require.NoError(t, err)
+	assert.Equal(t, 5, rows)
+	// Only the snapshot is left behind.
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	snapshot, err := OpenServingSnapshot(path)
+	require.NoError(t, err)
+	assert.NoError(t, snapshot.Ping())
+	var numbers []string
+	require.NoError(t, snapshot.FindNearest(context.Background(), 601000, 142000, 3, 10000, collectNumbers(&numbers)))
+	assert.Equal(t, []string{"00000001", "00000002", "00000003"}, numbers)
+
+	require.NoError(t, snapshot.Close())
+	assert.ErrorIs(t, snapshot.Ping(), ErrDatabaseUnavailable)
+	err = snapshot.Find(context.Background(), []float64{600000, 140000, 610000, 150000}, 0, func(*models.CompanyDataWithLocation) {})
+	assert.ErrorIs(t, err, ErrDatabaseUnavailable)
+	assert.NoError(t, snapshot.Close())
+}
+
+func TestOpenServingSnapshotRejectsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.snap")
+	_, err := ExportServingSnapshot(newProximityTestDatabase(t), valid)
+	require.NoError(t, err)
+	data, err := os.ReadFile(valid)
+	require.NoError(t, err)
+
+	var header servingSnapshotHeader
+	headerAt := binary.LittleEndian.Uint64(data[8:])
+	require.NoError(t, json.Unmarshal(data[headerAt:headerAt+binary.LittleEndian.Uint64(data[16:])], &header))
+	// corrupt overwrites the uint32 at index i of a section with value.
+	corrupt := func(section *servingSnapshotSection, i int64, value uint32) []byte {
+		require.NotNil(t, section)
+		require.Less(t, i*4, section.Length)
+		corrupted := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint32(corrupted[section.Offset+i*4:], value)
+		return corrupted
+	}
+	companyName := header.Columns["company_name"]
+	countyIndex := header.AreaIndexes["county"]
+
+	truncated := append([]byte(nil), data[:len(data)/2]...)
+	headerPastEnd := append([]byte(nil), data...)
+	headerPastEnd[8] = 0xff
+	headerPastEnd[15] = 0x7f
+	for name, contents := range map[string][]byte{
+		"empty":                   {},
+		"sqlite database":         []byte("SQLite format 3\x00" + string(make([]byte, 100))),
+		"truncated":               truncated,
+		"header past end":         headerPastEnd,
+		"dictionary id past end":  corrupt(&companyName.Values, 0, 1<<20),
+		"string offset past end":  corrupt(companyName.Offsets, 1, 1<<30),
+		"area index row past end": corrupt(&countyIndex, 0, uint32(header.Rows)),
+	} {
+		path := filepath.Join(dir, name+".snap")
+		require.NoError(t, os.WriteFile(path, contents, 0o644))
+		_, err := OpenServingSnapshot(path)
+		assert.Error(t, err, name)
+	}
+
+	_, err = OpenServingSnapshot(filepath.Join(dir, "missing.snap"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestExportServingSnapshotNeedsCompanyLocations(t *testing.T) {
+	db := connectTestDB(t)
+	for _, statement := range []string{
+		"DROP INDEX idx_company_data_location",
+		"ALTER TABLE company_data DROP COLUMN morton_key",
+	} {
+		_, err := db.Exec(statement)
+		require.NoError(t, err)
+	}
+
+	_, err := ExportServingSnapshot(db, filepath.Join(t.TempDir(), "companies_data.snap"))
+	assert.ErrorContains(t, err, "no company locations")
+}
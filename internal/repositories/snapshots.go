@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/map-services/company-data-api/internal"
+)
+
+// ErrNoSnapshot is returned when there is no snapshot on or before the
+// requested date.
+var ErrNoSnapshot = errors.New("no snapshot found")
+
+// SnapshotProvider is implemented by repositories that can also answer
+// searches as of an earlier date.
+type SnapshotProvider interface {
+	// AsOf returns the repository for the latest snapshot taken on or before
+	// the given date, and the date of that snapshot.
+	AsOf(date time.Time) (SearchRepository, time.Time, error)
+}
+
+// SnapshotOpener opens the snapshot database at path and the repository that
+// serves it.
+type SnapshotOpener func(path string) (*sql.DB, SearchRepository, error)
+
+// Snapshots serves the dated snapshots in a directory. Each snapshot is opened
+// the first time it is queried and kept open; snapshots taken since the
+// server started are picked up as they appear.
+type Snapshots struct {
+	dir  string
+	open SnapshotOpener
+
+	mu     sync.Mutex
+	opened map[string]*openSnapshot
+}
+
+// openSnapshot is reloaded when the snapshot for its date is retaken, so
+// that, as for the live database, queries in flight against the replaced
+// snapshot finish before it is closed.
+type openSnapshot struct {
+	modTime time.Time
+	repo    *ReloadableRepository
+}
+
+func NewSnapshots(dir string, open SnapshotOpener) *Snapshots {
+	return &Snapshots{dir: dir, open: open, opened: make(map[string]*openSnapshot)}
+}
+
+func (s *Snapshots) AsOf(date time.Time) (SearchRepository, time.Time, error) {
+	snapshots, err := internal.ListSnapshots(s.dir)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	snapshot, ok := internal.SnapshotAsOf(snapshots, date)
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("%w on or before %s", ErrNoSnapshot, date.Format(time.DateOnly))
+	}
+
+	info, err := os.Stat(snapshot.Path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to stat snapshot: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if opened, ok := s.opened[snapshot.Path]; ok {
+		if opened.modTime.Equal(info.ModTime()) {
+			return opened.repo, snapshot.Date, nil
+		}
+		// The snapshot for this date has been retaken.
+		if err := opened.repo.Reload(); err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to reopen snapshot %s: %w", snapshot.Date.Format(time.DateOnly), err)
+		}
+		opened.modTime = info.ModTime()
+		slog.Info("Reopened snapshot", "path", snapshot.Path)
+		return opened.repo, snapshot.Date, nil
+	}
+
+	repo, err := NewReloadableRepository(func() (Database, SearchRepository, error) {
+		return s.open(snapshot.Path)
+	})
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to open snapshot %s: %w", snapshot.Date.Format(time.DateOnly), err)
+	}
+	s.opened[snapshot.Path] = &openSnapshot{modTime: info.ModTime(), repo: repo}
+	slog.Info("Opened snapshot", "path", snapshot.Path)
+	return repo, snapshot.Date, nil
+}
+
+func (s *Snapshots) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for path, opened := range s.opened {
+		errs = append(errs, opened.repo.Close())
+		delete(s.opened, path)
+	}
+	return errors.Join(errs...)
+}
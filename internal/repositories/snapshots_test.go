@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotsAsOf(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "companies_data.db")
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	db, err := internal.Connect(dbPath)
+	require.NoError(t, err)
+	insertCodePoint(t, db, "TN23 1AA", 601000, 142000)
+	insertCompany(t, db, "00000001", "MARCH LIMITED", "TN23 1AA")
+	locateCompanies(t, db)
+	_, err = internal.CreateSnapshot(dbPath, march)
+	require.NoError(t, err)
+	insertCompany(t, db, "00000002", "APRIL LIMITED", "TN23 1AA")
+	locateCompanies(t, db)
+	_, err = internal.CreateSnapshot(dbPath, april)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	opened := 0
+	snapshots := NewSnapshots(internal.SnapshotDir(dbPath), func(path string) (*sql.DB, SearchRepository, error) {
+		opened++
+		db, err := internal.ConnectReadOnly(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		repo, err := NewSqliteDbRepository(db)
+		return db, repo, err
+	})
+	defer func() {
+		assert.NoError(t, snapshots.Close())
+	}()
+
+	names := func(asOf time.Time) ([]string, time.Time) {
+		repo, snapshotDate, err := snapshots.AsOf(asOf)
+		require.NoError(t, err)
+		var names []string
+		require.NoError(t, repo.Find(context.Background(), []float64{600000, 141000, 602000, 143000}, 0, func(cd *models.CompanyDataWithLocation) {
+			names = append(names, cd.CompanyName)
+		}))
+		return names, snapshotDate
+	}
+
+	found, snapshotDate := names(time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, []string{"MARCH LIMITED"}, found)
+	assert.Equal(t, march, snapshotDate)
+
+	found, snapshotDate = names(time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC))
+	assert.ElementsMatch(t, []string{"MARCH LIMITED", "APRIL LIMITED"}, found)
+	assert.Equal(t, april, snapshotDate)
+
+	names(march)
+	assert.Equal(t, 2, opened, "snapshots should be opened once and kept open")
+
+	_, _, err = snapshots.AsOf(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC))
+	assert.True(t, errors.Is(err, ErrNoSnapshot))
+	assert.EqualError(t, err, "no snapshot found on or before 2025-02-01")
+}
+
+func TestReloadableRepositoryWithoutSnapshots(t *testing.T) {
+	repo, err := NewReloadableRepository(func() (Database, SearchRepository, error) {
+		return nil, &stubRepository{}, nil
+	})
+	require.NoError(t, err)
+
+	_, _, err = repo.AsOf(time.Now())
+	assert.True(t, errors.Is(err, ErrNoSnapshot))
+}
+
+func TestSnapshotsAsOfRetakenSnapshot(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "companies_data.db")
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	db, err := internal.Connect(dbPath)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, db.Close())
+	}()
+	insertCodePoint(t, db, "TN23 1AA", 601000, 142000)
+	insertCompany(t, db, "00000001", "MARCH LIMITED", "TN23 1AA")
+	locateCompanies(t, db)
+	_, err = internal.CreateSnapshot(dbPath, march)
+	require.NoError(t, err)
+
+	snapshots := NewSnapshots(internal.SnapshotDir(dbPath), func(path string) (*sql.DB, SearchRepository, error) {
+		db, err := internal.ConnectReadOnly(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		repo, err := NewSqliteDbRepository(db)
+		return db, repo, err
+	})
+	defer func() {
+		assert.NoError(t, snapshots.Close())
+	}()
+
+	names := func(repo SearchRepository) []string {
+		var names []string
+		require.NoError(t, repo.Find(context.Background(), []float64{600000, 141000, 602000, 143000}, 0, func(cd *models.CompanyDataWithLocation) {
+			names = append(names, cd.CompanyName)
+		}))
+		return names
+	}
+
+	// A request that looked up the snapshot just before it was retaken.
+	inFlight, _, err := snapshots.AsOf(march)
+	require.NoError(t, err)
+
+	insertCompany(t, db, "00000002", "LATE MARCH LIMITED", "TN23 1AA")
+	locateCompanies(t, db)
+	snapshotPath, err := internal.CreateSnapshot(dbPath, march)
+	require.NoError(t, err)
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(snapshotPath, later, later))
+
+	retaken, _, err := snapshots.AsOf(march)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"MARCH LIMITED", "LATE MARCH LIMITED"}, names(retaken))
+	assert.ElementsMatch(t, []string{"MARCH LIMITED", "LATE MARCH LIMITED"}, names(inFlight),
+		"a repository looked up before the snapshot was retaken should serve the retaken snapshot")
+}
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"net/http"
+
+	repo "github.com/map-services/company-data-api/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnmatchedReport godoc
+// @Summary Report companies whose registered postcode could not be located
+// @Description Counts the companies located at their registered postcode, or at the centroid of its sector or district when the postcode is not in CodePoint Open, and those that could not be located at all, by reason: missing, invalid, outside_coverage (Northern Ireland, the Channel Islands and the Isle of Man, which CodePoint Open does not cover) or unknown (terminated postcodes and well-formed typos). The unmatched postcodes shared by the most companies are listed. Unmatched companies are not returned by bounding box searches.
+// @Tags admin
+// @Produce json
+// @Param X-API-Key header string true "API key with admin access"
+// @Success 200 {object} models.LocationReport
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 504 {object} map[string]string
+// @Router /admin/unmatched [get]
+func UnmatchedReport(repo repo.SearchRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		report, err := repo.LocationReport(c.Request.Context())
+		if err != nil {
+			queryFailed(c, err, "error while building location report")
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubReportRepository struct {
+	stubAreaRepository
+	report *models.LocationReport
+}
+
+func (s *stubReportRepository) LocationReport(ctx context.Context) (*models.LocationReport, error) {
+	return s.report, s.err
+}
+
+func unmatchedReport(t *testing.T, repo *stubReportRepository) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/admin/unmatched", UnmatchedReport(repo))
+
+	req, err := http.NewRequest("GET", "/admin/unmatched", nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestUnmatchedReport(t *testing.T) {
+	expected := models.LocationReport{
+		Located:        map[string]int{"postcode": 10, "sector": 2},
+		Unmatched:      map[string]int{"outside_coverage": 3},
+		TotalUnmatched: 3,
+		TopPostcodes:   []models.UnmatchedPostcode{{PostCode: "BT1 1AA", Reason: "outside_coverage", Companies: 3}},
+	}
+
+	w := unmatchedReport(t, &stubReportRepository{report: &expected})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report models.LocationReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report), w.Body.String())
+	assert.Equal(t, expected, report)
+}
+
+func TestUnmatchedReportError(t *testing.T) {
+	w := unmatchedReport(t, &stubReportRepository{stubAreaRepository: stubAreaRepository{err: errors.New("boom")}})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,169 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/models"
+	repo "github.com/map-services/company-data-api/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AreaSearchResponse struct {
+	Results      []models.CompanyDataWithLocation `json:"results"`
+	NextCursor   string                           `json:"next_cursor,omitempty"`
+	Attribution  []string                         `json:"attribution"`
+	LastUpdated  *time.Time                       `json:"last_updated,omitempty"`
+	SnapshotDate string                           `json:"snapshot_date,omitempty"`
+}
+
+// areaSearchTrailer holds the fields of AreaSearchResponse that follow the
+// streamed results.
+type areaSearchTrailer struct {
+	NextCursor   string     `json:"next_cursor,omitempty"`
+	Attribution  []string   `json:"attribution"`
+	LastUpdated  *time.Time `json:"last_updated,omitempty"`
+	SnapshotDate string     `json:"snapshot_date,omitempty"`
+}
+
+const (
+	DEFAULT_AREA_PAGE_SIZE = 1000
+	MAX_AREA_PAGE_SIZE     = 5000
+)
+
+// SearchByArea godoc
+// @Summary Search companies within an administrative area
+// @Description Returns companies whose registered postcode lies within the given region, county, district or ward. Results are ordered by company number and paginated: pass the returned next_cursor as the cursor parameter to fetch the following page.
+// @Tags search
+// @Param type query string true "Area type" Enums(region, county, district, ward)
+// @Param code query string true "Area code, e.g. E07000041"
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param limit query int false "Page size (default 1000, maximum 5000)"
+// @Param as_of query string false "Search the latest snapshot taken on or before this date (YYYY-MM-DD)"
+// @Produce json
+// @Success 200 {object} AreaSearchResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 504 {object} map[string]string
+// @Router /search/by-area [get]
+func SearchByArea(repository repo.SearchRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		areaType, code, limit, err := parseAreaQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		repo, snapshotDate, ok := repositoryAsOf(c, repository)
+		if !ok {
+			return
+		}
+
+		// Results are streamed as they are read rather than buffered, so the
+		// status and opening of the response are only written once the first
+		// row arrives (or the query completes); until then, errors can still
+		// be reported as a 500.
+		started := false
+		start := func() {
+			if !started {
+				started = true
+				c.Header("Content-Type", "application/json; charset=utf-8")
+				c.Status(http.StatusOK)
+				_, _ = c.Writer.WriteString(`{"results":[`)
+			}
+		}
+
+		written := 0
+		nextCursor := ""
+		lastCompanyNumber := ""
+
+		// One more row than requested is fetched to find out whether there is a further page.
+		err = repo.FindByArea(c.Request.Context(), areaType, code, c.Query("cursor"), limit+1, func(companyData *models.CompanyDataWithLocation) {
+			if written == limit {
+				nextCursor = lastCompanyNumber
+				return
+			}
+
+			data, err := json.Marshal(companyData)
+			if err != nil {
+				slog.Error("failed to serialize company data", "companyNumber", companyData.CompanyNumber, "error", err)
+				return
+			}
+
+			start()
+			if written > 0 {
+				_, _ = c.Writer.WriteString(",")
+			}
+			_, _ = c.Writer.Write(data)
+			written++
+			lastCompanyNumber = companyData.CompanyNumber
+
+			if written%500 == 0 {
+				c.Writer.Flush()
+			}
+		})
+
+		if err != nil {
+			if !started {
+				queryFailed(c, err, "error while fetching company data")
+				return
+			}
+			// Too late to change the status; leave the response as invalid
+			// JSON so that the client can't mistake it for a complete page.
+			slog.Error("error while streaming company data", "error", err)
+			c.Abort()
+			return
+		}
+
+		start()
+		trailer, err := json.Marshal(areaSearchTrailer{
+			NextCursor:   nextCursor,
+			Attribution:  internal.ATTRIBUTION,
+			LastUpdated:  repo.LastUpdated(),
+			SnapshotDate: snapshotDate,
+		})
+		if err != nil {
+			slog.Error("failed to serialize response trailer", "error", err)
+			c.Abort()
+			return
+		}
+		_, _ = c.Writer.WriteString("],")
+		_, _ = c.Writer.Write(trailer[1:])
+	}
+}
+
+func parseAreaQuery(c *gin.Context) (string, string, int, error) {
+	areaType := c.Query("type")
+	if _, ok := repo.AreaColumns[areaType]; !ok {
+		areaTypes := make([]string, 0, len(repo.AreaColumns))
+		for t := range repo.AreaColumns {
+			areaTypes = append(areaTypes, t)
+		}
+		slices.Sort(areaTypes)
+		return "", "", 0, fmt.Errorf("type must be one of: %s", strings.Join(areaTypes, ", "))
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(c.Query("code")))
+	if code == "" {
+		return "", "", 0, fmt.Errorf("code is required")
+	}
+
+	limit := DEFAULT_AREA_PAGE_SIZE
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > MAX_AREA_PAGE_SIZE {
+			return "", "", 0, fmt.Errorf("limit must be between 1 and %d", MAX_AREA_PAGE_SIZE)
+		}
+	}
+
+	return areaType, code, limit, nil
+}
@@ -0,0 +1,141 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubAreaRepository struct {
+	numbers []string
+	err     error
+}
+
+func (s *stubAreaRepository) Find(ctx context.Context, bbox []float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	for i, number := range s.numbers {
+		if i == limit && limit > 0 {
+			break
+		}
+		rowProcessor(&models.CompanyDataWithLocation{CompanyData: models.CompanyData{CompanyNumber: number, RegAddressPostCode: "TN23 " + number}})
+	}
+	return s.err
+}
+
+func (s *stubAreaRepository) FindByArea(ctx context.Context, areaType string, code string, after string, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	count := 0
+	for _, number := range s.numbers {
+		if number <= after || count == limit {
+			continue
+		}
+		rowProcessor(&models.CompanyDataWithLocation{CompanyData: models.CompanyData{CompanyNumber: number}, DistrictCode: code})
+		count++
+	}
+	return s.err
+}
+
+func (s *stubAreaRepository) FindWithinRadius(ctx context.Context, easting float64, northing float64, radius float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	return s.Find(ctx, nil, limit, rowProcessor)
+}
+
+func (s *stubAreaRepository) FindNearest(ctx context.Context, easting float64, northing float64, k int, maxDistance float64, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	return s.Find(ctx, nil, k, rowProcessor)
+}
+
+func (s *stubAreaRepository) FindChanges(ctx context.Context, since time.Time, bbox []float64, after int64, limit int, rowProcessor func(change *models.CompanyChange)) error {
+	return errors.New("not implemented")
+}
+
+func (s *stubAreaRepository) FindTimeline(ctx context.Context, companyNumber string, rowProcessor func(event *models.TimelineEvent)) error {
+	return errors.New("not implemented")
+}
+
+func (s *stubAreaRepository) LocationReport(ctx context.Context) (*models.LocationReport, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *stubAreaRepository) LastUpdated() *time.Time {
+	return nil
+}
+
+func searchByArea(t *testing.T, repo *stubAreaRepository, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/search/by-area", SearchByArea(repo))
+
+	req, err := http.NewRequest("GET", "/search/by-area?"+query, nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSearchByAreaPaginates(t *testing.T) {
+	repo := &stubAreaRepository{numbers: []string{"01", "02", "03"}}
+
+	w := searchByArea(t, repo, "type=district&code=e07000041&limit=2")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var page AreaSearchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page), w.Body.String())
+	require.Len(t, page.Results, 2)
+	assert.Equal(t, "01", page.Results[0].CompanyNumber)
+	assert.Equal(t, "E07000041", page.Results[0].DistrictCode)
+	assert.Equal(t, "02", page.NextCursor)
+	assert.NotEmpty(t, page.Attribution)
+
+	w = searchByArea(t, repo, fmt.Sprintf("type=district&code=E07000041&limit=2&cursor=%s", page.NextCursor))
+	require.Equal(t, http.StatusOK, w.Code)
+	page = AreaSearchResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page), w.Body.String())
+	require.Len(t, page.Results, 1)
+	assert.Equal(t, "03", page.Results[0].CompanyNumber)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestSearchByAreaEmpty(t *testing.T) {
+	w := searchByArea(t, &stubAreaRepository{}, "type=ward&code=E05009546")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var page AreaSearchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page), w.Body.String())
+	assert.Empty(t, page.Results)
+}
+
+func TestSearchByAreaValidation(t *testing.T) {
+	cases := map[string]string{
+		"unknown type":  "type=parish&code=E04000001",
+		"missing code":  "type=district",
+		"invalid limit": "type=district&code=E07000041&limit=0",
+		"limit too big": "type=district&code=E07000041&limit=5001",
+	}
+	for name, query := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := searchByArea(t, &stubAreaRepository{}, query)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestSearchByAreaErrorBeforeResults(t *testing.T) {
+	w := searchByArea(t, &stubAreaRepository{err: errors.New("boom")}, "type=district&code=E07000041")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestSearchByAreaErrorMidStream(t *testing.T) {
+	w := searchByArea(t, &stubAreaRepository{numbers: []string{"01"}, err: errors.New("boom")}, "type=district&code=E07000041")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var page AreaSearchResponse
+	assert.Error(t, json.Unmarshal(w.Body.Bytes(), &page), "truncated response must not parse as a complete page")
+}
@@ -0,0 +1,118 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/models"
+	repo "github.com/map-services/company-data-api/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChangesResponse struct {
+	Changes     []models.CompanyChange `json:"changes"`
+	NextCursor  string                 `json:"next_cursor,omitempty"`
+	Attribution []string               `json:"attribution"`
+}
+
+const (
+	DEFAULT_CHANGES_PAGE_SIZE = 1000
+	MAX_CHANGES_PAGE_SIZE     = 5000
+)
+
+// Changes godoc
+// @Summary List changes between Companies House imports
+// @Description Returns the changes detected when each Companies House snapshot was imported: new companies (incorporated), dissolved, status_changed, renamed, address_changed and removed. Changes are ordered by ID and paginated: pass the returned next_cursor as the cursor parameter to fetch the following page. To sync incrementally, keep the cursor of the last page and pass it on the next sync.
+// @Tags changes
+// @Param since query string false "Only changes detected at or after this time, as RFC 3339 or YYYY-MM-DD"
+// @Param bbox query string false "Bounding box as comma-separated values: minEasting,minNorthing,maxEasting,maxNorthing. Changes match on the company's location, and address changes on either its old or new location."
+// @Param cursor query string false "Cursor returned by the previous page"
+// @Param limit query int false "Page size (default 1000, maximum 5000)"
+// @Produce json
+// @Success 200 {object} ChangesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 501 {object} map[string]string
+// @Failure 504 {object} map[string]string
+// @Router /changes [get]
+func Changes(repo repo.SearchRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		query, err := parseChangesQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// One more row than requested is fetched to find out whether there is a further page.
+		changes := make([]models.CompanyChange, 0, min(query.limit, 100))
+		nextCursor := ""
+		err = repo.FindChanges(c.Request.Context(), query.since, query.bbox, query.after, query.limit+1, func(change *models.CompanyChange) {
+			if len(changes) == query.limit {
+				nextCursor = strconv.FormatInt(changes[len(changes)-1].ID, 10)
+				return
+			}
+			changes = append(changes, *change)
+		})
+
+		if err != nil {
+			queryFailed(c, err, "error while fetching company changes")
+			return
+		}
+
+		c.JSON(http.StatusOK, ChangesResponse{
+			Changes:     changes,
+			NextCursor:  nextCursor,
+			Attribution: internal.ATTRIBUTION,
+		})
+	}
+}
+
+type changesQuery struct {
+	since time.Time
+	bbox  []float64
+	after int64
+	limit int
+}
+
+func parseChangesQuery(c *gin.Context) (changesQuery, error) {
+	query := changesQuery{limit: DEFAULT_CHANGES_PAGE_SIZE}
+
+	if sinceStr := strings.TrimSpace(c.Query("since")); sinceStr != "" {
+		var err error
+		if query.since, err = time.Parse(time.RFC3339, sinceStr); err != nil {
+			if query.since, err = time.Parse(time.DateOnly, sinceStr); err != nil {
+				return query, fmt.Errorf("since must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+			}
+		}
+	}
+
+	if bboxStr := c.Query("bbox"); bboxStr != "" {
+		var err error
+		if query.bbox, err = parseBBoxValues(bboxStr); err != nil {
+			return query, err
+		}
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		var err error
+		query.after, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil || query.after < 0 {
+			return query, fmt.Errorf("invalid cursor")
+		}
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		query.limit, err = strconv.Atoi(limitStr)
+		if err != nil || query.limit < 1 || query.limit > MAX_CHANGES_PAGE_SIZE {
+			return query, fmt.Errorf("limit must be between 1 and %d", MAX_CHANGES_PAGE_SIZE)
+		}
+	}
+
+	return query, nil
+}
@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubChangesRepository struct {
+	stubAreaRepository
+	changes []models.CompanyChange
+	since   time.Time
+	bbox    []float64
+}
+
+func (s *stubChangesRepository) FindChanges(ctx context.Context, since time.Time, bbox []float64, after int64, limit int, rowProcessor func(change *models.CompanyChange)) error {
+	s.since, s.bbox = since, bbox
+	count := 0
+	for i := range s.changes {
+		if s.changes[i].ID <= after || count == limit {
+			continue
+		}
+		rowProcessor(&s.changes[i])
+		count++
+	}
+	return s.err
+}
+
+func changes(t *testing.T, repo *stubChangesRepository, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/changes", Changes(repo))
+
+	req, err := http.NewRequest("GET", "/changes?"+query, nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestChangesPaginates(t *testing.T) {
+	repo := &stubChangesRepository{changes: []models.CompanyChange{
+		{ID: 1, CompanyNumber: "01", ChangeType: models.ChangeIncorporated},
+		{ID: 2, CompanyNumber: "02", ChangeType: models.ChangeRenamed},
+		{ID: 3, CompanyNumber: "03", ChangeType: models.ChangeRemoved},
+	}}
+
+	w := changes(t, repo, "since=2025-09-01&bbox=600000,141000,700000,243000&limit=2")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var page ChangesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page), w.Body.String())
+	require.Len(t, page.Changes, 2)
+	assert.Equal(t, "01", page.Changes[0].CompanyNumber)
+	assert.Equal(t, "2", page.NextCursor)
+	assert.NotEmpty(t, page.Attribution)
+	assert.Equal(t, time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), repo.since)
+	assert.Equal(t, []float64{600000, 141000, 700000, 243000}, repo.bbox, "the bbox is not limited in size")
+
+	w = changes(t, repo, "since=2025-09-01T12:00:00%2B01:00&cursor="+page.NextCursor)
+	require.Equal(t, http.StatusOK, w.Code)
+	page = ChangesResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page), w.Body.String())
+	require.Len(t, page.Changes, 1)
+	assert.Equal(t, "03", page.Changes[0].CompanyNumber)
+	assert.Empty(t, page.NextCursor)
+	assert.True(t, time.Date(2025, 9, 1, 11, 0, 0, 0, time.UTC).Equal(repo.since))
+	assert.Nil(t, repo.bbox)
+}
+
+func TestChangesEmpty(t *testing.T) {
+	w := changes(t, &stubChangesRepository{}, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"changes":[]`)
+}
+
+func TestChangesValidation(t *testing.T) {
+	cases := map[string]string{
+		"invalid since":  "since=last-month",
+		"invalid bbox":   "bbox=1,2,3",
+		"invalid cursor": "cursor=abc",
+		"invalid limit":  "limit=0",
+		"limit too big":  "limit=5001",
+	}
+	for name, query := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := changes(t, &stubChangesRepository{}, query)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestChangesError(t *testing.T) {
+	w := changes(t, &stubChangesRepository{stubAreaRepository: stubAreaRepository{err: errors.New("boom")}}, "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusClientClosedRequest is the status, borrowed from nginx, recorded for
+// requests whose client disconnected before the response was ready.
+const statusClientClosedRequest = 499
+
+// queryFailed logs a failed repository query and writes the response: a 504
+// if the query ran past its timeout, nothing if the client has gone away, or
+// a 501 if the repository being served cannot answer that kind of query, or
+// a 500 otherwise.
+func queryFailed(c *gin.Context, err error, msg string, args ...any) {
+	args = append(args, "error", err)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.Warn(msg, args...)
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "The query took too long to complete"})
+	case errors.Is(err, context.Canceled):
+		slog.Debug(msg, args...)
+		c.AbortWithStatus(statusClientClosedRequest)
+	case errors.Is(err, errors.ErrUnsupported):
+		slog.Debug(msg, args...)
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "This query is not supported by the data being served"})
+	default:
+		slog.Error(msg, args...)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryFailed(t *testing.T) {
+	cases := map[error]int{
+		fmt.Errorf("error querying database: %w", context.DeadlineExceeded): http.StatusGatewayTimeout,
+		context.Canceled: statusClientClosedRequest,
+		fmt.Errorf("timelines are not included: %w", errors.ErrUnsupported): http.StatusNotImplemented,
+		errors.New("disk I/O"): http.StatusInternalServerError,
+	}
+	for err, status := range cases {
+		w := timeline(t, &stubTimelineRepository{stubAreaRepository: stubAreaRepository{err: err}}, "00012345")
+		assert.Equal(t, status, w.Code, err.Error())
+	}
+}
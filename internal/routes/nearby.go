@@ -0,0 +1,175 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/models"
+	repo "github.com/map-services/company-data-api/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	MAX_RADIUS           = MAX_BOUNDS / 2 // Searches around a point cover no more than a bounding box may
+	DEFAULT_NEAREST      = 10
+	MAX_NEAREST          = 100
+	DEFAULT_MAX_DISTANCE = MAX_RADIUS
+)
+
+// SearchRadius godoc
+// @Summary Search companies within a radius of a point
+// @Description Returns companies within the given distance of a point, nearest first, with ties ordered by company number. Results beyond the maximum a search may return are truncated, as for /search.
+// @Tags search
+// @Param point query string true "Point as comma-separated values: easting,northing"
+// @Param radius query number true "Radius in metres (maximum 2500)"
+// @Param as_of query string false "Search the latest snapshot taken on or before this date (YYYY-MM-DD)"
+// @Param X-API-Key header string false "API key, for a different maximum number of results"
+// @Produce json
+// @Success 200 {object} SearchResponse
+// @Header 200 {string} X-Results-Truncated "true if the results were truncated"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 504 {object} map[string]string
+// @Router /search/radius [get]
+func SearchRadius(repository repo.SearchRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		easting, northing, err := parsePoint(c.Query("point"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		radius, err := parseDistance(c, "radius", 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		searchNear(c, repository, 0, func(ctx context.Context, repo repo.SearchRepository, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+			return repo.FindWithinRadius(ctx, easting, northing, radius, limit, rowProcessor)
+		})
+	}
+}
+
+// SearchNearest godoc
+// @Summary Search the companies nearest to a point
+// @Description Returns the companies registered nearest to a point, nearest first, with ties ordered by company number. Companies further away than max_distance are not returned, so fewer than k may be.
+// @Tags search
+// @Param point query string true "Point as comma-separated values: easting,northing"
+// @Param k query int false "Number of companies (default 10, maximum 100)"
+// @Param max_distance query number false "Maximum distance in metres (default and maximum 2500)"
+// @Param as_of query string false "Search the latest snapshot taken on or before this date (YYYY-MM-DD)"
+// @Param X-API-Key header string false "API key, for a different maximum number of results"
+// @Produce json
+// @Success 200 {object} SearchResponse
+// @Header 200 {string} X-Results-Truncated "true if the results were truncated"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 504 {object} map[string]string
+// @Router /search/nearest [get]
+func SearchNearest(repository repo.SearchRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		easting, northing, err := parsePoint(c.Query("point"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		k := DEFAULT_NEAREST
+		if kStr := c.Query("k"); kStr != "" {
+			k, err = strconv.Atoi(kStr)
+			if err != nil || k < 1 || k > MAX_NEAREST {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("k must be between 1 and %d", MAX_NEAREST)})
+				return
+			}
+		}
+		maxDistance, err := parseDistance(c, "max_distance", DEFAULT_MAX_DISTANCE)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		searchNear(c, repository, k, func(ctx context.Context, repo repo.SearchRepository, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+			return repo.FindNearest(ctx, easting, northing, limit, maxDistance, rowProcessor)
+		})
+	}
+}
+
+// searchNear runs a search around a point for up to want companies (as many
+// as there are if zero), capped at the maximum the request may return, and
+// writes the response.
+func searchNear(
+	c *gin.Context,
+	repository repo.SearchRepository,
+	want int,
+	find func(ctx context.Context, repo repo.SearchRepository, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error,
+) {
+	repo, snapshotDate, ok := repositoryAsOf(c, repository)
+	if !ok {
+		return
+	}
+
+	capped := newResultCap(c)
+	limit := capped.limit()
+	if want > 0 && (limit == 0 || want < limit) {
+		limit = want
+	}
+	results := make([]models.CompanyDataWithLocation, 0, capped.capacity(max(want, 100)))
+	err := find(c.Request.Context(), repo, limit, func(companyData *models.CompanyDataWithLocation) {
+		if capped.accept() {
+			results = append(results, *companyData)
+		}
+	})
+
+	if err != nil {
+		queryFailed(c, err, "error while fetching company data")
+		return
+	}
+
+	capped.writeTruncated(c)
+	c.JSON(http.StatusOK, SearchResponse{
+		Results:      results,
+		Truncated:    capped.truncated,
+		Attribution:  internal.ATTRIBUTION,
+		LastUpdated:  repo.LastUpdated(),
+		SnapshotDate: snapshotDate,
+	})
+}
+
+func parsePoint(pointStr string) (float64, float64, error) {
+	parts := strings.Split(pointStr, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("point must have 2 comma-separated values: easting,northing")
+	}
+
+	var point [2]float64
+	for i, part := range parts {
+		val, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid point value '%s': not a valid float", part)
+		}
+		point[i] = val
+	}
+	return point[0], point[1], nil
+}
+
+// parseDistance parses a distance in metres from the query, which is
+// required unless there is a default.
+func parseDistance(c *gin.Context, name string, defaultDistance float64) (float64, error) {
+	distanceStr := c.Query(name)
+	if distanceStr == "" && defaultDistance > 0 {
+		return defaultDistance, nil
+	}
+	distance, err := strconv.ParseFloat(distanceStr, 64)
+	if err != nil || !(distance >= 0 && distance <= MAX_RADIUS) { // rejecting NaN
+		return 0, fmt.Errorf("%s must be a distance in metres between 0 and %d", name, MAX_RADIUS)
+	}
+	return distance, nil
+}
@@ -0,0 +1,137 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/map-services/company-data-api/internal/middleware"
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubNearbyRepository records the searches around a point made of it.
+type stubNearbyRepository struct {
+	stubAreaRepository
+	point       [2]float64
+	distance    float64
+	limit       int
+	searchCount int
+}
+
+func (s *stubNearbyRepository) FindWithinRadius(ctx context.Context, easting float64, northing float64, radius float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	s.point, s.distance, s.limit = [2]float64{easting, northing}, radius, limit
+	s.searchCount++
+	return s.Find(ctx, nil, limit, rowProcessor)
+}
+
+func (s *stubNearbyRepository) FindNearest(ctx context.Context, easting float64, northing float64, k int, maxDistance float64, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	s.point, s.distance, s.limit = [2]float64{easting, northing}, maxDistance, k
+	s.searchCount++
+	return s.Find(ctx, nil, k, rowProcessor)
+}
+
+func TestSearchRadius(t *testing.T) {
+	repository := &stubNearbyRepository{stubAreaRepository: stubAreaRepository{numbers: []string{"01", "02"}}}
+
+	w := search(t, repository, "/search/radius?point=601000.5,142000&radius=750")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response SearchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, "01", response.Results[0].CompanyNumber)
+	assert.False(t, response.Truncated)
+	assert.Equal(t, [2]float64{601000.5, 142000}, repository.point)
+	assert.Equal(t, 750.0, repository.distance)
+	assert.Equal(t, 0, repository.limit)
+}
+
+func TestSearchNearest(t *testing.T) {
+	repository := &stubNearbyRepository{stubAreaRepository: stubAreaRepository{numbers: []string{"01", "02"}}}
+
+	w := search(t, repository, "/search/nearest?point=601000,142000")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response SearchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Results, 2)
+	assert.Equal(t, DEFAULT_NEAREST, repository.limit)
+	assert.Equal(t, float64(DEFAULT_MAX_DISTANCE), repository.distance)
+
+	w = search(t, repository, "/search/nearest?point=601000,142000&k=1&max_distance=100")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	response = SearchResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Results, 1)
+	assert.False(t, response.Truncated, "k is not a truncation")
+	assert.Equal(t, 1, repository.limit)
+	assert.Equal(t, 100.0, repository.distance)
+}
+
+func TestSearchNearbyInvalidQuery(t *testing.T) {
+	cases := map[string]string{
+		"missing point":        "/search/radius?radius=100",
+		"short point":          "/search/radius?point=601000&radius=100",
+		"invalid point":        "/search/nearest?point=601000,north",
+		"missing radius":       "/search/radius?point=601000,142000",
+		"radius too large":     "/search/radius?point=601000,142000&radius=2501",
+		"negative radius":      "/search/radius?point=601000,142000&radius=-1",
+		"radius not a number":  "/search/radius?point=601000,142000&radius=NaN",
+		"k too small":          "/search/nearest?point=601000,142000&k=0",
+		"k too large":          "/search/nearest?point=601000,142000&k=101",
+		"max distance too far": "/search/nearest?point=601000,142000&max_distance=10000",
+	}
+	for name, path := range cases {
+		t.Run(name, func(t *testing.T) {
+			repository := &stubNearbyRepository{}
+			w := search(t, repository, path)
+			assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			assert.Zero(t, repository.searchCount)
+		})
+	}
+}
+
+func TestSearchNearbyTruncates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository := &stubNearbyRepository{stubAreaRepository: stubAreaRepository{numbers: []string{"01", "02", "03"}}}
+	r := gin.New()
+	r.Use(middleware.ResultLimit(2, nil))
+	r.GET("/search/radius", SearchRadius(repository))
+	r.GET("/search/nearest", SearchNearest(repository))
+
+	for _, path := range []string{
+		"/search/radius?point=601000,142000&radius=1000",
+		"/search/nearest?point=601000,142000&k=3",
+	} {
+		req, err := http.NewRequest("GET", path, nil)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var response SearchResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Results, 2, path)
+		assert.True(t, response.Truncated, path)
+		assert.Equal(t, "true", w.Header().Get(TruncatedHeader), path)
+		assert.Equal(t, 3, repository.limit, path)
+	}
+}
+
+func TestSearchNearbyAsOfAndErrors(t *testing.T) {
+	repository := newStubSnapshotRepository()
+	w := search(t, repository, "/search/nearest?point=601000,142000&as_of=2025-03-20")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response SearchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2025-03-01", response.SnapshotDate)
+
+	w = search(t, &stubNearbyRepository{stubAreaRepository: stubAreaRepository{err: errors.New("boom")}}, "/search/radius?point=601000,142000&radius=100")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	w = search(t, &stubNearbyRepository{stubAreaRepository: stubAreaRepository{err: context.DeadlineExceeded}}, "/search/nearest?point=601000,142000")
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
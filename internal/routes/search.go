@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"math"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/middleware"
 	"github.com/map-services/company-data-api/internal/models"
 	repo "github.com/map-services/company-data-api/internal/repositories"
 
@@ -17,78 +19,162 @@ import (
 )
 
 type SearchResponse struct {
-	Results     []models.CompanyDataWithLocation `json:"results"`
-	Attribution []string                         `json:"attribution"`
-	LastUpdated *time.Time                       `json:"last_updated,omitempty"`
+	Results      []models.CompanyDataWithLocation `json:"results"`
+	Truncated    bool                             `json:"truncated"`
+	Attribution  []string                         `json:"attribution"`
+	LastUpdated  *time.Time                       `json:"last_updated,omitempty"`
+	SnapshotDate string                           `json:"snapshot_date,omitempty"`
 }
 
 type GroupedSearchResponse struct {
-	Results     map[string][]models.CompanyDataWithLocation `json:"results"`
-	Attribution []string                                    `json:"attribution"`
-	LastUpdated *time.Time                                  `json:"last_updated,omitempty"`
+	Results      map[string][]models.CompanyDataWithLocation `json:"results"`
+	Truncated    bool                                        `json:"truncated"`
+	Attribution  []string                                    `json:"attribution"`
+	LastUpdated  *time.Time                                  `json:"last_updated,omitempty"`
+	SnapshotDate string                                      `json:"snapshot_date,omitempty"`
 }
 
 const MAX_BOUNDS = 5000 // Maximum bounds in meters (5 KM)
 
+// TruncatedHeader is set on search responses that hold only some of the
+// companies in the bounding box.
+const TruncatedHeader = "X-Results-Truncated"
+
+// resultCap counts the companies found by a search against the maximum the
+// request may return.
+type resultCap struct {
+	max       int
+	count     int
+	truncated bool
+}
+
+func newResultCap(c *gin.Context) *resultCap {
+	return &resultCap{max: middleware.MaxResults(c)}
+}
+
+// limit is the number of companies to search for: one more than the maximum,
+// so that a box holding exactly the maximum isn't reported as truncated.
+func (rc *resultCap) limit() int {
+	if rc.max <= 0 {
+		return 0
+	}
+	return rc.max + 1
+}
+
+// accept reports whether there is room in the results for another company,
+// noting that the results are truncated if not.
+func (rc *resultCap) accept() bool {
+	if rc.max > 0 && rc.count == rc.max {
+		rc.truncated = true
+		return false
+	}
+	rc.count++
+	return true
+}
+
+// capacity is the number of results to preallocate space for.
+func (rc *resultCap) capacity(preferred int) int {
+	if rc.max > 0 {
+		return min(preferred, rc.max)
+	}
+	return preferred
+}
+
+// writeTruncated sets the truncated header if the results were truncated.
+func (rc *resultCap) writeTruncated(c *gin.Context) {
+	if rc.truncated {
+		c.Header(TruncatedHeader, "true")
+	}
+}
+
 // @BasePath /v1/company-data/
 
 // Search godoc
 // @Summary Search companies within bounding box
-// @Description Returns companies within the specified bounding box
+// @Description Returns companies within the specified bounding box. If there are more than the maximum a search may return, only that many are returned and the response is marked truncated (and the X-Results-Truncated header set): narrow the bounding box, or page through an area with /search/by-area, to see the rest.
 // @Tags search
 // @Param bbox query string true "Bounding box as comma-separated values: minLon,minLat,maxLon,maxLat"
+// @Param as_of query string false "Search the latest snapshot taken on or before this date (YYYY-MM-DD)"
+// @Param X-API-Key header string false "API key, for a different maximum number of results"
 // @Produce json
 // @Success 200 {object} SearchResponse
+// @Header 200 {string} X-Results-Truncated "true if the results were truncated"
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Failure 504 {object} map[string]string
 // @Router /search [get]
-func Search(repo repo.SearchRepository) func(c *gin.Context) {
+func Search(repository repo.SearchRepository) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		bbox, err := parseBBox(c.Query("bbox"))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		repo, snapshotDate, ok := repositoryAsOf(c, repository)
+		if !ok {
+			return
+		}
 
-		results := make([]models.CompanyDataWithLocation, 0, 1000)
-		err = repo.Find(bbox, func(companyData *models.CompanyDataWithLocation) {
-			results = append(results, *companyData)
+		capped := newResultCap(c)
+		results := make([]models.CompanyDataWithLocation, 0, capped.capacity(1000))
+		err = repo.Find(c.Request.Context(), bbox, capped.limit(), func(companyData *models.CompanyDataWithLocation) {
+			if capped.accept() {
+				results = append(results, *companyData)
+			}
 		})
 
 		if err != nil {
-			slog.Error("error while fetching company data", "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			queryFailed(c, err, "error while fetching company data")
 			return
 		}
 
+		capped.writeTruncated(c)
 		c.JSON(http.StatusOK, SearchResponse{
-			Results:     results,
-			Attribution: internal.ATTRIBUTION,
-			LastUpdated: repo.LastUpdated(),
+			Results:      results,
+			Truncated:    capped.truncated,
+			Attribution:  internal.ATTRIBUTION,
+			LastUpdated:  repo.LastUpdated(),
+			SnapshotDate: snapshotDate,
 		})
 	}
 }
 
 // GroupByPostcode godoc
 // @Summary Group companies by postcode within bounding box
-// @Description Returns companies grouped by postcode within the specified bounding box
+// @Description Returns companies grouped by postcode within the specified bounding box. Results beyond the maximum a search may return are truncated, as for /search.
 // @Tags search
 // @Param bbox query string true "Bounding box as comma-separated values: minLon,minLat,maxLon,maxLat"
+// @Param as_of query string false "Search the latest snapshot taken on or before this date (YYYY-MM-DD)"
+// @Param X-API-Key header string false "API key, for a different maximum number of results"
 // @Produce json
 // @Success 200 {object} GroupedSearchResponse
+// @Header 200 {string} X-Results-Truncated "true if the results were truncated"
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Failure 504 {object} map[string]string
 // @Router /search/by-postcode [get]
-func GroupByPostcode(repo repo.SearchRepository) func(c *gin.Context) {
+func GroupByPostcode(repository repo.SearchRepository) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		bbox, err := parseBBox(c.Query("bbox"))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		repo, snapshotDate, ok := repositoryAsOf(c, repository)
+		if !ok {
+			return
+		}
 
+		capped := newResultCap(c)
 		results := make(map[string][]models.CompanyDataWithLocation, 100)
-		err = repo.Find(bbox, func(companyData *models.CompanyDataWithLocation) {
+		err = repo.Find(c.Request.Context(), bbox, capped.limit(), func(companyData *models.CompanyDataWithLocation) {
+			if !capped.accept() {
+				return
+			}
 			arr, exists := results[companyData.RegAddressPostCode]
 			if !exists {
 				arr = make([]models.CompanyDataWithLocation, 0, 10)
@@ -97,20 +183,69 @@ func GroupByPostcode(repo repo.SearchRepository) func(c *gin.Context) {
 		})
 
 		if err != nil {
-			slog.Error("error while fetching company data", "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+			queryFailed(c, err, "error while fetching company data")
 			return
 		}
 
+		capped.writeTruncated(c)
 		c.JSON(http.StatusOK, GroupedSearchResponse{
-			Results:     results,
-			Attribution: internal.ATTRIBUTION,
-			LastUpdated: repo.LastUpdated(),
+			Results:      results,
+			Truncated:    capped.truncated,
+			Attribution:  internal.ATTRIBUTION,
+			LastUpdated:  repo.LastUpdated(),
+			SnapshotDate: snapshotDate,
 		})
 	}
 }
 
+// repositoryAsOf returns the repository to search: the snapshot for the as_of
+// date and its date if one was requested, or the live database otherwise. If
+// the request can't be served, it writes the error response and returns false.
+func repositoryAsOf(c *gin.Context, repository repo.SearchRepository) (repo.SearchRepository, string, bool) {
+	asOfStr := c.Query("as_of")
+	if asOfStr == "" {
+		return repository, "", true
+	}
+
+	asOf, err := time.Parse(time.DateOnly, asOfStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "as_of must be a date in the form YYYY-MM-DD"})
+		return nil, "", false
+	}
+
+	snapshots, ok := repository.(repo.SnapshotProvider)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "snapshots are not available"})
+		return nil, "", false
+	}
+	snapshot, snapshotDate, err := snapshots.AsOf(asOf)
+	if errors.Is(err, repo.ErrNoSnapshot) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, "", false
+	} else if err != nil {
+		slog.Error("error while opening snapshot", "asOf", asOfStr, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal server error occurred"})
+		return nil, "", false
+	}
+	return snapshot, snapshotDate.Format(time.DateOnly), true
+}
+
 func parseBBox(bboxStr string) ([]float64, error) {
+	bbox, err := parseBBoxValues(bboxStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if math.Abs(bbox[2]-bbox[0]) > MAX_BOUNDS || math.Abs(bbox[3]-bbox[1]) > MAX_BOUNDS {
+		return nil, fmt.Errorf("bbox must define a valid area (no more than %d KM in either dimension)", MAX_BOUNDS/1000)
+	}
+
+	return bbox, nil
+}
+
+// parseBBoxValues parses a bounding box without limiting its size, for
+// endpoints that are paginated.
+func parseBBoxValues(bboxStr string) ([]float64, error) {
 	bboxParts := strings.Split(bboxStr, ",")
 	if len(bboxParts) != 4 {
 		return nil, fmt.Errorf("bbox must have 4 comma-separated values")
@@ -125,9 +260,5 @@ func parseBBox(bboxStr string) ([]float64, error) {
 		bbox[i] = val
 	}
 
-	if math.Abs(bbox[2]-bbox[0]) > MAX_BOUNDS || math.Abs(bbox[3]-bbox[1]) > MAX_BOUNDS {
-		return nil, fmt.Errorf("bbox must define a valid area (no more than %d KM in either dimension)", MAX_BOUNDS/1000)
-	}
-
 	return bbox, nil
 }
@@ -0,0 +1,170 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/map-services/company-data-api/internal/middleware"
+	"github.com/map-services/company-data-api/internal/models"
+	repo "github.com/map-services/company-data-api/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubSnapshotRepository serves a single company, named after the snapshot
+// it was found in.
+type stubSnapshotRepository struct {
+	stubAreaRepository
+	name      string
+	snapshots map[string]*stubSnapshotRepository
+	err       error
+}
+
+func (s *stubSnapshotRepository) Find(ctx context.Context, bbox []float64, limit int, rowProcessor func(companyData *models.CompanyDataWithLocation)) error {
+	rowProcessor(&models.CompanyDataWithLocation{CompanyData: models.CompanyData{CompanyName: s.name, RegAddressPostCode: "TN23 1AA"}})
+	return nil
+}
+
+func (s *stubSnapshotRepository) AsOf(date time.Time) (repo.SearchRepository, time.Time, error) {
+	if s.err != nil {
+		return nil, time.Time{}, s.err
+	}
+	for day := date; day.Year() >= 2025; day = day.AddDate(0, 0, -1) {
+		if snapshot, ok := s.snapshots[day.Format(time.DateOnly)]; ok {
+			return snapshot, day, nil
+		}
+	}
+	return nil, time.Time{}, fmt.Errorf("%w on or before %s", repo.ErrNoSnapshot, date.Format(time.DateOnly))
+}
+
+func search(t *testing.T, repository repo.SearchRepository, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/search", Search(repository))
+	r.GET("/search/by-postcode", GroupByPostcode(repository))
+	r.GET("/search/by-area", SearchByArea(repository))
+	r.GET("/search/radius", SearchRadius(repository))
+	r.GET("/search/nearest", SearchNearest(repository))
+
+	req, err := http.NewRequest("GET", path, nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func newStubSnapshotRepository() *stubSnapshotRepository {
+	return &stubSnapshotRepository{
+		name: "LIVE",
+		snapshots: map[string]*stubSnapshotRepository{
+			"2025-03-01": {name: "MARCH", stubAreaRepository: stubAreaRepository{numbers: []string{"03"}}},
+		},
+	}
+}
+
+func TestSearchAsOf(t *testing.T) {
+	repository := newStubSnapshotRepository()
+
+	w := search(t, repository, "/search?bbox=600000,141000,602000,143000")
+	require.Equal(t, http.StatusOK, w.Code)
+	var response SearchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "LIVE", response.Results[0].CompanyName)
+	assert.Empty(t, response.SnapshotDate)
+
+	w = search(t, repository, "/search?bbox=600000,141000,602000,143000&as_of=2025-03-20")
+	require.Equal(t, http.StatusOK, w.Code)
+	response = SearchResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "MARCH", response.Results[0].CompanyName)
+	assert.Equal(t, "2025-03-01", response.SnapshotDate)
+
+	w = search(t, repository, "/search/by-postcode?bbox=600000,141000,602000,143000&as_of=2025-03-01")
+	require.Equal(t, http.StatusOK, w.Code)
+	var grouped GroupedSearchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &grouped))
+	assert.Equal(t, "MARCH", grouped.Results["TN23 1AA"][0].CompanyName)
+	assert.Equal(t, "2025-03-01", grouped.SnapshotDate)
+
+	w = search(t, repository, "/search/by-area?type=district&code=E07000105&as_of=2025-03-20")
+	require.Equal(t, http.StatusOK, w.Code)
+	var page AreaSearchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page), w.Body.String())
+	assert.Equal(t, "03", page.Results[0].CompanyNumber)
+	assert.Equal(t, "2025-03-01", page.SnapshotDate)
+}
+
+func TestSearchAsOfErrors(t *testing.T) {
+	cases := map[string]struct {
+		repository repo.SearchRepository
+		query      string
+		status     int
+	}{
+		"invalid date":          {newStubSnapshotRepository(), "as_of=March", http.StatusBadRequest},
+		"before first snapshot": {newStubSnapshotRepository(), "as_of=2025-02-28", http.StatusNotFound},
+		"snapshots unsupported": {&stubAreaRepository{}, "as_of=2025-03-01", http.StatusNotFound},
+		"snapshot fails":        {&stubSnapshotRepository{err: errors.New("boom")}, "as_of=2025-03-01", http.StatusInternalServerError},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := search(t, tc.repository, "/search?bbox=600000,141000,602000,143000&"+tc.query)
+			assert.Equal(t, tc.status, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestSearchTruncates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository := &stubAreaRepository{numbers: []string{"01", "02", "03"}}
+
+	get := func(path string, maxResults int) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.Use(middleware.ResultLimit(maxResults, nil))
+		r.GET("/search", Search(repository))
+		r.GET("/search/by-postcode", GroupByPostcode(repository))
+
+		req, err := http.NewRequest("GET", path, nil)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	cases := map[string]struct {
+		maxResults int
+		results    int
+		truncated  bool
+	}{
+		"under the limit":   {4, 3, false},
+		"exactly the limit": {3, 3, false},
+		"over the limit":    {2, 2, true},
+		"no limit":          {0, 3, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := get("/search?bbox=600000,141000,602000,143000", tc.maxResults)
+			require.Equal(t, http.StatusOK, w.Code)
+			var response SearchResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Len(t, response.Results, tc.results)
+			assert.Equal(t, tc.truncated, response.Truncated)
+			assert.Equal(t, tc.truncated, w.Header().Get(TruncatedHeader) == "true")
+
+			w = get("/search/by-postcode?bbox=600000,141000,602000,143000", tc.maxResults)
+			require.Equal(t, http.StatusOK, w.Code)
+			var grouped GroupedSearchResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &grouped))
+			assert.Len(t, grouped.Results, tc.results)
+			assert.Equal(t, tc.truncated, grouped.Truncated)
+			assert.Equal(t, tc.truncated, w.Header().Get(TruncatedHeader) == "true")
+		})
+	}
+}
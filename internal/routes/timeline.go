@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/models"
+	repo "github.com/map-services/company-data-api/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TimelineResponse struct {
+	CompanyNumber string                 `json:"company_number"`
+	Events        []models.TimelineEvent `json:"events"`
+	Attribution   []string               `json:"attribution"`
+}
+
+var companyNumberPattern = regexp.MustCompile(`^[A-Z0-9]{8}$`)
+
+// CompanyTimeline godoc
+// @Summary List the changes recorded for a company
+// @Description Returns every change detected for a company across Companies House imports, oldest first: incorporated, renamed, address_changed (with the location of the old and new postcodes), status_changed, dissolved, sic_changed, accounts_filed (when accounts_last_made_up_date moves on) and removed. Changes are only recorded from the second import onwards, so companies that have not changed since the database was first loaded have no timeline.
+// @Tags changes
+// @Param number path string true "Company number, e.g. 01234567 or SC123456. Numbers of fewer than 8 digits are padded with leading zeros."
+// @Produce json
+// @Success 200 {object} TimelineResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 501 {object} map[string]string
+// @Failure 504 {object} map[string]string
+// @Router /companies/{number}/timeline [get]
+func CompanyTimeline(repo repo.SearchRepository) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		companyNumber, err := parseCompanyNumber(c.Param("number"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		events := make([]models.TimelineEvent, 0)
+		err = repo.FindTimeline(c.Request.Context(), companyNumber, func(event *models.TimelineEvent) {
+			events = append(events, *event)
+		})
+
+		if err != nil {
+			queryFailed(c, err, "error while fetching company timeline", "companyNumber", companyNumber)
+			return
+		}
+
+		if len(events) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no changes have been recorded for company %s", companyNumber)})
+			return
+		}
+
+		c.JSON(http.StatusOK, TimelineResponse{
+			CompanyNumber: companyNumber,
+			Events:        events,
+			Attribution:   internal.ATTRIBUTION,
+		})
+	}
+}
+
+// parseCompanyNumber normalises a company number to the 8 characters used
+// by Companies House, restoring the leading zeros often dropped by
+// spreadsheets.
+func parseCompanyNumber(number string) (string, error) {
+	number = strings.ToUpper(strings.TrimSpace(number))
+	if number != "" && len(number) < 8 && strings.Trim(number, "0123456789") == "" {
+		number = strings.Repeat("0", 8-len(number)) + number
+	}
+	if !companyNumberPattern.MatchString(number) {
+		return "", fmt.Errorf("company number must be 8 letters or digits")
+	}
+	return number, nil
+}
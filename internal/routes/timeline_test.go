@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/map-services/company-data-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubTimelineRepository struct {
+	stubAreaRepository
+	events        []models.TimelineEvent
+	companyNumber string
+}
+
+func (s *stubTimelineRepository) FindTimeline(ctx context.Context, companyNumber string, rowProcessor func(event *models.TimelineEvent)) error {
+	s.companyNumber = companyNumber
+	for i := range s.events {
+		rowProcessor(&s.events[i])
+	}
+	return s.err
+}
+
+func timeline(t *testing.T, repo *stubTimelineRepository, number string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/companies/:number/timeline", CompanyTimeline(repo))
+
+	req, err := http.NewRequest("GET", "/companies/"+number+"/timeline", nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompanyTimeline(t *testing.T) {
+	repo := &stubTimelineRepository{events: []models.TimelineEvent{
+		{CompanyChange: models.CompanyChange{ID: 1, CompanyNumber: "00012345", ChangeType: models.ChangeRenamed, OldValue: "OLD LIMITED", NewValue: "NEW LIMITED"}},
+		{
+			CompanyChange:    models.CompanyChange{ID: 7, CompanyNumber: "00012345", ChangeType: models.ChangeAddressChanged, PostCode: "TN23 9ZZ", PreviousPostCode: "TN23 1AA"},
+			Location:         &models.Location{Easting: 620000, Northing: 160000},
+			PreviousLocation: &models.Location{Easting: 601000, Northing: 142000},
+		},
+	}}
+
+	w := timeline(t, repo, "12345")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "00012345", repo.companyNumber, "the number is padded to 8 digits")
+
+	var response TimelineResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), w.Body.String())
+	assert.Equal(t, "00012345", response.CompanyNumber)
+	require.Len(t, response.Events, 2)
+	assert.Nil(t, response.Events[0].Location)
+	assert.Equal(t, &models.Location{Easting: 601000, Northing: 142000}, response.Events[1].PreviousLocation)
+	assert.NotEmpty(t, response.Attribution)
+
+	timeline(t, repo, "sc123456")
+	assert.Equal(t, "SC123456", repo.companyNumber)
+}
+
+func TestCompanyTimelineErrors(t *testing.T) {
+	w := timeline(t, &stubTimelineRepository{}, "00012345")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	for _, number := range []string{"SC12", "123456789", "00-12345"} {
+		w = timeline(t, &stubTimelineRepository{}, number)
+		assert.Equal(t, http.StatusBadRequest, w.Code, number)
+	}
+
+	w = timeline(t, &stubTimelineRepository{stubAreaRepository: stubAreaRepository{err: errors.New("boom")}}, "00012345")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
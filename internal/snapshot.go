@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Snapshot is a dated, read-only copy of the database, kept so that searches
+// can be answered as of an earlier import.
+type Snapshot struct {
+	Date time.Time
+	Path string
+}
+
+// SnapshotDir returns the directory holding the dated snapshots of dbPath.
+func SnapshotDir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "snapshots")
+}
+
+// SnapshotPath returns the path of the snapshot of dbPath for the given date.
+func SnapshotPath(dbPath string, date time.Time) string {
+	return filepath.Join(SnapshotDir(dbPath), date.Format(time.DateOnly)+".db")
+}
+
+// CreateSnapshot saves a compacted copy of the database at dbPath as the
+// snapshot for the given date, replacing any existing snapshot for that date.
+// The copy is written to a temporary file and renamed into place, so a
+// running API server never sees a partial snapshot.
+func CreateSnapshot(dbPath string, date time.Time) (string, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return "", fmt.Errorf("failed to stat database: %w", err)
+	}
+
+	snapshotPath := SnapshotPath(dbPath, date)
+	if err := os.MkdirAll(filepath.Dir(snapshotPath), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	tmpPath := snapshotPath + ".tmp"
+	if err := removeDatabaseFiles(tmpPath); err != nil {
+		return "", fmt.Errorf("failed to remove previous temporary snapshot: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?mode=ro&_busy_timeout=5000")
+	if err != nil {
+		return "", fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			slog.Error("error closing database", "error", err)
+		}
+	}()
+
+	slog.Info("Creating snapshot", "dbPath", dbPath, "snapshotPath", snapshotPath)
+	if _, err := db.Exec("VACUUM INTO ?", tmpPath); err != nil {
+		return "", fmt.Errorf("failed to copy database into snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, snapshotPath); err != nil {
+		return "", fmt.Errorf("failed to move snapshot into place: %w", err)
+	}
+	return snapshotPath, nil
+}
+
+// ListSnapshots returns the snapshots in dir, oldest first. A missing
+// directory holds no snapshots.
+func ListSnapshots(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".db")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		date, err := time.Parse(time.DateOnly, name)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{Date: date, Path: filepath.Join(dir, entry.Name())})
+	}
+	slices.SortFunc(snapshots, func(a, b Snapshot) int {
+		return a.Date.Compare(b.Date)
+	})
+	return snapshots, nil
+}
+
+// SnapshotAsOf returns the latest of the (sorted) snapshots taken on or
+// before the given date.
+func SnapshotAsOf(snapshots []Snapshot, date time.Time) (Snapshot, bool) {
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if !snapshots[i].Date.After(date) {
+			return snapshots[i], true
+		}
+	}
+	return Snapshot{}, false
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAndListSnapshots(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "companies_data.db")
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	db, err := Connect(dbPath)
+	require.NoError(t, err)
+	_, err = db.Exec(InsertCodePointSQL, "AB12 3CD", 10, 300000, 700000, "", "", "", "", "", "", MortonKey(300000, 700000))
+	require.NoError(t, err)
+
+	snapshotPath, err := CreateSnapshot(dbPath, march)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(dbPath), "snapshots", "2025-03-01.db"), snapshotPath)
+
+	_, err = db.Exec(InsertCodePointSQL, "EF45 6GH", 10, 310000, 710000, "", "", "", "", "", "", MortonKey(310000, 710000))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	_, err = CreateSnapshot(dbPath, april)
+	require.NoError(t, err)
+
+	// Files that aren't dated snapshots are ignored.
+	require.NoError(t, os.WriteFile(filepath.Join(SnapshotDir(dbPath), "notes.txt"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(SnapshotDir(dbPath), "latest.db"), nil, 0o644))
+
+	snapshots, err := ListSnapshots(SnapshotDir(dbPath))
+	require.NoError(t, err)
+	assert.Equal(t, []Snapshot{
+		{Date: march, Path: SnapshotPath(dbPath, march)},
+		{Date: april, Path: SnapshotPath(dbPath, april)},
+	}, snapshots)
+
+	snapshot, err := ConnectReadOnly(snapshots[0].Path)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, snapshot.Close())
+	}()
+	var count int
+	require.NoError(t, snapshot.QueryRow("SELECT COUNT(*) FROM code_point").Scan(&count))
+	assert.Equal(t, 1, count, "the March snapshot should not include later changes")
+}
+
+func TestListSnapshotsWithoutDirectory(t *testing.T) {
+	snapshots, err := ListSnapshots(filepath.Join(t.TempDir(), "snapshots"))
+	require.NoError(t, err)
+	assert.Empty(t, snapshots)
+}
+
+func TestSnapshotAsOf(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		require.NoError(t, err)
+		return d
+	}
+	snapshots := []Snapshot{{Date: date("2025-03-01")}, {Date: date("2025-04-01")}}
+
+	_, ok := SnapshotAsOf(snapshots, date("2025-02-28"))
+	assert.False(t, ok)
+
+	for asOf, expected := range map[string]string{
+		"2025-03-01": "2025-03-01",
+		"2025-03-31": "2025-03-01",
+		"2025-04-01": "2025-04-01",
+		"2026-01-01": "2025-04-01",
+	} {
+		snapshot, ok := SnapshotAsOf(snapshots, date(asOf))
+		assert.True(t, ok, asOf)
+		assert.Equal(t, date(expected), snapshot.Date, asOf)
+	}
+}
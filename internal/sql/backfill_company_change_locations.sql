@@ -0,0 +1,19 @@
+-- Changes recorded before they had locations are given those of their
+-- postcodes, or the company's own where its postcode is still the same.
+UPDATE company_changes
+SET (easting, northing, previous_easting, previous_northing) = (
+    SELECT
+        COALESCE(
+            (SELECT cd.easting FROM company_data cd
+             WHERE cd.company_number = company_changes.company_number
+             AND cd.reg_address_post_code = company_changes.post_code),
+            (SELECT cp.easting FROM code_point cp WHERE cp.post_code = company_changes.post_code)),
+        COALESCE(
+            (SELECT cd.northing FROM company_data cd
+             WHERE cd.company_number = company_changes.company_number
+             AND cd.reg_address_post_code = company_changes.post_code),
+            (SELECT cp.northing FROM code_point cp WHERE cp.post_code = company_changes.post_code)),
+        (SELECT cp.easting FROM code_point cp WHERE cp.post_code = company_changes.previous_post_code),
+        (SELECT cp.northing FROM code_point cp WHERE cp.post_code = company_changes.previous_post_code)
+)
+WHERE easting IS NULL
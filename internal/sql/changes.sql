@@ -0,0 +1,11 @@
+SELECT
+    ch.id, ch.company_number, ch.change_type, ch.old_value, ch.new_value,
+    ch.post_code, ch.previous_post_code, ch.detected_at
+FROM company_changes ch
+WHERE ch.detected_at >= ?1
+AND ch.id > ?2
+AND (?3 = 0
+    OR (ch.easting BETWEEN ?4 AND ?5 AND ch.northing BETWEEN ?6 AND ?7)
+    OR (ch.previous_easting BETWEEN ?4 AND ?5 AND ch.previous_northing BETWEEN ?6 AND ?7))
+ORDER BY ch.id
+LIMIT ?8
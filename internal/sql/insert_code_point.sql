@@ -1 +1,14 @@
-INSERT OR REPLACE INTO code_point (post_code, easting, northing) VALUES (?,?,?)
+INSERT OR REPLACE INTO code_point (
+    post_code,
+    positional_quality,
+    easting,
+    northing,
+    country_code,
+    nhs_region_code,
+    nhs_ha_code,
+    county_code,
+    district_code,
+    ward_code,
+    morton_key,
+    source
+) VALUES (?,?,?,?,?,?,?,?,?,?,?,'codepoint')
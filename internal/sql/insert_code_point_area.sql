@@ -0,0 +1 @@
+INSERT OR REPLACE INTO code_point_area (code, name, area_type) VALUES (?,?,?)
@@ -0,0 +1,15 @@
+-- The company's previous location is read before the import overwrites it.
+INSERT INTO company_changes (
+    company_number,
+    change_type,
+    old_value,
+    new_value,
+    post_code,
+    previous_post_code,
+    detected_at,
+    previous_easting,
+    previous_northing
+)
+SELECT ?1, ?2, ?3, ?4, ?5, ?6, ?7, previous.easting, previous.northing
+FROM (SELECT 1)
+LEFT JOIN company_data previous ON previous.company_number = ?1 AND ?6 != ''
@@ -0,0 +1,28 @@
+-- CodePoint Open takes precedence over ONSPD where both have a postcode, so
+-- ONSPD only replaces the postcodes it imported itself.
+INSERT INTO code_point (
+    post_code,
+    positional_quality,
+    easting,
+    northing,
+    country_code,
+    nhs_region_code,
+    nhs_ha_code,
+    county_code,
+    district_code,
+    ward_code,
+    morton_key,
+    source
+) VALUES (?,?,?,?,?,?,?,?,?,?,?,'onspd')
+ON CONFLICT (post_code) DO UPDATE SET
+    positional_quality = excluded.positional_quality,
+    easting = excluded.easting,
+    northing = excluded.northing,
+    country_code = excluded.country_code,
+    nhs_region_code = excluded.nhs_region_code,
+    nhs_ha_code = excluded.nhs_ha_code,
+    county_code = excluded.county_code,
+    district_code = excluded.district_code,
+    ward_code = excluded.ward_code,
+    morton_key = excluded.morton_key
+WHERE code_point.source = 'onspd'
@@ -0,0 +1,4 @@
+INSERT INTO company_changes (company_number, change_type, old_value, post_code, detected_at, easting, northing)
+SELECT company_number, 'removed', company_status, reg_address_post_code, ?, easting, northing
+FROM company_data
+WHERE company_number NOT IN (SELECT key FROM {{seen_table}})
@@ -0,0 +1,7 @@
+UPDATE company_data
+SET easting = cp.easting, northing = cp.northing, morton_key = cp.morton_key, location_precision = 'postcode'
+FROM code_point cp
+WHERE cp.post_code = company_data.reg_address_post_code
+-- Quality 90 means CodePoint has no coordinates for the postcode.
+AND cp.positional_quality < 90
+AND company_data.morton_key IS NULL
@@ -0,0 +1,14 @@
+UPDATE company_data
+SET (easting, northing, morton_key, location_precision) = (
+    SELECT c.easting, c.northing, c.morton_key, c.precision
+    FROM postcode_centroid c
+    WHERE c.code IN (
+        substr(company_data.reg_address_post_code, 1, length(company_data.reg_address_post_code) - 2),
+        substr(company_data.reg_address_post_code, 1, instr(company_data.reg_address_post_code, ' ') - 1)
+    )
+    -- The sector is the closer match.
+    ORDER BY length(c.code) DESC
+    LIMIT 1
+)
+WHERE morton_key IS NULL
+AND reg_address_post_code LIKE '% ___'
@@ -0,0 +1,12 @@
+-- The changes found by the latest import, which share its detected_at, are
+-- given the location the import copied onto the company.
+UPDATE company_changes
+SET (easting, northing) = (
+    SELECT cd.easting, cd.northing
+    FROM company_data cd
+    WHERE cd.company_number = company_changes.company_number
+    AND cd.reg_address_post_code = company_changes.post_code
+)
+WHERE detected_at = (SELECT MAX(detected_at) FROM company_changes)
+AND easting IS NULL
+AND change_type != 'removed'
@@ -1,12 +1,60 @@
 CREATE TABLE IF NOT EXISTS code_point (
     post_code TEXT NOT NULL PRIMARY KEY,
+    positional_quality NUMERIC NOT NULL DEFAULT 0,
     easting NUMERIC NOT NULL,
-    northing NUMERIC NOT NULL
+    northing NUMERIC NOT NULL,
+    country_code TEXT NOT NULL DEFAULT '',
+    nhs_region_code TEXT NOT NULL DEFAULT '',
+    nhs_ha_code TEXT NOT NULL DEFAULT '',
+    county_code TEXT NOT NULL DEFAULT '',
+    district_code TEXT NOT NULL DEFAULT '',
+    ward_code TEXT NOT NULL DEFAULT '',
+    morton_key INTEGER,
+    -- The gazetteer the postcode was imported from: codepoint or onspd.
+    source TEXT NOT NULL DEFAULT 'codepoint'
 );
 
 CREATE INDEX IF NOT EXISTS idx_code_point_easting_northing
 ON code_point (easting, northing);
 
+CREATE INDEX IF NOT EXISTS idx_code_point_nhs_region_code
+ON code_point (nhs_region_code);
+
+CREATE INDEX IF NOT EXISTS idx_code_point_county_code
+ON code_point (county_code);
+
+CREATE INDEX IF NOT EXISTS idx_code_point_district_code
+ON code_point (district_code);
+
+CREATE INDEX IF NOT EXISTS idx_code_point_ward_code
+ON code_point (ward_code);
+
+-- Spatial index of code_point, rebuilt after each CodePoint import. Entries
+-- are joined back on post_code rather than rowid, which VACUUM may renumber.
+CREATE VIRTUAL TABLE IF NOT EXISTS code_point_rtree USING rtree (
+    id,
+    min_easting, max_easting,
+    min_northing, max_northing,
+    +post_code TEXT
+);
+
+-- Mean locations of the postcodes in each sector ("AB10 1") and district
+-- ("AB10"), for companies whose postcode is not in CodePoint. Rebuilt after
+-- each CodePoint import.
+CREATE TABLE IF NOT EXISTS postcode_centroid (
+    code TEXT NOT NULL PRIMARY KEY,
+    precision TEXT NOT NULL,
+    easting INTEGER NOT NULL,
+    northing INTEGER NOT NULL,
+    morton_key INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS code_point_area (
+    code TEXT NOT NULL PRIMARY KEY,
+    name TEXT NOT NULL,
+    area_type TEXT NOT NULL
+);
+
 
 CREATE TABLE IF NOT EXISTS company_data (
     company_name TEXT NOT NULL,
@@ -48,8 +96,50 @@ CREATE TABLE IF NOT EXISTS company_data (
     limited_partnerships_num_lim_partners NUMERIC NOT NULL,
     uri TEXT NOT NULL,
     conf_stmt_next_due_date TIMESTAMP,
-    conf_stmt_last_made_up_date TIMESTAMP
+    conf_stmt_last_made_up_date TIMESTAMP,
+    -- Copied from code_point, or postcode_centroid, after each import, and
+    -- NULL when the registered postcode could not be located.
+    easting INTEGER,
+    northing INTEGER,
+    morton_key INTEGER,
+    location_precision TEXT
 );
 
 CREATE INDEX IF NOT EXISTS idx_company_data_reg_address_post_code
 ON company_data (reg_address_post_code);
+
+-- Covers the bounding box filter, so that searches only read the rows of
+-- companies within the box.
+CREATE INDEX IF NOT EXISTS idx_company_data_location
+ON company_data (morton_key, easting, northing);
+
+CREATE TABLE IF NOT EXISTS company_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    company_number TEXT NOT NULL,
+    change_type TEXT NOT NULL,
+    old_value TEXT NOT NULL DEFAULT '',
+    new_value TEXT NOT NULL DEFAULT '',
+    post_code TEXT NOT NULL DEFAULT '',
+    previous_post_code TEXT NOT NULL DEFAULT '',
+    detected_at TIMESTAMP NOT NULL,
+    -- The company's location at post_code and, for an address change, at
+    -- previous_post_code, as copied onto company_data. NULL if it had none.
+    easting INTEGER,
+    northing INTEGER,
+    previous_easting INTEGER,
+    previous_northing INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_company_changes_detected_at
+ON company_changes (detected_at);
+
+CREATE INDEX IF NOT EXISTS idx_company_changes_company_number
+ON company_changes (company_number);
+
+-- When each dataset was last imported. Together these identify the version
+-- of the data being served, so that cached search results from an earlier
+-- version are not reused.
+CREATE TABLE IF NOT EXISTS dataset_imports (
+    dataset TEXT NOT NULL PRIMARY KEY,
+    imported_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,3 @@
+DELETE FROM code_point_rtree;
+INSERT INTO code_point_rtree (id, min_easting, max_easting, min_northing, max_northing, post_code)
+SELECT rowid, easting, easting, northing, northing, post_code FROM code_point;
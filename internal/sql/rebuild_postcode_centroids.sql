@@ -0,0 +1,11 @@
+DELETE FROM postcode_centroid;
+INSERT INTO postcode_centroid (code, precision, easting, northing)
+SELECT substr(post_code, 1, length(post_code) - 2), 'sector', CAST(round(avg(easting)) AS INTEGER), CAST(round(avg(northing)) AS INTEGER)
+FROM code_point
+WHERE post_code LIKE '% ___' AND positional_quality < 90
+GROUP BY 1;
+INSERT INTO postcode_centroid (code, precision, easting, northing)
+SELECT substr(post_code, 1, instr(post_code, ' ') - 1), 'district', CAST(round(avg(easting)) AS INTEGER), CAST(round(avg(northing)) AS INTEGER)
+FROM code_point
+WHERE post_code LIKE '% ___' AND positional_quality < 90
+GROUP BY 1;
@@ -0,0 +1,2 @@
+INSERT INTO dataset_imports (dataset, imported_at) VALUES (?, ?)
+ON CONFLICT (dataset) DO UPDATE SET imported_at = excluded.imported_at
@@ -0,0 +1,7 @@
+UPDATE company_data
+SET (easting, northing, morton_key, location_precision) = (
+    SELECT cp.easting, cp.northing, cp.morton_key, 'postcode'
+    FROM code_point cp
+    WHERE cp.post_code = company_data.reg_address_post_code
+    AND cp.positional_quality < 90
+)
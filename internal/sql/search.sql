@@ -10,8 +10,18 @@ SELECT
     cd.sic_code_1, cd.sic_code_2, cd.sic_code_3, cd.sic_code_4,
     cd.limited_partnerships_num_gen_partners, cd.limited_partnerships_num_lim_partners,
     cd.uri, cd.conf_stmt_next_due_date, cd.conf_stmt_last_made_up_date,
-    cp.easting, cp.northing
-FROM code_point cp
-INNER JOIN company_data cd ON cp.post_code = cd.reg_address_post_code
-WHERE cp.easting BETWEEN ? AND ?
-AND cp.northing BETWEEN ? AND ?
+    cd.easting, cd.northing, COALESCE(cp.positional_quality, 0), COALESCE(cp.country_code, ''),
+    COALESCE(cp.nhs_region_code, ''), COALESCE(cp.nhs_ha_code, ''),
+    COALESCE(cp.county_code, ''), COALESCE(county.name, ''),
+    COALESCE(cp.district_code, ''), COALESCE(district.name, ''),
+    COALESCE(cp.ward_code, ''), COALESCE(ward.name, ''),
+    COALESCE(cd.location_precision, 'postcode')
+FROM company_data cd
+LEFT JOIN code_point cp ON cp.post_code = cd.reg_address_post_code
+LEFT JOIN code_point_area county ON county.code = cp.county_code
+LEFT JOIN code_point_area district ON district.code = cp.district_code
+LEFT JOIN code_point_area ward ON ward.code = cp.ward_code
+WHERE cd.morton_key BETWEEN ? AND ?
+AND cd.easting BETWEEN ? AND ?
+AND cd.northing BETWEEN ? AND ?
+ORDER BY cd.morton_key
@@ -0,0 +1,27 @@
+SELECT
+    cd.company_name, cd.company_number, cd.reg_address_care_of, cd.reg_address_po_box,
+    cd.reg_address_address_line_1, cd.reg_address_address_line_2, cd.reg_address_post_town,
+    cd.reg_address_county, cd.reg_address_country, cd.reg_address_post_code,
+    cd.company_category, cd.company_status, cd.country_of_origin, cd.dissolution_date,
+    cd.incorporation_date, cd.accounts_account_ref_day, cd.accounts_account_ref_month,
+    cd.accounts_next_due_date, cd.accounts_last_made_up_date, cd.accounts_account_category,
+    cd.returns_next_due_date, cd.returns_last_made_up_date, cd.mortgages_num_charges,
+    cd.mortgages_num_outstanding, cd.mortgages_num_part_satisfied, cd.mortgages_num_satisfied,
+    cd.sic_code_1, cd.sic_code_2, cd.sic_code_3, cd.sic_code_4,
+    cd.limited_partnerships_num_gen_partners, cd.limited_partnerships_num_lim_partners,
+    cd.uri, cd.conf_stmt_next_due_date, cd.conf_stmt_last_made_up_date,
+    cp.easting, cp.northing, cp.positional_quality, cp.country_code,
+    cp.nhs_region_code, cp.nhs_ha_code,
+    cp.county_code, COALESCE(county.name, ''),
+    cp.district_code, COALESCE(district.name, ''),
+    cp.ward_code, COALESCE(ward.name, ''),
+    'postcode'
+FROM code_point cp
+INNER JOIN company_data cd ON cp.post_code = cd.reg_address_post_code
+LEFT JOIN code_point_area county ON county.code = cp.county_code
+LEFT JOIN code_point_area district ON district.code = cp.district_code
+LEFT JOIN code_point_area ward ON ward.code = cp.ward_code
+WHERE cp.{{area_column}} = ?
+AND cd.company_number > ?
+ORDER BY cd.company_number
+LIMIT ?
@@ -0,0 +1,12 @@
+-- Changes recorded without a location, such as those of companies that had
+-- none, fall back to that of their postcode.
+SELECT
+    ch.id, ch.company_number, ch.change_type, ch.old_value, ch.new_value,
+    ch.post_code, ch.previous_post_code, ch.detected_at,
+    COALESCE(ch.easting, cp.easting), COALESCE(ch.northing, cp.northing),
+    COALESCE(ch.previous_easting, previous.easting), COALESCE(ch.previous_northing, previous.northing)
+FROM company_changes ch
+LEFT JOIN code_point cp ON cp.post_code = ch.post_code
+LEFT JOIN code_point previous ON previous.post_code = ch.previous_post_code
+WHERE ch.company_number = ?
+ORDER BY ch.id
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// StagingPath returns the path of the database file that blue/green imports
+// are built into before being promoted over dbPath.
+func StagingPath(dbPath string) string {
+	return dbPath + ".staging"
+}
+
+// PrepareStaging creates a fresh staging database next to dbPath, seeded with
+// a compacted copy of the live database (if there is one) so that datasets
+// which are not part of this import are carried across. It returns the path
+// of the staging database.
+func PrepareStaging(dbPath string) (string, error) {
+	stagingPath := StagingPath(dbPath)
+	if err := removeDatabaseFiles(stagingPath); err != nil {
+		return "", fmt.Errorf("failed to remove previous staging database: %w", err)
+	}
+
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		slog.Info("No live database found, staging database will start empty", "dbPath", dbPath)
+		return stagingPath, nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to stat live database: %w", err)
+	}
+
+	live, err := sql.Open("sqlite3", "file:"+dbPath+"?mode=ro&_busy_timeout=5000")
+	if err != nil {
+		return "", fmt.Errorf("failed to open live database: %w", err)
+	}
+	defer func() {
+		if err := live.Close(); err != nil {
+			slog.Error("error closing live database", "error", err)
+		}
+	}()
+
+	slog.Info("Copying live database into staging database", "dbPath", dbPath, "stagingPath", stagingPath)
+	if _, err := live.Exec("VACUUM INTO ?", stagingPath); err != nil {
+		return "", fmt.Errorf("failed to copy live database into staging database: %w", err)
+	}
+	return stagingPath, nil
+}
+
+// OptimizeForServing analyzes and compacts a freshly imported database, and
+// switches it out of WAL mode so that it consists of a single file that can
+// be atomically renamed into place.
+func OptimizeForServing(db *sql.DB) error {
+	slog.Info("Analyzing staging database")
+	if _, err := db.Exec("ANALYZE"); err != nil {
+		return fmt.Errorf("failed to analyze staging database: %w", err)
+	}
+	slog.Info("Vacuuming staging database")
+	if _, err := db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum staging database: %w", err)
+	}
+	if _, err := db.Exec("PRAGMA journal_mode=DELETE"); err != nil {
+		return fmt.Errorf("failed to disable WAL on staging database: %w", err)
+	}
+	return nil
+}
+
+// PromoteStaging atomically replaces the live database with the staging
+// database. The staging database must already have been closed. Running API
+// servers notice the new file and reopen it (see WatchForReload).
+//
+// The live database's write-ahead log and shared-memory files are found by
+// name, so would otherwise outlive it and be replayed onto the staging
+// database when it is next opened. The log is emptied before the rename, so
+// nothing can be replayed from it in the meantime, and the files are removed
+// after.
+func PromoteStaging(stagingPath string, dbPath string) error {
+	if err := checkpointLive(dbPath); err != nil {
+		return err
+	}
+	if err := os.Rename(stagingPath, dbPath); err != nil {
+		return fmt.Errorf("failed to promote staging database: %w", err)
+	}
+	if err := removeJournalFiles(dbPath); err != nil {
+		return fmt.Errorf("failed to remove live database journal: %w", err)
+	}
+	slog.Info("Promoted staging database", "stagingPath", stagingPath, "dbPath", dbPath)
+	return nil
+}
+
+// checkpointLive copies the write-ahead log of the live database, if it has
+// one, into the database and truncates it.
+func checkpointLive(dbPath string) error {
+	if _, err := os.Stat(dbPath + "-wal"); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to stat live database write-ahead log: %w", err)
+	}
+
+	live, err := sql.Open("sqlite3", "file:"+dbPath+"?_busy_timeout=5000")
+	if err != nil {
+		return fmt.Errorf("failed to open live database: %w", err)
+	}
+	defer func() {
+		if err := live.Close(); err != nil {
+			slog.Error("error closing live database", "error", err)
+		}
+	}()
+
+	var busy, frames, checkpointed int
+	if err := live.QueryRow("PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &frames, &checkpointed); err != nil {
+		return fmt.Errorf("failed to checkpoint live database: %w", err)
+	}
+	if busy != 0 {
+		return fmt.Errorf("failed to checkpoint live database: %d of %d frames checkpointed while it was in use", checkpointed, frames)
+	}
+	return nil
+}
+
+func removeDatabaseFiles(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return removeJournalFiles(path)
+}
+
+// removeJournalFiles removes the rollback journal, write-ahead log and
+// shared-memory files of the database at path.
+func removeJournalFiles(path string) error {
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(path + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
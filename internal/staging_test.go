@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlueGreenStagingAndPromotion(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "companies_data.db")
+
+	live, err := Connect(dbPath)
+	require.NoError(t, err)
+	_, err = live.Exec(InsertCodePointSQL, "AB12 3CD", 10, 300000, 700000, "", "", "", "", "", "", MortonKey(300000, 700000))
+	require.NoError(t, err)
+	require.NoError(t, live.Close())
+
+	stagingPath, err := PrepareStaging(dbPath)
+	require.NoError(t, err)
+	assert.Equal(t, StagingPath(dbPath), stagingPath)
+
+	staging, err := Connect(stagingPath)
+	require.NoError(t, err)
+	_, err = staging.Exec(InsertCodePointSQL, "EF45 6GH", 10, 310000, 710000, "", "", "", "", "", "", MortonKey(310000, 710000))
+	require.NoError(t, err)
+	require.NoError(t, OptimizeForServing(staging))
+	require.NoError(t, staging.Close())
+
+	require.NoError(t, PromoteStaging(stagingPath, dbPath))
+	_, err = os.Stat(stagingPath)
+	assert.True(t, os.IsNotExist(err), "staging database should have been moved")
+
+	promoted, err := Connect(dbPath)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, promoted.Close())
+	}()
+
+	var count int
+	require.NoError(t, promoted.QueryRow("SELECT COUNT(*) FROM code_point").Scan(&count))
+	assert.Equal(t, 2, count, "promoted database should contain live and newly imported rows")
+}
+
+func TestPromoteStagingDiscardsLiveWriteAheadLog(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "companies_data.db")
+
+	live, err := Connect(dbPath)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, live.Close())
+	}()
+	// Keep the live database's changes in its write-ahead log.
+	live.SetMaxOpenConns(1)
+	_, err = live.Exec("PRAGMA wal_autocheckpoint=0")
+	require.NoError(t, err)
+
+	stagingPath, err := PrepareStaging(dbPath)
+	require.NoError(t, err)
+	staging, err := Connect(stagingPath)
+	require.NoError(t, err)
+	_, err = staging.Exec(InsertCodePointSQL, "EF45 6GH", 10, 310000, 710000, "", "", "", "", "", "", MortonKey(310000, 710000))
+	require.NoError(t, err)
+	require.NoError(t, OptimizeForServing(staging))
+	require.NoError(t, staging.Close())
+
+	for i := range 100 {
+		postCode := fmt.Sprintf("AB%d 3CD", i)
+		_, err = live.Exec(InsertCodePointSQL, postCode, 10, 300000, 700000, "", "", "", "", "", "", MortonKey(300000, 700000))
+		require.NoError(t, err)
+	}
+	info, err := os.Stat(dbPath + "-wal")
+	require.NoError(t, err)
+	require.NotZero(t, info.Size(), "live database should have an uncheckpointed write-ahead log")
+
+	require.NoError(t, PromoteStaging(stagingPath, dbPath))
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		_, err = os.Stat(dbPath + suffix)
+		assert.True(t, os.IsNotExist(err), "live database %s file should have been removed", suffix)
+	}
+
+	promoted, err := Connect(dbPath)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, promoted.Close())
+	}()
+
+	var integrity string
+	require.NoError(t, promoted.QueryRow("PRAGMA integrity_check").Scan(&integrity))
+	assert.Equal(t, "ok", integrity)
+	var postCodes []string
+	rows, err := promoted.Query("SELECT post_code FROM code_point")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, rows.Close())
+	}()
+	for rows.Next() {
+		var postCode string
+		require.NoError(t, rows.Scan(&postCode))
+		postCodes = append(postCodes, postCode)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"EF45 6GH"}, postCodes, "promoted database should only contain the staging database's rows")
+}
+
+func TestPrepareStagingWithoutLiveDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "companies_data.db")
+
+	stagingPath, err := PrepareStaging(dbPath)
+	require.NoError(t, err)
+
+	_, err = os.Stat(stagingPath)
+	assert.True(t, os.IsNotExist(err), "no staging file should be created when there is no live database")
+}
@@ -1,7 +1,14 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+
 	"github.com/map-services/company-data-api/cmd"
+	"github.com/map-services/company-data-api/internal"
+	"github.com/map-services/company-data-api/internal/importer"
 
 	"github.com/spf13/cobra"
 )
@@ -11,8 +18,29 @@ func main() {
 	var dbPath string
 	var port int
 	var debug bool
-	var companiesHouseZipFile string
-	var codepointZipFile string
+	var reloadInterval time.Duration
+	var queryTimeout time.Duration
+	var maxResults int
+	var apiKeysPath string
+	var cacheOptions cmd.CacheOptions
+	var backend string
+	var servingSnapshotPath string
+	var outputPath string
+	var blueGreen bool
+	var source string
+	var fullRefresh bool
+	var workers int
+	var batchSize int
+	var bulkLoad bool
+	var bbox string
+	var polygon string
+	var postcodeAreas []string
+	var companyStatuses []string
+	var sicPrefixes []string
+	var progress string
+	var dryRun bool
+	var reportPath string
+	var snapshotDate string
 
 	rootCmd := &cobra.Command{
 		Use:  "company-data",
@@ -20,34 +48,148 @@ func main() {
 	}
 
 	apiServerCmd := &cobra.Command{
-		Use:   "api-server [--db <path>] [--port <port>] [--debug]",
+		Use:   "api-server [--db <path>] [--port <port>] [--debug] [--reload-interval <duration>] [--query-timeout <duration>] [--max-results <n>] [--api-keys <path>] [--cache-size-mb <n>] [--redis-url <url>] [--cache-ttl <duration>] [--backend sqlite|memory] [--serving-snapshot <path>]",
 		Short: "Start HTTP API server",
 		Run: func(_ *cobra.Command, _ []string) {
-			cmd.ApiServer(dbPath, port, debug)
+			cmd.ApiServer(dbPath, port, debug, reloadInterval, queryTimeout, maxResults, apiKeysPath, cacheOptions, backend, servingSnapshotPath)
 		},
 	}
 	apiServerCmd.Flags().IntVar(&port, "port", 8080, "Port to run HTTP server on")
 	apiServerCmd.Flags().BoolVar(&debug, "debug", false, "Enable debugging (pprof) - WARING: do not enable in production")
+	apiServerCmd.Flags().DurationVar(&reloadInterval, "reload-interval", 30*time.Second, "How often to check whether the database file has been replaced (0 to only reload on SIGHUP)")
+	apiServerCmd.Flags().DurationVar(&queryTimeout, "query-timeout", 10*time.Second, "How long a database query may run before it is abandoned (0 for no limit)")
+	apiServerCmd.Flags().IntVar(&maxResults, "max-results", 10000, "Maximum number of companies a bounding box search may return before its results are truncated (0 for no limit)")
+	apiServerCmd.Flags().StringVar(&apiKeysPath, "api-keys", "", "Path to a JSON file of API keys and their settings, e.g. a higher max_results, or admin access to /admin routes")
+	apiServerCmd.Flags().IntVar(&cacheOptions.SizeMB, "cache-size-mb", 256, "Size of the in-memory search result cache, in megabytes (0 to disable it)")
+	apiServerCmd.Flags().StringVar(&cacheOptions.RedisURL, "redis-url", "", "Cache search results in this Redis-protocol server, shared by every API server, instead of in memory, e.g. redis://localhost:6379/0")
+	apiServerCmd.Flags().DurationVar(&cacheOptions.TTL, "cache-ttl", 24*time.Hour, "How long search results are kept in the Redis cache")
+	apiServerCmd.Flags().StringVar(&backend, "backend", cmd.BackendSQLite, "Serve bounding box, radius and nearest searches from sqlite, or from memory, loading every located company when the database is opened")
+	apiServerCmd.Flags().StringVar(&servingSnapshotPath, "serving-snapshot", "", "Serve searches from a serving snapshot written by export-snapshot, memory-mapped, instead of the database; changes and timelines are then unavailable")
+
+	importOptions := func() []importer.Option {
+		cobra.CheckErr(internal.SetProgressMode(progress))
+
+		filter := importer.Filter{
+			PostcodeAreas:   postcodeAreas,
+			CompanyStatuses: companyStatuses,
+			SICPrefixes:     sicPrefixes,
+		}
+		if bbox != "" {
+			filter.BoundingBox, err = importer.ParseBoundingBox(bbox)
+			cobra.CheckErr(err)
+		}
+		if polygon != "" {
+			filter.Polygon, err = importer.ParsePolygon(polygon)
+			cobra.CheckErr(err)
+		}
+
+		return []importer.Option{
+			importer.WithFullRefresh(fullRefresh),
+			importer.WithWorkers(workers),
+			importer.WithBatchSize(batchSize),
+			importer.WithBulkLoad(bulkLoad),
+			importer.WithFilter(filter),
+		}
+	}
+
+	runImport := func(dataset string) {
+		if reportPath != "" && !dryRun {
+			cobra.CheckErr(errors.New("--report is only used with --dry-run"))
+		}
+		if dryRun {
+			cmd.DryRunDataset(dataset, source, dbPath, reportPath, importOptions()...)
+			return
+		}
+		cmd.ImportDataset(dataset, source, dbPath, blueGreen, importOptions()...)
+	}
+
+	datasetHelp := "Datasets:\n"
+	for _, dataset := range importer.Datasets() {
+		datasetHelp += fmt.Sprintf("  %-18s %s (default source: %s)\n", dataset.Name(), dataset.Description(), dataset.DefaultSource())
+	}
+
+	importCmd := &cobra.Command{
+		Use:       "import <dataset> [--source <path|url>] [--db <path>] [--full-refresh] [--blue-green]",
+		Short:     "Import a dataset",
+		Long:      "Import a dataset from a zip file, CSV file, gzip-compressed CSV file, directory or URL, or from stdin with --source -.\n\n" + datasetHelp,
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: importer.DatasetNames(),
+		Run: func(_ *cobra.Command, args []string) {
+			runImport(args[0])
+		},
+	}
+	importCmd.Flags().StringVar(&source, "source", "", "Path or URL of the dataset: a .zip, .csv or .csv.gz file, a directory, or - for stdin (default: the dataset's default source)")
 
 	processCompaniesHouseZipCmd := &cobra.Command{
-		Use:   "import-companies-house [--zip-file <path>] [--db <path>]",
-		Short: "Import Companies House ZIP file",
+		Use:        "import-companies-house [--zip-file <path>] [--db <path>] [--full-refresh] [--blue-green]",
+		Short:      "Import Companies House ZIP file",
+		Deprecated: `use "import companies-house --source <path>" instead`,
 		Run: func(_ *cobra.Command, _ []string) {
-			cmd.ImportCompaniesHouseZipFile(companiesHouseZipFile, dbPath)
+			runImport("companies-house")
 		},
 	}
-	processCompaniesHouseZipCmd.Flags().StringVar(&companiesHouseZipFile, "zip-file", "./data/BasicCompanyDataAsOneFile-2025-09-01.zip", "Path to Companies House .zip file")
+	processCompaniesHouseZipCmd.Flags().StringVar(&source, "zip-file", "", "Path to Companies House .zip file")
 
 	processCodepointZipCmd := &cobra.Command{
-		Use:   "import-code-point [--zip-file <path>] [--db <path>]",
-		Short: "Import Codepoint ZIP file",
+		Use:        "import-code-point [--zip-file <path>] [--db <path>] [--full-refresh] [--blue-green]",
+		Short:      "Import Codepoint ZIP file",
+		Deprecated: `use "import code-point --source <path>" instead`,
+		Run: func(_ *cobra.Command, _ []string) {
+			runImport("code-point")
+		},
+	}
+	processCodepointZipCmd.Flags().StringVar(&source, "zip-file", "", "Path to Codepoint .zip file")
+
+	for _, importCmd := range []*cobra.Command{importCmd, processCompaniesHouseZipCmd, processCodepointZipCmd} {
+		importCmd.Flags().BoolVar(&fullRefresh, "full-refresh", false, "Treat the source as a full snapshot and remove rows not present in it")
+		importCmd.Flags().BoolVar(&blueGreen, "blue-green", false, "Build into a staging copy of the database and atomically promote it when complete")
+		importCmd.Flags().IntVar(&workers, "workers", runtime.GOMAXPROCS(0), "Number of goroutines parsing CSV records in parallel")
+		importCmd.Flags().IntVar(&batchSize, "batch-size", 5000, "Number of rows written per transaction")
+		importCmd.Flags().BoolVar(&bulkLoad, "bulk-load", false, "Use import-time PRAGMAs, rebuild indexes after loading and insert multiple rows per statement")
+		importCmd.Flags().StringVar(&bbox, "bbox", "", "Only import rows within minEasting,minNorthing,maxEasting,maxNorthing")
+		importCmd.Flags().StringVar(&polygon, "polygon", "", "Only import rows within a polygon of semicolon-separated easting,northing vertices")
+		importCmd.Flags().StringSliceVar(&postcodeAreas, "postcode-areas", nil, "Only import rows in these postcode areas, e.g. AB,EH")
+		importCmd.Flags().StringSliceVar(&companyStatuses, "company-status", nil, "Only import companies with one of these statuses, e.g. Active")
+		importCmd.Flags().StringSliceVar(&sicPrefixes, "sic-prefix", nil, "Only import companies with a SIC code starting with one of these prefixes")
+		importCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Parse the source and write a data quality report instead of importing it; can't be combined with --blue-green or --bulk-load")
+		importCmd.Flags().StringVar(&reportPath, "report", "", "Where to write the dry-run quality report (default: stdout)")
+		importCmd.Flags().StringVar(&progress, "progress", "auto", "How to report download and import progress: auto, bar, log or off")
+		// A dry run writes nothing, so has nothing to stage or bulk-load.
+		importCmd.MarkFlagsMutuallyExclusive("dry-run", "blue-green")
+		importCmd.MarkFlagsMutuallyExclusive("dry-run", "bulk-load")
+	}
+
+	snapshotCmd := &cobra.Command{
+		Use:   "snapshot [--date <YYYY-MM-DD>] [--db <path>]",
+		Short: "Save a dated copy of the database for as_of searches",
+		Long:  "Save a copy of the database into the snapshots directory next to it, which the API server searches when a request's as_of is on or after the snapshot date. Take a snapshot after each month's imports.",
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			date := time.Now().UTC().Truncate(24 * time.Hour)
+			if snapshotDate != "" {
+				date, err = time.Parse(time.DateOnly, snapshotDate)
+				cobra.CheckErr(err)
+			}
+			cmd.Snapshot(dbPath, date)
+		},
+	}
+	snapshotCmd.Flags().StringVar(&snapshotDate, "date", "", "Date of the snapshot, e.g. the date of the Companies House data (default: today)")
+
+	exportSnapshotCmd := &cobra.Command{
+		Use:   "export-snapshot [--db <path>] [--output <path>]",
+		Short: "Export the located companies to a read-optimised serving snapshot",
+		Long:  "Write the located companies to a serving snapshot: a spatially sorted, columnar file that the API server memory-maps with --serving-snapshot, instead of opening the database. Export after each import; a server watching the file reloads it once it has been replaced.",
+		Args:  cobra.NoArgs,
 		Run: func(_ *cobra.Command, _ []string) {
-			cmd.ImportCodepointZipFile(codepointZipFile, dbPath)
+			cmd.ExportSnapshot(dbPath, outputPath)
 		},
 	}
-	processCodepointZipCmd.Flags().StringVar(&codepointZipFile, "zip-file", "./data/codepo_gb.zip", "Path to Codepoint .zip file")
+	exportSnapshotCmd.Flags().StringVar(&outputPath, "output", "./data/companies_data.snap", "Path to write the serving snapshot to")
 
 	rootCmd.AddCommand(apiServerCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(exportSnapshotCmd)
+	rootCmd.AddCommand(importCmd)
 	rootCmd.AddCommand(processCompaniesHouseZipCmd)
 	rootCmd.AddCommand(processCodepointZipCmd)
 	rootCmd.PersistentFlags().StringVar(&dbPath, "db", "./data/companies_data.db", "Path to Companies data SQLite database")